@@ -4,6 +4,7 @@ import (
 	in_memory "Habr-comments-server/internal/storage/in-memory"
 	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"io"
 	"log/slog"
@@ -13,14 +14,24 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
-	"github.com/99designs/gqlgen/handler"
+	"github.com/vektah/gqlparser/v2/ast"
 
+	"Habr-comments-server/internal/auth"
 	"Habr-comments-server/internal/config"
 	"Habr-comments-server/internal/graphql"
 	"Habr-comments-server/internal/graphql/loaders"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/pubsub"
+	"Habr-comments-server/internal/ratelimit"
 	"Habr-comments-server/internal/service"
 	"Habr-comments-server/internal/storage/pg"
+	"Habr-comments-server/internal/storage/sqlite"
+	"Habr-comments-server/internal/validation"
 )
 
 const (
@@ -31,10 +42,12 @@ const (
 
 var (
 	useInMemory bool
+	makeAdmin   int
 )
 
 func init() {
 	flag.BoolVar(&useInMemory, "in-memory", false, "use in-memory storage")
+	flag.IntVar(&makeAdmin, "make-admin", 0, "grant ADMIN role to the existing user with this ID and exit")
 }
 
 func main() {
@@ -47,19 +60,65 @@ func main() {
 	log.Info("Starting server", slog.String("env", cfg.Env))
 
 	var svc *service.Service
+	var poolStats func() pg.PoolStats // Статистика пула соединений, только для PostgreSQL
 
-	switch useInMemory {
-	case true:
-		db := in_memory.NewInMemoryStorage()
+	// Флаг -in-memory оставлен для совместимости и перекрывает storage.driver
+	driver := cfg.Storage.Driver
+	if useInMemory {
+		driver = config.DriverMemory
+	}
 
-		log.Info("Using in-memory storage")
+	switch driver {
+	case config.DriverMemory:
+		// С in_memory.data_dir изменения пишутся в журнал и переживают перезапуск
+		db, err := in_memory.Open(cfg.InMemory, log)
+		if err != nil {
+			log.Error("Failed to open in-memory storage", slog.Any("error", err))
+			os.Exit(1)
+		}
 
-		// Создаем сервисы
-		svc = service.NewService(db, db)
+		defer func() {
+			// Последний снимок и сброс журнала на диск
+			stopCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.Timeout)
+			defer cancel()
 
-	default:
+			if err = db.Stop(stopCtx); err != nil {
+				log.Error("Failed to stop in-memory storage", slog.Any("error", err))
+			}
+		}()
+
+		log.Info("Using in-memory storage", slog.String("data_dir", cfg.InMemory.DataDir))
+
+		// Создаем сервисы; проверки входных данных одинаковы для всех хранилищ
+		svc = service.NewService(db, db, db, validation.New(cfg.Validation, db))
+
+	case config.DriverSQLite:
+		// Схема создается и обновляется встроенными миграциями при открытии
+		db, err := sqlite.New(cfg.SQLite)
+		if err != nil {
+			log.Error("Failed to open SQLite database", slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		defer func() {
+			stopCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.Timeout)
+			defer cancel()
+
+			if err = db.Stop(stopCtx); err != nil {
+				log.Error("Failed to close SQLite database", slog.Any("error", err))
+			} else {
+				log.Info("SQLite database closed")
+			}
+		}()
+
+		log.Info("Using SQLite storage", slog.String("path", cfg.SQLite.Path))
+
+		// Создаем сервисы; проверки входных данных одинаковы для всех хранилищ
+		svc = service.NewService(db, db, db, validation.New(cfg.Validation, db))
+
+	case config.DriverPostgres:
 		// Подключаемся к БД
-		db, err := pg.New(cfg.Storage)
+		db, err := pg.New(cfg.DB)
 
 		if err != nil {
 			log.Error("Database connection failed", slog.Any("error", err))
@@ -71,36 +130,85 @@ func main() {
 		}
 
 		defer func() {
-			if err = db.Stop(context.Background()); err != nil {
+			// Пул ждет, пока выполняющиеся запросы вернут соединения
+			stopCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.Timeout)
+			defer cancel()
+
+			if err = db.Stop(stopCtx); err != nil {
 				log.Error("Failed to close database connection", slog.Any("error", err))
 			} else {
 				log.Info("Database connection closed")
 			}
 		}()
 
-		log.Info("Connected to database")
+		log.Info("Connected to database", slog.Int("max_conns", int(cfg.DB.MaxConns)))
+		poolStats = db.Stats
+
+		// Создаем сервисы; проверки входных данных одинаковы для всех хранилищ
+		svc = service.NewService(db, db, db, validation.New(cfg.Validation, db))
+
+	default:
+		log.Error("Unknown storage driver", slog.String("driver", driver))
+		os.Exit(1)
+	}
 
-		// Создаем сервисы
-		svc = service.NewService(db, db)
+	// Первого администратора назначают вне API: роль нельзя получить через регистрацию
+	if makeAdmin > 0 {
+		user, err := svc.UserService.SetUserRole(context.Background(), makeAdmin, models.RoleAdmin)
+		if err != nil {
+			log.Error("Failed to grant admin role", slog.Int("user_id", makeAdmin), slog.Any("error", err))
+			os.Exit(1)
+		}
+		log.Info("Admin role granted", slog.Int("user_id", user.ID), slog.String("username", user.Username))
+		return
 	}
 
-	// Создаем DataLoader'ы
-	lds := loaders.NewLoaders(svc)
+	// Токены доступа подписываются секретом из переменной AUTH_SECRET
+	tokens, err := auth.NewTokens(cfg.Auth.Secret, cfg.Auth.TokenTTL)
+	if err != nil {
+		log.Error("Invalid auth secret", slog.Any("error", err))
+		os.Exit(1)
+	}
 
 	// Создаем резолвер GraphQL
 	resolver := &graphql.Resolver{
 		Service: svc,
-		Loaders: lds,
+		Broker:  pubsub.NewBroker(pubsub.DefaultBuffer), // Шина событий для подписок
+		Tokens:  tokens,
 	}
 
-	// Запускаем GraphQL-сервер
-	srv := handler.GraphQL(
-		graphql.NewExecutableSchema(graphql.Config{Resolvers: resolver}),
-		handler.ComplexityLimit(500), // Ограничение сложности запроса
-	)
+	// Лимиты частоты мутаций
+	limits, err := ratelimit.New(cfg.RateLimit)
+	if err != nil {
+		log.Error("Invalid rate limit config", slog.Any("error", err))
+		os.Exit(1)
+	}
 
+	// Запускаем GraphQL-сервер
+	gqlSrv := handler.New(graphql.NewExecutableSchema(graphql.Config{
+		Resolvers:  resolver,
+		Directives: graphql.NewDirectiveRoot(svc), // @hasRole и @isOwner
+	}))
+
+	gqlSrv.AddTransport(transport.Websocket{
+		InitFunc:              auth.WebsocketInit(tokens), // Токен для подписок приходит в connection_init
+		KeepAlivePingInterval: 10 * time.Second,           // Keep-alive для подписок по WebSocket
+	})
+	gqlSrv.AddTransport(transport.Options{})
+	gqlSrv.AddTransport(transport.GET{})
+	gqlSrv.AddTransport(transport.POST{})
+
+	gqlSrv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	gqlSrv.SetErrorPresenter(graphql.NewErrorPresenter(log, cfg.Env == envProd)) // В prod внутренние ошибки не показываются клиенту
+	gqlSrv.Use(extension.Introspection{})
+	gqlSrv.Use(extension.FixedComplexityLimit(500)) // Ограничение сложности запроса
+	gqlSrv.AroundOperations(limits.Middleware)      // Лимиты проверяются до выполнения мутации
+
+	srv := http.HandlerFunc(gqlSrv.ServeHTTP)
+	srv = loaders.Middleware(svc, srv) // Свои DataLoader'ы на каждый запрос
 	srv = GraphQLLoggingMiddleware(log, srv)
-	srv = AuthMiddleware(srv)
+	srv = auth.Middleware(tokens, srv)
+	srv = ratelimit.ClientIPMiddleware(srv)
 
 	mux := http.NewServeMux()
 
@@ -110,6 +218,26 @@ func main() {
 	// Обработчик GraphQL API
 	mux.Handle("/graphql", srv)
 
+	// Статистика пула соединений для мониторинга: на отдельном внутреннем адресе,
+	// чтобы не отдавать ее через публичный листенер без аутентификации
+	var debugServer *http.Server
+	if poolStats != nil && cfg.HTTPServer.DebugAddress != "" {
+		debugMux := http.NewServeMux()
+		debugMux.HandleFunc("/debug/db", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(poolStats()); err != nil {
+				log.Error("Failed to write pool stats", slog.Any("error", err))
+			}
+		})
+
+		debugServer = &http.Server{
+			Addr:         cfg.HTTPServer.DebugAddress,
+			Handler:      debugMux,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+	}
+
 	// Запускаем HTTP-сервер
 	serverAddr := cfg.HTTPServer.Address
 	if serverAddr == "" {
@@ -137,6 +265,17 @@ func main() {
 		}
 	}()
 
+	if debugServer != nil {
+		log.Info("Debug server is running", slog.String("address", debugServer.Addr))
+
+		go func() {
+			if err := debugServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error("Debug server failed", slog.Any("error", err))
+				os.Exit(1)
+			}
+		}()
+	}
+
 	// Ожидание сигнала завершения
 	sig := <-quit
 	log.Info("Shutting down server", slog.String("signal", sig.String()))
@@ -145,6 +284,12 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.Timeout*time.Second)
 	defer cancel()
 
+	if debugServer != nil {
+		if err := debugServer.Shutdown(ctx); err != nil {
+			log.Error("Failed to shutdown debug server", slog.Any("error", err))
+		}
+	}
+
 	if err := server.Shutdown(ctx); err != nil {
 		log.Error("Failed to gracefully shutdown server", slog.Any("error", err))
 	} else {
@@ -154,14 +299,6 @@ func main() {
 	log.Info("Server exited")
 }
 
-func AuthMiddleware(next http.Handler) http.HandlerFunc {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := context.WithValue(r.Context(), "userID", uint(1)) // Заглушка
-		r = r.WithContext(ctx)
-		next.ServeHTTP(w, r)
-	})
-}
-
 func GraphQLLoggingMiddleware(log *slog.Logger, next http.Handler) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -178,9 +315,10 @@ func GraphQLLoggingMiddleware(log *slog.Logger, next http.Handler) http.HandlerF
 		log.Info("GraphQL Request",
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.Path),
-			slog.String("body", string(body)),
 			slog.String("remote_addr", r.RemoteAddr),
 		)
+		// Тело может содержать пароли (createUser, login), поэтому пишем его только в debug
+		log.Debug("GraphQL Request Body", slog.String("body", string(body)))
 
 		next.ServeHTTP(w, r)
 
@@ -0,0 +1,18 @@
+package auth
+
+import "context"
+
+// ctxKey — неэкспортируемый тип ключа, чтобы значение нельзя было подменить
+// через context.WithValue из другого пакета.
+type ctxKey struct{}
+
+// WithUserID кладет ID аутентифицированного пользователя в контекст.
+func WithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, ctxKey{}, userID)
+}
+
+// UserID возвращает ID аутентифицированного пользователя; ok == false для анонимного запроса.
+func UserID(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(ctxKey{}).(int)
+	return userID, ok
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+const bearerPrefix = "Bearer "
+
+// Middleware аутентифицирует запрос по заголовку Authorization: Bearer <token>.
+// Запрос без заголовка проходит анонимно (только чтение), с неверным токеном — отклоняется.
+func Middleware(tokens *Tokens, next http.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := parseBearer(tokens, header)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), userID)))
+	})
+}
+
+// WebsocketInit аутентифицирует подписки: браузер не может передать заголовок
+// при открытии WebSocket, поэтому токен приходит в payload сообщения connection_init.
+func WebsocketInit(tokens *Tokens) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		header := payload.Authorization()
+		if header == "" {
+			return ctx, &payload, nil
+		}
+
+		userID, err := parseBearer(tokens, header)
+		if err != nil {
+			return ctx, nil, err
+		}
+
+		return WithUserID(ctx, userID), &payload, nil
+	}
+}
+
+func parseBearer(tokens *Tokens, header string) (int, error) {
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return 0, errors.New("authorization header must use the Bearer scheme")
+	}
+
+	return tokens.Parse(strings.TrimSpace(strings.TrimPrefix(header, bearerPrefix)))
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+	ErrWeakSecret   = errors.New("token secret is too short")
+)
+
+// MinSecretLen — минимальная длина секрета HMAC в байтах: не короче выхода SHA-256.
+const MinSecretLen = 32
+
+// Заголовок JWT у всех токенов одинаковый, поэтому он закодирован заранее.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+type claims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Tokens выпускает и проверяет JWT-токены, подписанные HMAC-SHA256.
+type Tokens struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewTokens возвращает ErrWeakSecret, если секрет пустой или короче MinSecretLen:
+// коротким секретом любой может подделать токен перебором.
+func NewTokens(secret string, ttl time.Duration) (*Tokens, error) {
+	if len(secret) < MinSecretLen {
+		return nil, fmt.Errorf("%w: need at least %d bytes, got %d", ErrWeakSecret, MinSecretLen, len(secret))
+	}
+
+	return &Tokens{
+		secret: []byte(secret),
+		ttl:    ttl,
+	}, nil
+}
+
+// Issue выпускает токен для пользователя и возвращает время его истечения.
+func (t *Tokens) Issue(userID int) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(t.ttl)
+
+	payload, err := json.Marshal(claims{
+		Subject:   strconv.Itoa(userID),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to encode claims: %w", err)
+	}
+
+	signingInput := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + t.sign(signingInput), expiresAt, nil
+}
+
+// Parse проверяет подпись и срок действия токена и возвращает ID пользователя.
+func (t *Tokens) Parse(token string) (int, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return 0, ErrInvalidToken
+	}
+
+	signingInput := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(t.sign(signingInput))) {
+		return 0, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	var c claims
+	if err = json.Unmarshal(payload, &c); err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	if time.Now().Unix() >= c.ExpiresAt {
+		return 0, ErrTokenExpired
+	}
+
+	userID, err := strconv.Atoi(c.Subject)
+	if err != nil || userID <= 0 {
+		return 0, ErrInvalidToken
+	}
+
+	return userID, nil
+}
+
+func (t *Tokens) sign(signingInput string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/joho/godotenv"
 	"log"
@@ -9,14 +10,90 @@ import (
 )
 
 type Config struct {
-	Env        string `yaml:"env" env-default:"local"`
-	Storage    DB     `yaml:"db" env-required:"true"`
+	Env        string   `yaml:"env" env-default:"local"`
+	Storage    Storage  `yaml:"storage"`
+	DB         DB       `yaml:"db"`
+	InMemory   InMemory `yaml:"in_memory"`
+	SQLite     SQLite   `yaml:"sqlite"`
 	HTTPServer `yaml:"http_server"`
+	Auth       Auth       `yaml:"auth"`
+	RateLimit  RateLimit  `yaml:"rate_limit"`
+	Validation Validation `yaml:"validation"`
 }
 
 type HTTPServer struct {
-	Address string        `yaml:"address" env-default:"localhost:8080"`
-	Timeout time.Duration `yaml:"timeout" env-default:"4s"`
+	Address      string        `yaml:"address" env-default:"localhost:8080"`
+	DebugAddress string        `yaml:"debug_address"` // Внутренний адрес для /debug/*, не публикуется наружу; пусто — выключено
+	Timeout      time.Duration `yaml:"timeout" env-default:"4s"`
+}
+
+type Auth struct {
+	Secret   string        `yaml:"secret" env:"AUTH_SECRET" env-required:"true"` // Ключ HMAC для подписи токенов, не короче 32 байт; в репозиторий не коммитится
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"24h"`
+}
+
+type RateLimit struct {
+	MaxKeys    int                      `yaml:"max_keys" env-default:"10000"` // Сколько пользователей/IP отслеживается на каждую операцию
+	Operations map[string]RateLimitRule `yaml:"operations"`                   // Имя поля Mutation -> лимит
+}
+
+type RateLimitRule struct {
+	Per      string        `yaml:"per"`   // user или ip
+	Limit    int           `yaml:"limit"` // Сколько операций разрешено за Interval
+	Interval time.Duration `yaml:"interval"`
+	Burst    int           `yaml:"burst"` // Сколько операций можно сделать подряд; по умолчанию Limit
+}
+
+// Верхние границы лимитов Validation, которые задают хранилища.
+const (
+	MaxCommentLenLimit   = 2000 // Ограничение CHECK на длину текста в таблице comments
+	MaxCommentDepthLimit = 100  // Путь комментария хранится в индексе comments.path, а строка индекса ограничена
+)
+
+// Validation — ограничения на входные данные мутаций (длины — в символах).
+// Все лимиты положительные; MaxCommentLen и MaxCommentDepth не больше границ выше.
+type Validation struct {
+	MaxTitleLen     int `yaml:"max_title_len" env-default:"200"`
+	MaxPostLen      int `yaml:"max_post_len" env-default:"20000"`
+	MaxCommentLen   int `yaml:"max_comment_len" env-default:"2000"`
+	MaxCommentDepth int `yaml:"max_comment_depth" env-default:"100"` // Глубина ответа; у корневого комментария 0
+}
+
+// Validate проверяет, что лимиты положительные и не выходят за границы хранилищ:
+// иначе текст прошел бы проверку и был отклонен хранилищем без указания поля.
+func (v Validation) Validate() error {
+	limits := []struct {
+		name  string
+		value int
+		max   int // 0 — без верхней границы
+	}{
+		{"max_title_len", v.MaxTitleLen, 0},
+		{"max_post_len", v.MaxPostLen, 0},
+		{"max_comment_len", v.MaxCommentLen, MaxCommentLenLimit},
+		{"max_comment_depth", v.MaxCommentDepth, MaxCommentDepthLimit},
+	}
+
+	for _, l := range limits {
+		if l.value <= 0 {
+			return fmt.Errorf("validation.%s must be positive, got %d", l.name, l.value)
+		}
+		if l.max > 0 && l.value > l.max {
+			return fmt.Errorf("validation.%s must be at most %d, got %d", l.name, l.max, l.value)
+		}
+	}
+
+	return nil
+}
+
+// Хранилища, между которыми выбирает storage.driver.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+type Storage struct {
+	Driver string `yaml:"driver" env:"STORAGE_DRIVER" env-default:"postgres"` // postgres, sqlite или memory
 }
 
 type DB struct {
@@ -25,6 +102,29 @@ type DB struct {
 	Username string `yaml:"username" env-default:"postgres"`
 	Password string `yaml:"password" env-default:"postgres"`
 	Database string `yaml:"database" env-default:"postgres"`
+
+	// Пул соединений
+	MaxConns           int32         `yaml:"max_conns" env-default:"10"`
+	MinConns           int32         `yaml:"min_conns" env-default:"2"`
+	MaxConnLifetime    time.Duration `yaml:"max_conn_lifetime" env-default:"1h"`
+	MaxConnIdleTime    time.Duration `yaml:"max_conn_idle_time" env-default:"30m"`
+	HealthCheckPeriod  time.Duration `yaml:"health_check_period" env-default:"1m"`
+	StatementCacheMode string        `yaml:"statement_cache_mode" env-default:"cache_statement"` // exec или simple_protocol для PgBouncer
+}
+
+// InMemory — долговременный режим in-memory хранилища: журнал изменений и снимки в DataDir.
+// Пустой DataDir — данные живут только в памяти процесса.
+type InMemory struct {
+	DataDir          string        `yaml:"data_dir" env:"IN_MEMORY_DATA_DIR"`
+	Fsync            string        `yaml:"fsync" env-default:"interval"` // always, interval или never
+	FsyncInterval    time.Duration `yaml:"fsync_interval" env-default:"1s"`
+	SnapshotInterval time.Duration `yaml:"snapshot_interval" env-default:"10m"` // 0 — снимок только при остановке
+}
+
+// SQLite — хранилище в одном файле; схема создается миграциями при открытии.
+type SQLite struct {
+	Path        string        `yaml:"path" env:"SQLITE_PATH" env-default:"data/comments.db"`
+	BusyTimeout time.Duration `yaml:"busy_timeout" env-default:"5s"` // Сколько запись ждет, пока базу держит другая транзакция
 }
 
 func MustLoad() *Config {
@@ -47,6 +147,10 @@ func MustLoad() *Config {
 		log.Fatalf("cannot read config: %s", err)
 	}
 
+	if err := cfg.Validation.Validate(); err != nil {
+		log.Fatalf("invalid config: %s", err)
+	}
+
 	return &cfg
 }
 
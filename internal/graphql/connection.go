@@ -0,0 +1,138 @@
+package graphql
+
+import (
+	"Habr-comments-server/internal/models"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 20  // Размер страницы, если first не задан
+	maxPageSize     = 100 // Максимальный размер страницы
+
+	contextSiblings       = 2 // Сколько соседей того же уровня показывать в commentContext
+	defaultContextReplies = 5 // Сколько ответов показывать в commentContext, если repliesBelow не задан
+)
+
+// cursorOrder — порядок списка, для которого выдан курсор.
+// Ключ пагинации имеет смысл только в том порядке, в котором он выдан, поэтому порядок входит в курсор.
+type cursorOrder string
+
+// Посты и комментарии пользователя идут от новых к старым.
+// Курсоры комментариев поста помечаются их сортировкой, см. commentSort.
+const (
+	postsOrder        cursorOrder = "POSTS"
+	userCommentsOrder cursorOrder = "USER_COMMENTS"
+)
+
+// encodeCursor упаковывает ключ пагинации в непрозрачную для клиента строку.
+func encodeCursor(order cursorOrder, c models.Cursor) string {
+	raw := fmt.Sprintf("%s:%d:%d:%d", order, c.Value, c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor разбирает курсор, полученный от клиента, и проверяет, что он выдан для списка с тем же порядком.
+func decodeCursor(s string, order cursorOrder) (*models.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, invalidInput("invalid cursor")
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 4 {
+		return nil, invalidInput("invalid cursor")
+	}
+	if parts[0] != string(order) {
+		return nil, invalidInput("cursor was issued for a different list")
+	}
+
+	var nums [3]int64
+	for i, part := range parts[1:] {
+		nums[i], err = strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, invalidInput("invalid cursor")
+		}
+	}
+
+	return &models.Cursor{
+		Value:     int(nums[0]),
+		CreatedAt: time.Unix(0, nums[1]).UTC(),
+		ID:        int(nums[2]),
+	}, nil
+}
+
+// commentSort переводит аргумент sort в порядок сортировки хранилища.
+func commentSort(sort *CommentSort) models.CommentSort {
+	if sort == nil {
+		return models.SortOldest
+	}
+	return models.CommentSort(*sort)
+}
+
+// pageParams превращает аргументы first/after в параметры запроса к хранилищу.
+// order — порядок списка, для которого должен быть выдан курсор after.
+func pageParams(first *int, after *string, order cursorOrder) (models.PageParams, error) {
+	page := models.PageParams{First: defaultPageSize}
+
+	if first != nil {
+		if *first < 0 {
+			return models.PageParams{}, invalidInput("first must be non-negative")
+		}
+		page.First = min(*first, maxPageSize)
+	}
+
+	if after != nil {
+		cursor, err := decodeCursor(*after, order)
+		if err != nil {
+			return models.PageParams{}, err
+		}
+		page.After = cursor
+	}
+
+	return page, nil
+}
+
+func newPostConnection(page models.PostPage) *PostConnection {
+	conn := &PostConnection{
+		Edges:      make([]*PostEdge, len(page.Posts)),
+		PageInfo:   &PageInfo{HasNextPage: page.HasNextPage},
+		TotalCount: page.TotalCount,
+	}
+
+	for i := range page.Posts {
+		conn.Edges[i] = &PostEdge{
+			Cursor: encodeCursor(postsOrder, page.Posts[i].Cursor()),
+			Node:   &page.Posts[i],
+		}
+	}
+
+	if n := len(conn.Edges); n > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[n-1].Cursor
+	}
+
+	return conn
+}
+
+func newCommentConnection(page models.CommentPage, order cursorOrder) *CommentConnection {
+	conn := &CommentConnection{
+		Edges:      make([]*CommentEdge, len(page.Comments)),
+		PageInfo:   &PageInfo{HasNextPage: page.HasNextPage},
+		TotalCount: page.TotalCount,
+	}
+
+	for i := range page.Comments {
+		conn.Edges[i] = &CommentEdge{
+			Cursor: encodeCursor(order, page.Cursors[i]),
+			Node:   &page.Comments[i],
+		}
+	}
+
+	if n := len(conn.Edges); n > 0 {
+		conn.PageInfo.EndCursor = &conn.Edges[n-1].Cursor
+	}
+
+	return conn
+}
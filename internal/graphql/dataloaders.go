@@ -0,0 +1,15 @@
+package graphql
+
+import (
+	"Habr-comments-server/internal/graphql/loaders"
+	"context"
+)
+
+// dataloaders возвращает Loaders текущего запроса. Для подписок по WebSocket
+// их нет в контексте, и каждое событие получает свои, без общего кеша.
+func (r *Resolver) dataloaders(ctx context.Context) *loaders.Loaders {
+	if l := loaders.For(ctx); l != nil {
+		return l
+	}
+	return loaders.NewLoaders(ctx, r.Service)
+}
@@ -0,0 +1,113 @@
+package graphql
+
+import (
+	"Habr-comments-server/internal/auth"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/service"
+	"Habr-comments-server/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	gql "github.com/99designs/gqlgen/graphql"
+)
+
+// NewDirectiveRoot возвращает реализации директив @hasRole и @isOwner из schema.graphql.
+func NewDirectiveRoot(svc *service.Service) DirectiveRoot {
+	d := &directives{svc: svc}
+	return DirectiveRoot{
+		HasRole: d.hasRole,
+		IsOwner: d.isOwner,
+	}
+}
+
+type directives struct {
+	svc *service.Service
+}
+
+// hasRole пропускает только пользователей с ролью не ниже role.
+func (d *directives) hasRole(ctx context.Context, obj any, next gql.Resolver, role Role) (any, error) {
+	viewer, err := d.viewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !viewer.Role.AtLeast(modelRole(role)) {
+		return nil, newClientError(auth.ErrForbidden, "role %s required", role)
+	}
+
+	return next(ctx)
+}
+
+// isOwner пропускает автора ресурса, ID которого передан в аргументе idArg, а также модераторов и администраторов.
+func (d *directives) isOwner(ctx context.Context, obj any, next gql.Resolver, resource OwnedResource, idArg string) (any, error) {
+	viewer, err := d.viewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if viewer.IsStaff() {
+		return next(ctx)
+	}
+
+	rawID, ok := gql.GetFieldContext(ctx).Args[idArg].(string)
+	if !ok {
+		return nil, fmt.Errorf("@isOwner: field has no ID argument %q", idArg)
+	}
+
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		return nil, invalidInput("invalid %s %q", idArg, rawID)
+	}
+
+	var authorID int
+	switch resource {
+	case OwnedResourcePost:
+		post, err := d.svc.PostService.GetPost(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		authorID = post.AuthorId
+	case OwnedResourceComment:
+		comment, err := d.svc.CommentService.GetComment(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		authorID = comment.AuthorId
+	default:
+		return nil, fmt.Errorf("@isOwner: unknown resource %s", resource)
+	}
+
+	if authorID != viewer.ID {
+		return nil, newClientError(auth.ErrForbidden, "only the author of the %s can do this", strings.ToLower(string(resource)))
+	}
+
+	return next(ctx)
+}
+
+// viewer загружает текущего пользователя: роль берется из хранилища, а не из токена,
+// чтобы смена роли действовала сразу.
+func (d *directives) viewer(ctx context.Context) (models.User, error) {
+	userID, ok := viewerID(ctx)
+	if !ok {
+		return models.User{}, auth.ErrUnauthenticated
+	}
+
+	// Токен подписан верно, но пользователь удален: для клиента это тот же вход без учетной записи
+	viewer, err := d.svc.UserService.GetUser(ctx, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.User{}, auth.ErrUnauthenticated
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to load current user: %w", err)
+	}
+
+	return viewer, nil
+}
+
+// modelRole переводит роль из GraphQL-перечисления в значение, которое хранится в БД.
+func modelRole(role Role) models.Role {
+	return models.Role(strings.ToLower(string(role)))
+}
@@ -0,0 +1,116 @@
+package graphql
+
+import (
+	"Habr-comments-server/internal/auth"
+	"Habr-comments-server/internal/storage"
+	"Habr-comments-server/internal/validation"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	gql "github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Значения extensions.code в ответе
+const (
+	codeNotFound        = "NOT_FOUND"
+	codeAlreadyExists   = "ALREADY_EXISTS"
+	codeCommentsLocked  = "COMMENTS_LOCKED"
+	codeValidation      = "VALIDATION_FAILED"
+	codeUnauthenticated = "UNAUTHENTICATED"
+	codeForbidden       = "FORBIDDEN"
+	codeInternal        = "INTERNAL_SERVER_ERROR"
+)
+
+// errorCodes сопоставляет ошибкам-сигналам коды и сообщения, которые показываются вместо внутренних.
+var errorCodes = []struct {
+	err     error
+	code    string
+	message string
+}{
+	{storage.ErrNotFound, codeNotFound, "not found"},
+	{storage.ErrAlreadyExists, codeAlreadyExists, "already exists"},
+	{storage.ErrCommentsBlock, codeCommentsLocked, "comments are locked for this post"},
+	{storage.ErrValidation, codeValidation, "invalid input"},
+	{auth.ErrUnauthenticated, codeUnauthenticated, "authentication required"},
+	{auth.ErrForbidden, codeForbidden, "forbidden"},
+}
+
+// clientError — ошибка, сообщение которой можно показать клиенту как есть.
+// kind определяет код ошибки в ответе.
+type clientError struct {
+	kind    error
+	message string
+}
+
+func (e *clientError) Error() string { return e.message }
+
+func (e *clientError) Unwrap() error { return e.kind }
+
+func newClientError(kind error, format string, args ...any) error {
+	return &clientError{kind: kind, message: fmt.Sprintf(format, args...)}
+}
+
+// invalidInput сообщает клиенту о некорректном аргументе.
+func invalidInput(format string, args ...any) error {
+	return newClientError(storage.ErrValidation, format, args...)
+}
+
+// NewErrorPresenter проставляет extensions.code по ошибкам хранилища и авторизации.
+// При hideInternal (prod) клиент видит только сообщения клиентских ошибок и общие сообщения
+// для остальных: подробности с путями вызова и текстом ошибок БД уходят только в лог.
+func NewErrorPresenter(log *slog.Logger, hideInternal bool) gql.ErrorPresenterFunc {
+	return func(ctx context.Context, err error) *gqlerror.Error {
+		gqlErr := gql.DefaultErrorPresenter(ctx, err)
+
+		// Ошибки разбора запроса и лимитов уже содержат код
+		if _, ok := gqlErr.Extensions["code"]; ok {
+			return gqlErr
+		}
+
+		code, message := classify(err)
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = make(map[string]interface{})
+		}
+		gqlErr.Extensions["code"] = code
+
+		var clientErr *clientError
+		var validationErr *validation.Error
+		switch {
+		case errors.As(err, &validationErr):
+			gqlErr.Message = validationErr.Error()
+			gqlErr.Extensions["fields"] = validationErr.Fields // Ошибки по каждому аргументу
+		case errors.As(err, &clientErr):
+			gqlErr.Message = clientErr.message
+		case code == codeInternal:
+			log.Error("GraphQL resolver failed", slog.String("path", gqlErr.Path.String()), slog.Any("error", err))
+			if hideInternal {
+				gqlErr.Message = message
+			}
+		case hideInternal:
+			gqlErr.Message = message
+		}
+
+		return gqlErr
+	}
+}
+
+// classify возвращает код ошибки и сообщение для клиента.
+func classify(err error) (string, string) {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code, c.message
+		}
+	}
+
+	// Некорректный ID в аргументах
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return codeValidation, "invalid ID"
+	}
+
+	return codeInternal, "internal server error"
+}
@@ -9,10 +9,10 @@ import (
 	"Habr-comments-server/internal/models"
 )
 
-// CommentLoaderConfig captures the config to create a new CommentLoader
-type CommentLoaderConfig struct {
+// CommentByIDLoaderConfig captures the config to create a new CommentByIDLoader
+type CommentByIDLoaderConfig struct {
 	// Fetch is a method that provides the data for the loader
-	Fetch func(keys []int) ([][]*models.Comment, []error)
+	Fetch func(keys []int) ([]*models.Comment, []error)
 
 	// Wait is how long wait before sending a batch
 	Wait time.Duration
@@ -21,19 +21,19 @@ type CommentLoaderConfig struct {
 	MaxBatch int
 }
 
-// NewCommentLoader creates a new CommentLoader given a fetch, wait, and maxBatch
-func NewCommentLoader(config CommentLoaderConfig) *CommentLoader {
-	return &CommentLoader{
+// NewCommentByIDLoader creates a new CommentByIDLoader given a fetch, wait, and maxBatch
+func NewCommentByIDLoader(config CommentByIDLoaderConfig) *CommentByIDLoader {
+	return &CommentByIDLoader{
 		fetch:    config.Fetch,
 		wait:     config.Wait,
 		maxBatch: config.MaxBatch,
 	}
 }
 
-// CommentLoader batches and caches requests
-type CommentLoader struct {
+// CommentByIDLoader batches and caches requests
+type CommentByIDLoader struct {
 	// this method provides the data for the loader
-	fetch func(keys []int) ([][]*models.Comment, []error)
+	fetch func(keys []int) ([]*models.Comment, []error)
 
 	// how long to done before sending a batch
 	wait time.Duration
@@ -44,51 +44,51 @@ type CommentLoader struct {
 	// INTERNAL
 
 	// lazily created cache
-	cache map[int][]*models.Comment
+	cache map[int]*models.Comment
 
 	// the current batch. keys will continue to be collected until timeout is hit,
 	// then everything will be sent to the fetch method and out to the listeners
-	batch *commentLoaderBatch
+	batch *commentByIDLoaderBatch
 
 	// mutex to prevent races
 	mu sync.Mutex
 }
 
-type commentLoaderBatch struct {
+type commentByIDLoaderBatch struct {
 	keys    []int
-	data    [][]*models.Comment
+	data    []*models.Comment
 	error   []error
 	closing bool
 	done    chan struct{}
 }
 
 // Load a Comment by key, batching and caching will be applied automatically
-func (l *CommentLoader) Load(key int) ([]*models.Comment, error) {
+func (l *CommentByIDLoader) Load(key int) (*models.Comment, error) {
 	return l.LoadThunk(key)()
 }
 
 // LoadThunk returns a function that when called will block waiting for a Comment.
 // This method should be used if you want one goroutine to make requests to many
 // different data loaders without blocking until the thunk is called.
-func (l *CommentLoader) LoadThunk(key int) func() ([]*models.Comment, error) {
+func (l *CommentByIDLoader) LoadThunk(key int) func() (*models.Comment, error) {
 	l.mu.Lock()
 	if it, ok := l.cache[key]; ok {
 		l.mu.Unlock()
-		return func() ([]*models.Comment, error) {
+		return func() (*models.Comment, error) {
 			return it, nil
 		}
 	}
 	if l.batch == nil {
-		l.batch = &commentLoaderBatch{done: make(chan struct{})}
+		l.batch = &commentByIDLoaderBatch{done: make(chan struct{})}
 	}
 	batch := l.batch
 	pos := batch.keyIndex(l, key)
 	l.mu.Unlock()
 
-	return func() ([]*models.Comment, error) {
+	return func() (*models.Comment, error) {
 		<-batch.done
 
-		var data []*models.Comment
+		var data *models.Comment
 		if pos < len(batch.data) {
 			data = batch.data[pos]
 		}
@@ -113,14 +113,14 @@ func (l *CommentLoader) LoadThunk(key int) func() ([]*models.Comment, error) {
 
 // LoadAll fetches many keys at once. It will be broken into appropriate sized
 // sub batches depending on how the loader is configured
-func (l *CommentLoader) LoadAll(keys []int) ([][]*models.Comment, []error) {
-	results := make([]func() ([]*models.Comment, error), len(keys))
+func (l *CommentByIDLoader) LoadAll(keys []int) ([]*models.Comment, []error) {
+	results := make([]func() (*models.Comment, error), len(keys))
 
 	for i, key := range keys {
 		results[i] = l.LoadThunk(key)
 	}
 
-	comments := make([][]*models.Comment, len(keys))
+	comments := make([]*models.Comment, len(keys))
 	errors := make([]error, len(keys))
 	for i, thunk := range results {
 		comments[i], errors[i] = thunk()
@@ -131,13 +131,13 @@ func (l *CommentLoader) LoadAll(keys []int) ([][]*models.Comment, []error) {
 // LoadAllThunk returns a function that when called will block waiting for a Comments.
 // This method should be used if you want one goroutine to make requests to many
 // different data loaders without blocking until the thunk is called.
-func (l *CommentLoader) LoadAllThunk(keys []int) func() ([][]*models.Comment, []error) {
-	results := make([]func() ([]*models.Comment, error), len(keys))
+func (l *CommentByIDLoader) LoadAllThunk(keys []int) func() ([]*models.Comment, []error) {
+	results := make([]func() (*models.Comment, error), len(keys))
 	for i, key := range keys {
 		results[i] = l.LoadThunk(key)
 	}
-	return func() ([][]*models.Comment, []error) {
-		comments := make([][]*models.Comment, len(keys))
+	return func() ([]*models.Comment, []error) {
+		comments := make([]*models.Comment, len(keys))
 		errors := make([]error, len(keys))
 		for i, thunk := range results {
 			comments[i], errors[i] = thunk()
@@ -149,37 +149,36 @@ func (l *CommentLoader) LoadAllThunk(keys []int) func() ([][]*models.Comment, []
 // Prime the cache with the provided key and value. If the key already exists, no change is made
 // and false is returned.
 // (To forcefully prime the cache, clear the key first with loader.clear(key).prime(key, value).)
-func (l *CommentLoader) Prime(key int, value []*models.Comment) bool {
+func (l *CommentByIDLoader) Prime(key int, value *models.Comment) bool {
 	l.mu.Lock()
 	var found bool
 	if _, found = l.cache[key]; !found {
 		// make a copy when writing to the cache, its easy to pass a pointer in from a loop var
 		// and end up with the whole cache pointing to the same value.
-		cpy := make([]*models.Comment, len(value))
-		copy(cpy, value)
-		l.unsafeSet(key, cpy)
+		cpy := *value
+		l.unsafeSet(key, &cpy)
 	}
 	l.mu.Unlock()
 	return !found
 }
 
 // Clear the value at key from the cache, if it exists
-func (l *CommentLoader) Clear(key int) {
+func (l *CommentByIDLoader) Clear(key int) {
 	l.mu.Lock()
 	delete(l.cache, key)
 	l.mu.Unlock()
 }
 
-func (l *CommentLoader) unsafeSet(key int, value []*models.Comment) {
+func (l *CommentByIDLoader) unsafeSet(key int, value *models.Comment) {
 	if l.cache == nil {
-		l.cache = map[int][]*models.Comment{}
+		l.cache = map[int]*models.Comment{}
 	}
 	l.cache[key] = value
 }
 
 // keyIndex will return the location of the key in the batch, if its not found
 // it will add the key to the batch
-func (b *commentLoaderBatch) keyIndex(l *CommentLoader, key int) int {
+func (b *commentByIDLoaderBatch) keyIndex(l *CommentByIDLoader, key int) int {
 	for i, existingKey := range b.keys {
 		if key == existingKey {
 			return i
@@ -203,7 +202,7 @@ func (b *commentLoaderBatch) keyIndex(l *CommentLoader, key int) int {
 	return pos
 }
 
-func (b *commentLoaderBatch) startTimer(l *CommentLoader) {
+func (b *commentByIDLoaderBatch) startTimer(l *CommentByIDLoader) {
 	time.Sleep(l.wait)
 	l.mu.Lock()
 
@@ -219,7 +218,7 @@ func (b *commentLoaderBatch) startTimer(l *CommentLoader) {
 	b.end(l)
 }
 
-func (b *commentLoaderBatch) end(l *CommentLoader) {
+func (b *commentByIDLoaderBatch) end(l *CommentByIDLoader) {
 	b.data, b.error = l.fetch(b.keys)
 	close(b.done)
 }
@@ -0,0 +1,221 @@
+// Code generated by github.com/vektah/dataloaden, DO NOT EDIT.
+
+package loaders
+
+import (
+	"sync"
+	"time"
+
+	"Habr-comments-server/internal/models"
+)
+
+// CommentStatsLoaderConfig captures the config to create a new CommentStatsLoader
+type CommentStatsLoaderConfig struct {
+	// Fetch is a method that provides the data for the loader
+	Fetch func(keys []int) ([]models.CommentStats, []error)
+
+	// Wait is how long wait before sending a batch
+	Wait time.Duration
+
+	// MaxBatch will limit the maximum number of keys to send in one batch, 0 = not limit
+	MaxBatch int
+}
+
+// NewCommentStatsLoader creates a new CommentStatsLoader given a fetch, wait, and maxBatch
+func NewCommentStatsLoader(config CommentStatsLoaderConfig) *CommentStatsLoader {
+	return &CommentStatsLoader{
+		fetch:    config.Fetch,
+		wait:     config.Wait,
+		maxBatch: config.MaxBatch,
+	}
+}
+
+// CommentStatsLoader batches and caches requests
+type CommentStatsLoader struct {
+	// this method provides the data for the loader
+	fetch func(keys []int) ([]models.CommentStats, []error)
+
+	// how long to done before sending a batch
+	wait time.Duration
+
+	// this will limit the maximum number of keys to send in one batch, 0 = no limit
+	maxBatch int
+
+	// INTERNAL
+
+	// lazily created cache
+	cache map[int]models.CommentStats
+
+	// the current batch. keys will continue to be collected until timeout is hit,
+	// then everything will be sent to the fetch method and out to the listeners
+	batch *commentStatsLoaderBatch
+
+	// mutex to prevent races
+	mu sync.Mutex
+}
+
+type commentStatsLoaderBatch struct {
+	keys    []int
+	data    []models.CommentStats
+	error   []error
+	closing bool
+	done    chan struct{}
+}
+
+// Load a CommentStats by key, batching and caching will be applied automatically
+func (l *CommentStatsLoader) Load(key int) (models.CommentStats, error) {
+	return l.LoadThunk(key)()
+}
+
+// LoadThunk returns a function that when called will block waiting for a CommentStats.
+// This method should be used if you want one goroutine to make requests to many
+// different data loaders without blocking until the thunk is called.
+func (l *CommentStatsLoader) LoadThunk(key int) func() (models.CommentStats, error) {
+	l.mu.Lock()
+	if it, ok := l.cache[key]; ok {
+		l.mu.Unlock()
+		return func() (models.CommentStats, error) {
+			return it, nil
+		}
+	}
+	if l.batch == nil {
+		l.batch = &commentStatsLoaderBatch{done: make(chan struct{})}
+	}
+	batch := l.batch
+	pos := batch.keyIndex(l, key)
+	l.mu.Unlock()
+
+	return func() (models.CommentStats, error) {
+		<-batch.done
+
+		var data models.CommentStats
+		if pos < len(batch.data) {
+			data = batch.data[pos]
+		}
+
+		var err error
+		// its convenient to be able to return a single error for everything
+		if len(batch.error) == 1 {
+			err = batch.error[0]
+		} else if batch.error != nil {
+			err = batch.error[pos]
+		}
+
+		if err == nil {
+			l.mu.Lock()
+			l.unsafeSet(key, data)
+			l.mu.Unlock()
+		}
+
+		return data, err
+	}
+}
+
+// LoadAll fetches many keys at once. It will be broken into appropriate sized
+// sub batches depending on how the loader is configured
+func (l *CommentStatsLoader) LoadAll(keys []int) ([]models.CommentStats, []error) {
+	results := make([]func() (models.CommentStats, error), len(keys))
+
+	for i, key := range keys {
+		results[i] = l.LoadThunk(key)
+	}
+
+	commentStatss := make([]models.CommentStats, len(keys))
+	errors := make([]error, len(keys))
+	for i, thunk := range results {
+		commentStatss[i], errors[i] = thunk()
+	}
+	return commentStatss, errors
+}
+
+// LoadAllThunk returns a function that when called will block waiting for a CommentStatss.
+// This method should be used if you want one goroutine to make requests to many
+// different data loaders without blocking until the thunk is called.
+func (l *CommentStatsLoader) LoadAllThunk(keys []int) func() ([]models.CommentStats, []error) {
+	results := make([]func() (models.CommentStats, error), len(keys))
+	for i, key := range keys {
+		results[i] = l.LoadThunk(key)
+	}
+	return func() ([]models.CommentStats, []error) {
+		commentStatss := make([]models.CommentStats, len(keys))
+		errors := make([]error, len(keys))
+		for i, thunk := range results {
+			commentStatss[i], errors[i] = thunk()
+		}
+		return commentStatss, errors
+	}
+}
+
+// Prime the cache with the provided key and value. If the key already exists, no change is made
+// and false is returned.
+// (To forcefully prime the cache, clear the key first with loader.clear(key).prime(key, value).)
+func (l *CommentStatsLoader) Prime(key int, value models.CommentStats) bool {
+	l.mu.Lock()
+	var found bool
+	if _, found = l.cache[key]; !found {
+		l.unsafeSet(key, value)
+	}
+	l.mu.Unlock()
+	return !found
+}
+
+// Clear the value at key from the cache, if it exists
+func (l *CommentStatsLoader) Clear(key int) {
+	l.mu.Lock()
+	delete(l.cache, key)
+	l.mu.Unlock()
+}
+
+func (l *CommentStatsLoader) unsafeSet(key int, value models.CommentStats) {
+	if l.cache == nil {
+		l.cache = map[int]models.CommentStats{}
+	}
+	l.cache[key] = value
+}
+
+// keyIndex will return the location of the key in the batch, if its not found
+// it will add the key to the batch
+func (b *commentStatsLoaderBatch) keyIndex(l *CommentStatsLoader, key int) int {
+	for i, existingKey := range b.keys {
+		if key == existingKey {
+			return i
+		}
+	}
+
+	pos := len(b.keys)
+	b.keys = append(b.keys, key)
+	if pos == 0 {
+		go b.startTimer(l)
+	}
+
+	if l.maxBatch != 0 && pos >= l.maxBatch-1 {
+		if !b.closing {
+			b.closing = true
+			l.batch = nil
+			go b.end(l)
+		}
+	}
+
+	return pos
+}
+
+func (b *commentStatsLoaderBatch) startTimer(l *CommentStatsLoader) {
+	time.Sleep(l.wait)
+	l.mu.Lock()
+
+	// we must have hit a batch limit and are already finalizing this batch
+	if b.closing {
+		l.mu.Unlock()
+		return
+	}
+
+	l.batch = nil
+	l.mu.Unlock()
+
+	b.end(l)
+}
+
+func (b *commentStatsLoaderBatch) end(l *CommentStatsLoader) {
+	b.data, b.error = l.fetch(b.keys)
+	close(b.done)
+}
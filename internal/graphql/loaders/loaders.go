@@ -4,67 +4,314 @@ import (
 	"Habr-comments-server/internal/models"
 	"Habr-comments-server/internal/service"
 	"context"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
 type Loaders struct {
-	UserLoader         *UserLoader
-	CommentLoader      *CommentLoader
-	ChildCommentLoader *ChildCommentLoader
+	UserLoader        *UserLoader
+	PostByIDLoader    *PostByIDLoader
+	CommentByIDLoader *CommentByIDLoader
+
+	PostStatsLoader    *PostStatsLoader
+	CommentStatsLoader *CommentStatsLoader
+
+	ctx context.Context
+	svc *service.Service
+
+	// Страничные лоадеры создаются лениво, по одному на набор аргументов поля:
+	// в одну пачку попадают только запросы с одинаковыми sort/first/after.
+	mu            sync.Mutex
+	rootComments  map[pageKey]*CommentPageLoader
+	childComments map[pageKey]*CommentPageLoader
+	userPosts     map[pageKey]*PostPageLoader
+	userComments  map[pageKey]*CommentPageLoader
+	votes         map[int]*VoteLoader // ID пользователя -> его голоса
+}
+
+// pageKey — аргументы страницы, для которых создан лоадер.
+type pageKey struct {
+	sort     models.CommentSort
+	first    int
+	after    models.Cursor
+	hasAfter bool
+}
+
+func newPageKey(sort models.CommentSort, page models.PageParams) pageKey {
+	key := pageKey{sort: sort, first: page.First}
+	if page.After != nil {
+		key.after, key.hasAfter = *page.After, true
+	}
+	return key
+}
+
+func (k pageKey) page() models.PageParams {
+	page := models.PageParams{First: k.first}
+	if k.hasAfter {
+		after := k.after
+		page.After = &after
+	}
+	return page
 }
 
-// Функция для инициализации всех загрузчиков
-func NewLoaders(svc *service.Service) *Loaders {
+// ctxKey — ключ Loaders в контексте запроса.
+type ctxKey struct{}
+
+// Функция для инициализации всех загрузчиков.
+// Загрузчики кешируют результаты, поэтому создаются на каждый запрос;
+// ctx запроса передается в хранилище, чтобы отмена и дедлайны доходили до БД.
+func NewLoaders(ctx context.Context, svc *service.Service) *Loaders {
 	return &Loaders{
 		// Лоадер для пользователей
 		UserLoader: &UserLoader{
 			wait:     2 * time.Millisecond,
 			maxBatch: 100,
 			fetch: func(keys []int) ([]*models.User, []error) {
-				users, err := svc.PostService.GetUsersByID(context.Background(), keys)
+				users, err := svc.UserService.GetUsersByID(ctx, keys)
 				if err != nil {
-					errors := make([]error, len(keys))
-					for i := range keys {
-						errors[i] = err
-					}
-					return nil, errors
+					return nil, fetchErrors(keys, err)
 				}
 				return users, nil
 			},
 		},
-		// Лоадер для комментариев по ID постов
-		CommentLoader: &CommentLoader{
-			wait:     5 * time.Millisecond,
-			maxBatch: 50,
-			fetch: func(keys []int) ([][]*models.Comment, []error) {
-				comments, err := svc.CommentService.GetCommentsByPostID(context.Background(), keys)
+		// Лоадер для постов по ID
+		PostByIDLoader: &PostByIDLoader{
+			wait:     2 * time.Millisecond,
+			maxBatch: 100,
+			fetch: func(keys []int) ([]*models.Post, []error) {
+				posts, err := svc.PostService.GetPostsByID(ctx, keys)
 				if err != nil {
-					errors := make([]error, len(keys))
-					for i := range keys {
-						errors[i] = err
-					}
-					return nil, errors
+					return nil, fetchErrors(keys, err)
 				}
-				return comments, nil
+				return posts, nil
 			},
 		},
-
-		// Лоадер для дочерних комментариев по ID родительских комментариев
-		ChildCommentLoader: &ChildCommentLoader{
-			wait:     5 * time.Millisecond,
-			maxBatch: 50,
-			fetch: func(keys []int) ([][]*models.Comment, []error) {
-				comments, err := svc.CommentService.GetChildCommentsByParentID(context.Background(), keys)
+		// Лоадер для комментариев по ID (родитель, предки)
+		CommentByIDLoader: &CommentByIDLoader{
+			wait:     2 * time.Millisecond,
+			maxBatch: 100,
+			fetch: func(keys []int) ([]*models.Comment, []error) {
+				comments, err := svc.CommentService.GetCommentsByID(ctx, keys)
 				if err != nil {
-					errors := make([]error, len(keys))
-					for i := range keys {
-						errors[i] = err
-					}
-					return nil, errors
+					return nil, fetchErrors(keys, err)
 				}
-
 				return comments, nil
 			},
 		},
+		// Лоадер счетчиков комментариев поста
+		PostStatsLoader: &PostStatsLoader{
+			wait:     2 * time.Millisecond,
+			maxBatch: 100,
+			fetch: func(keys []int) ([]models.PostStats, []error) {
+				stats, err := svc.PostService.GetPostStats(ctx, keys)
+				if err != nil {
+					return nil, fetchErrors(keys, err)
+				}
+				return stats, nil
+			},
+		},
+		// Лоадер счетчиков ответов комментария
+		CommentStatsLoader: &CommentStatsLoader{
+			wait:     2 * time.Millisecond,
+			maxBatch: 100,
+			fetch: func(keys []int) ([]models.CommentStats, []error) {
+				stats, err := svc.CommentService.GetCommentStats(ctx, keys)
+				if err != nil {
+					return nil, fetchErrors(keys, err)
+				}
+				return stats, nil
+			},
+		},
+
+		ctx:           ctx,
+		svc:           svc,
+		rootComments:  make(map[pageKey]*CommentPageLoader),
+		childComments: make(map[pageKey]*CommentPageLoader),
+		userPosts:     make(map[pageKey]*PostPageLoader),
+		userComments:  make(map[pageKey]*CommentPageLoader),
+		votes:         make(map[int]*VoteLoader),
+	}
+}
+
+// RootComments — лоадер страниц корневых комментариев по ID поста.
+func (l *Loaders) RootComments(sort models.CommentSort, page models.PageParams) *CommentPageLoader {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return commentPageLoader(l.rootComments, newPageKey(sort, page), func(keys []int, key pageKey) ([]models.CommentPage, error) {
+		return l.svc.CommentService.GetRootCommentPages(l.ctx, keys, key.sort, key.page())
+	})
+}
+
+// ChildComments — лоадер страниц ответов по ID родительского комментария.
+func (l *Loaders) ChildComments(sort models.CommentSort, page models.PageParams) *CommentPageLoader {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return commentPageLoader(l.childComments, newPageKey(sort, page), func(keys []int, key pageKey) ([]models.CommentPage, error) {
+		return l.svc.CommentService.GetChildCommentPages(l.ctx, keys, key.sort, key.page())
+	})
+}
+
+// UserComments — лоадер страниц комментариев по ID автора.
+func (l *Loaders) UserComments(page models.PageParams) *CommentPageLoader {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return commentPageLoader(l.userComments, newPageKey(models.SortNewest, page), func(keys []int, key pageKey) ([]models.CommentPage, error) {
+		return l.svc.UserService.GetUserCommentPages(l.ctx, keys, key.page())
+	})
+}
+
+// UserPosts — лоадер страниц постов по ID автора.
+func (l *Loaders) UserPosts(page models.PageParams) *PostPageLoader {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := newPageKey("", page)
+	if loader, ok := l.userPosts[key]; ok {
+		return loader
+	}
+
+	loader := &PostPageLoader{
+		wait:     5 * time.Millisecond,
+		maxBatch: 50,
+		fetch: func(keys []int) ([]models.PostPage, []error) {
+			pages, err := l.svc.UserService.GetUserPostPages(l.ctx, keys, key.page())
+			if err != nil {
+				return nil, fetchErrors(keys, err)
+			}
+			return pages, nil
+		},
+	}
+	l.userPosts[key] = loader
+	return loader
+}
+
+// Votes — лоадер голосов пользователя по ID комментария (0 — голоса нет).
+func (l *Loaders) Votes(userID int) *VoteLoader {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if loader, ok := l.votes[userID]; ok {
+		return loader
+	}
+
+	loader := &VoteLoader{
+		wait:     2 * time.Millisecond,
+		maxBatch: 100,
+		fetch: func(keys []int) ([]int, []error) {
+			votes, err := l.svc.CommentService.GetUserVotes(l.ctx, userID, keys)
+			if err != nil {
+				return nil, fetchErrors(keys, err)
+			}
+			return votes, nil
+		},
+	}
+	l.votes[userID] = loader
+	return loader
+}
+
+// commentPageLoader возвращает лоадер из loaders для key, создавая его при первом обращении.
+// Вызывается под l.mu.
+func commentPageLoader(loaders map[pageKey]*CommentPageLoader, key pageKey, fetch func(keys []int, key pageKey) ([]models.CommentPage, error)) *CommentPageLoader {
+	if loader, ok := loaders[key]; ok {
+		return loader
+	}
+
+	loader := &CommentPageLoader{
+		wait:     5 * time.Millisecond,
+		maxBatch: 50,
+		fetch: func(keys []int) ([]models.CommentPage, []error) {
+			pages, err := fetch(keys, key)
+			if err != nil {
+				return nil, fetchErrors(keys, err)
+			}
+			return pages, nil
+		},
+	}
+	loaders[key] = loader
+	return loader
+}
+
+// fetchErrors возвращает одну и ту же ошибку для каждого ключа пачки.
+func fetchErrors(keys []int, err error) []error {
+	errors := make([]error, len(keys))
+	for i := range keys {
+		errors[i] = err
+	}
+	return errors
+}
+
+// Middleware создает новые Loaders для каждого HTTP-запроса и кладет их в контекст.
+// WebSocket-соединение живет долго, и кеш на все соединение отдавал бы подпискам
+// устаревшие данные, поэтому для него Loaders не создаются.
+func Middleware(svc *service.Service, next http.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		next.ServeHTTP(w, r.WithContext(WithLoaders(ctx, NewLoaders(ctx, svc))))
+	})
+}
+
+// WithLoaders кладет Loaders в контекст.
+func WithLoaders(ctx context.Context, l *Loaders) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// For возвращает Loaders текущего запроса или nil, если их нет в контексте.
+func For(ctx context.Context) *Loaders {
+	l, _ := ctx.Value(ctxKey{}).(*Loaders)
+	return l
+}
+
+// PrimeUser заменяет пользователя в кеше, например после смены роли.
+func (l *Loaders) PrimeUser(user *models.User) {
+	l.UserLoader.Clear(user.ID)
+	l.UserLoader.Prime(user.ID, user)
+}
+
+// PrimePost заменяет пост в кеше после изменения.
+func (l *Loaders) PrimePost(post *models.Post) {
+	l.PostByIDLoader.Clear(post.ID)
+	l.PostByIDLoader.Prime(post.ID, post)
+}
+
+// ClearComment заменяет комментарий в кеше и сбрасывает закешированные страницы,
+// в которые он входит, чтобы после мутации в том же запросе читались свежие данные.
+func (l *Loaders) ClearComment(comment *models.Comment) {
+	l.CommentByIDLoader.Clear(comment.ID)
+	l.CommentByIDLoader.Prime(comment.ID, comment)
+
+	// Счетчики предков выше родителя остаются в кеше: в одном запросе
+	// их читают после собственной мутации крайне редко.
+	l.PostStatsLoader.Clear(comment.PostId)
+	l.CommentStatsLoader.Clear(comment.ID)
+	if comment.ParentId != nil {
+		l.CommentStatsLoader.Clear(*comment.ParentId)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, loader := range l.rootComments {
+		loader.Clear(comment.PostId)
+	}
+	if comment.ParentId != nil {
+		for _, loader := range l.childComments {
+			loader.Clear(*comment.ParentId)
+		}
+	}
+	for _, loader := range l.userComments {
+		loader.Clear(comment.AuthorId)
+	}
+	for _, loader := range l.votes {
+		loader.Clear(comment.ID)
 	}
 }
@@ -9,10 +9,10 @@ import (
 	"Habr-comments-server/internal/models"
 )
 
-// ChildCommentLoaderConfig captures the config to create a new ChildCommentLoader
-type ChildCommentLoaderConfig struct {
+// PostByIDLoaderConfig captures the config to create a new PostByIDLoader
+type PostByIDLoaderConfig struct {
 	// Fetch is a method that provides the data for the loader
-	Fetch func(keys []int) ([][]*models.Comment, []error)
+	Fetch func(keys []int) ([]*models.Post, []error)
 
 	// Wait is how long wait before sending a batch
 	Wait time.Duration
@@ -21,19 +21,19 @@ type ChildCommentLoaderConfig struct {
 	MaxBatch int
 }
 
-// NewChildCommentLoader creates a new ChildCommentLoader given a fetch, wait, and maxBatch
-func NewChildCommentLoader(config ChildCommentLoaderConfig) *ChildCommentLoader {
-	return &ChildCommentLoader{
+// NewPostByIDLoader creates a new PostByIDLoader given a fetch, wait, and maxBatch
+func NewPostByIDLoader(config PostByIDLoaderConfig) *PostByIDLoader {
+	return &PostByIDLoader{
 		fetch:    config.Fetch,
 		wait:     config.Wait,
 		maxBatch: config.MaxBatch,
 	}
 }
 
-// ChildCommentLoader batches and caches requests
-type ChildCommentLoader struct {
+// PostByIDLoader batches and caches requests
+type PostByIDLoader struct {
 	// this method provides the data for the loader
-	fetch func(keys []int) ([][]*models.Comment, []error)
+	fetch func(keys []int) ([]*models.Post, []error)
 
 	// how long to done before sending a batch
 	wait time.Duration
@@ -44,51 +44,51 @@ type ChildCommentLoader struct {
 	// INTERNAL
 
 	// lazily created cache
-	cache map[int][]*models.Comment
+	cache map[int]*models.Post
 
 	// the current batch. keys will continue to be collected until timeout is hit,
 	// then everything will be sent to the fetch method and out to the listeners
-	batch *childCommentLoaderBatch
+	batch *postByIDLoaderBatch
 
 	// mutex to prevent races
 	mu sync.Mutex
 }
 
-type childCommentLoaderBatch struct {
+type postByIDLoaderBatch struct {
 	keys    []int
-	data    [][]*models.Comment
+	data    []*models.Post
 	error   []error
 	closing bool
 	done    chan struct{}
 }
 
-// Load a Comment by key, batching and caching will be applied automatically
-func (l *ChildCommentLoader) Load(key int) ([]*models.Comment, error) {
+// Load a Post by key, batching and caching will be applied automatically
+func (l *PostByIDLoader) Load(key int) (*models.Post, error) {
 	return l.LoadThunk(key)()
 }
 
-// LoadThunk returns a function that when called will block waiting for a Comment.
+// LoadThunk returns a function that when called will block waiting for a Post.
 // This method should be used if you want one goroutine to make requests to many
 // different data loaders without blocking until the thunk is called.
-func (l *ChildCommentLoader) LoadThunk(key int) func() ([]*models.Comment, error) {
+func (l *PostByIDLoader) LoadThunk(key int) func() (*models.Post, error) {
 	l.mu.Lock()
 	if it, ok := l.cache[key]; ok {
 		l.mu.Unlock()
-		return func() ([]*models.Comment, error) {
+		return func() (*models.Post, error) {
 			return it, nil
 		}
 	}
 	if l.batch == nil {
-		l.batch = &childCommentLoaderBatch{done: make(chan struct{})}
+		l.batch = &postByIDLoaderBatch{done: make(chan struct{})}
 	}
 	batch := l.batch
 	pos := batch.keyIndex(l, key)
 	l.mu.Unlock()
 
-	return func() ([]*models.Comment, error) {
+	return func() (*models.Post, error) {
 		<-batch.done
 
-		var data []*models.Comment
+		var data *models.Post
 		if pos < len(batch.data) {
 			data = batch.data[pos]
 		}
@@ -113,73 +113,72 @@ func (l *ChildCommentLoader) LoadThunk(key int) func() ([]*models.Comment, error
 
 // LoadAll fetches many keys at once. It will be broken into appropriate sized
 // sub batches depending on how the loader is configured
-func (l *ChildCommentLoader) LoadAll(keys []int) ([][]*models.Comment, []error) {
-	results := make([]func() ([]*models.Comment, error), len(keys))
+func (l *PostByIDLoader) LoadAll(keys []int) ([]*models.Post, []error) {
+	results := make([]func() (*models.Post, error), len(keys))
 
 	for i, key := range keys {
 		results[i] = l.LoadThunk(key)
 	}
 
-	comments := make([][]*models.Comment, len(keys))
+	posts := make([]*models.Post, len(keys))
 	errors := make([]error, len(keys))
 	for i, thunk := range results {
-		comments[i], errors[i] = thunk()
+		posts[i], errors[i] = thunk()
 	}
-	return comments, errors
+	return posts, errors
 }
 
-// LoadAllThunk returns a function that when called will block waiting for a Comments.
+// LoadAllThunk returns a function that when called will block waiting for a Posts.
 // This method should be used if you want one goroutine to make requests to many
 // different data loaders without blocking until the thunk is called.
-func (l *ChildCommentLoader) LoadAllThunk(keys []int) func() ([][]*models.Comment, []error) {
-	results := make([]func() ([]*models.Comment, error), len(keys))
+func (l *PostByIDLoader) LoadAllThunk(keys []int) func() ([]*models.Post, []error) {
+	results := make([]func() (*models.Post, error), len(keys))
 	for i, key := range keys {
 		results[i] = l.LoadThunk(key)
 	}
-	return func() ([][]*models.Comment, []error) {
-		comments := make([][]*models.Comment, len(keys))
+	return func() ([]*models.Post, []error) {
+		posts := make([]*models.Post, len(keys))
 		errors := make([]error, len(keys))
 		for i, thunk := range results {
-			comments[i], errors[i] = thunk()
+			posts[i], errors[i] = thunk()
 		}
-		return comments, errors
+		return posts, errors
 	}
 }
 
 // Prime the cache with the provided key and value. If the key already exists, no change is made
 // and false is returned.
 // (To forcefully prime the cache, clear the key first with loader.clear(key).prime(key, value).)
-func (l *ChildCommentLoader) Prime(key int, value []*models.Comment) bool {
+func (l *PostByIDLoader) Prime(key int, value *models.Post) bool {
 	l.mu.Lock()
 	var found bool
 	if _, found = l.cache[key]; !found {
 		// make a copy when writing to the cache, its easy to pass a pointer in from a loop var
 		// and end up with the whole cache pointing to the same value.
-		cpy := make([]*models.Comment, len(value))
-		copy(cpy, value)
-		l.unsafeSet(key, cpy)
+		cpy := *value
+		l.unsafeSet(key, &cpy)
 	}
 	l.mu.Unlock()
 	return !found
 }
 
 // Clear the value at key from the cache, if it exists
-func (l *ChildCommentLoader) Clear(key int) {
+func (l *PostByIDLoader) Clear(key int) {
 	l.mu.Lock()
 	delete(l.cache, key)
 	l.mu.Unlock()
 }
 
-func (l *ChildCommentLoader) unsafeSet(key int, value []*models.Comment) {
+func (l *PostByIDLoader) unsafeSet(key int, value *models.Post) {
 	if l.cache == nil {
-		l.cache = map[int][]*models.Comment{}
+		l.cache = map[int]*models.Post{}
 	}
 	l.cache[key] = value
 }
 
 // keyIndex will return the location of the key in the batch, if its not found
 // it will add the key to the batch
-func (b *childCommentLoaderBatch) keyIndex(l *ChildCommentLoader, key int) int {
+func (b *postByIDLoaderBatch) keyIndex(l *PostByIDLoader, key int) int {
 	for i, existingKey := range b.keys {
 		if key == existingKey {
 			return i
@@ -203,7 +202,7 @@ func (b *childCommentLoaderBatch) keyIndex(l *ChildCommentLoader, key int) int {
 	return pos
 }
 
-func (b *childCommentLoaderBatch) startTimer(l *ChildCommentLoader) {
+func (b *postByIDLoaderBatch) startTimer(l *PostByIDLoader) {
 	time.Sleep(l.wait)
 	l.mu.Lock()
 
@@ -219,7 +218,7 @@ func (b *childCommentLoaderBatch) startTimer(l *ChildCommentLoader) {
 	b.end(l)
 }
 
-func (b *childCommentLoaderBatch) end(l *ChildCommentLoader) {
+func (b *postByIDLoaderBatch) end(l *PostByIDLoader) {
 	b.data, b.error = l.fetch(b.keys)
 	close(b.done)
 }
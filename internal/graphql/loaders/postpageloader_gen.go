@@ -0,0 +1,221 @@
+// Code generated by github.com/vektah/dataloaden, DO NOT EDIT.
+
+package loaders
+
+import (
+	"sync"
+	"time"
+
+	"Habr-comments-server/internal/models"
+)
+
+// PostPageLoaderConfig captures the config to create a new PostPageLoader
+type PostPageLoaderConfig struct {
+	// Fetch is a method that provides the data for the loader
+	Fetch func(keys []int) ([]models.PostPage, []error)
+
+	// Wait is how long wait before sending a batch
+	Wait time.Duration
+
+	// MaxBatch will limit the maximum number of keys to send in one batch, 0 = not limit
+	MaxBatch int
+}
+
+// NewPostPageLoader creates a new PostPageLoader given a fetch, wait, and maxBatch
+func NewPostPageLoader(config PostPageLoaderConfig) *PostPageLoader {
+	return &PostPageLoader{
+		fetch:    config.Fetch,
+		wait:     config.Wait,
+		maxBatch: config.MaxBatch,
+	}
+}
+
+// PostPageLoader batches and caches requests
+type PostPageLoader struct {
+	// this method provides the data for the loader
+	fetch func(keys []int) ([]models.PostPage, []error)
+
+	// how long to done before sending a batch
+	wait time.Duration
+
+	// this will limit the maximum number of keys to send in one batch, 0 = no limit
+	maxBatch int
+
+	// INTERNAL
+
+	// lazily created cache
+	cache map[int]models.PostPage
+
+	// the current batch. keys will continue to be collected until timeout is hit,
+	// then everything will be sent to the fetch method and out to the listeners
+	batch *postPageLoaderBatch
+
+	// mutex to prevent races
+	mu sync.Mutex
+}
+
+type postPageLoaderBatch struct {
+	keys    []int
+	data    []models.PostPage
+	error   []error
+	closing bool
+	done    chan struct{}
+}
+
+// Load a PostPage by key, batching and caching will be applied automatically
+func (l *PostPageLoader) Load(key int) (models.PostPage, error) {
+	return l.LoadThunk(key)()
+}
+
+// LoadThunk returns a function that when called will block waiting for a PostPage.
+// This method should be used if you want one goroutine to make requests to many
+// different data loaders without blocking until the thunk is called.
+func (l *PostPageLoader) LoadThunk(key int) func() (models.PostPage, error) {
+	l.mu.Lock()
+	if it, ok := l.cache[key]; ok {
+		l.mu.Unlock()
+		return func() (models.PostPage, error) {
+			return it, nil
+		}
+	}
+	if l.batch == nil {
+		l.batch = &postPageLoaderBatch{done: make(chan struct{})}
+	}
+	batch := l.batch
+	pos := batch.keyIndex(l, key)
+	l.mu.Unlock()
+
+	return func() (models.PostPage, error) {
+		<-batch.done
+
+		var data models.PostPage
+		if pos < len(batch.data) {
+			data = batch.data[pos]
+		}
+
+		var err error
+		// its convenient to be able to return a single error for everything
+		if len(batch.error) == 1 {
+			err = batch.error[0]
+		} else if batch.error != nil {
+			err = batch.error[pos]
+		}
+
+		if err == nil {
+			l.mu.Lock()
+			l.unsafeSet(key, data)
+			l.mu.Unlock()
+		}
+
+		return data, err
+	}
+}
+
+// LoadAll fetches many keys at once. It will be broken into appropriate sized
+// sub batches depending on how the loader is configured
+func (l *PostPageLoader) LoadAll(keys []int) ([]models.PostPage, []error) {
+	results := make([]func() (models.PostPage, error), len(keys))
+
+	for i, key := range keys {
+		results[i] = l.LoadThunk(key)
+	}
+
+	postPages := make([]models.PostPage, len(keys))
+	errors := make([]error, len(keys))
+	for i, thunk := range results {
+		postPages[i], errors[i] = thunk()
+	}
+	return postPages, errors
+}
+
+// LoadAllThunk returns a function that when called will block waiting for a PostPages.
+// This method should be used if you want one goroutine to make requests to many
+// different data loaders without blocking until the thunk is called.
+func (l *PostPageLoader) LoadAllThunk(keys []int) func() ([]models.PostPage, []error) {
+	results := make([]func() (models.PostPage, error), len(keys))
+	for i, key := range keys {
+		results[i] = l.LoadThunk(key)
+	}
+	return func() ([]models.PostPage, []error) {
+		postPages := make([]models.PostPage, len(keys))
+		errors := make([]error, len(keys))
+		for i, thunk := range results {
+			postPages[i], errors[i] = thunk()
+		}
+		return postPages, errors
+	}
+}
+
+// Prime the cache with the provided key and value. If the key already exists, no change is made
+// and false is returned.
+// (To forcefully prime the cache, clear the key first with loader.clear(key).prime(key, value).)
+func (l *PostPageLoader) Prime(key int, value models.PostPage) bool {
+	l.mu.Lock()
+	var found bool
+	if _, found = l.cache[key]; !found {
+		l.unsafeSet(key, value)
+	}
+	l.mu.Unlock()
+	return !found
+}
+
+// Clear the value at key from the cache, if it exists
+func (l *PostPageLoader) Clear(key int) {
+	l.mu.Lock()
+	delete(l.cache, key)
+	l.mu.Unlock()
+}
+
+func (l *PostPageLoader) unsafeSet(key int, value models.PostPage) {
+	if l.cache == nil {
+		l.cache = map[int]models.PostPage{}
+	}
+	l.cache[key] = value
+}
+
+// keyIndex will return the location of the key in the batch, if its not found
+// it will add the key to the batch
+func (b *postPageLoaderBatch) keyIndex(l *PostPageLoader, key int) int {
+	for i, existingKey := range b.keys {
+		if key == existingKey {
+			return i
+		}
+	}
+
+	pos := len(b.keys)
+	b.keys = append(b.keys, key)
+	if pos == 0 {
+		go b.startTimer(l)
+	}
+
+	if l.maxBatch != 0 && pos >= l.maxBatch-1 {
+		if !b.closing {
+			b.closing = true
+			l.batch = nil
+			go b.end(l)
+		}
+	}
+
+	return pos
+}
+
+func (b *postPageLoaderBatch) startTimer(l *PostPageLoader) {
+	time.Sleep(l.wait)
+	l.mu.Lock()
+
+	// we must have hit a batch limit and are already finalizing this batch
+	if b.closing {
+		l.mu.Unlock()
+		return
+	}
+
+	l.batch = nil
+	l.mu.Unlock()
+
+	b.end(l)
+}
+
+func (b *postPageLoaderBatch) end(l *PostPageLoader) {
+	b.data, b.error = l.fetch(b.keys)
+	close(b.done)
+}
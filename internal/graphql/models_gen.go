@@ -2,8 +2,182 @@
 
 package graphql
 
+import (
+	"Habr-comments-server/internal/models"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type AuthPayload struct {
+	Token     string       `json:"token"`
+	ExpiresAt string       `json:"expiresAt"`
+	User      *models.User `json:"user"`
+}
+
+type CommentConnection struct {
+	Edges      []*CommentEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount int            `json:"totalCount"`
+}
+
+type CommentEdge struct {
+	Cursor string          `json:"cursor"`
+	Node   *models.Comment `json:"node"`
+}
+
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+}
+
+type PostConnection struct {
+	Edges      []*PostEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type PostEdge struct {
+	Cursor string       `json:"cursor"`
+	Node   *models.Post `json:"node"`
+}
+
 type Query struct {
 }
+
+type Subscription struct {
+}
+
+type CommentSort string
+
+const (
+	CommentSortOldest        CommentSort = "OLDEST"
+	CommentSortNewest        CommentSort = "NEWEST"
+	CommentSortTop           CommentSort = "TOP"
+	CommentSortControversial CommentSort = "CONTROVERSIAL"
+	CommentSortMostReplies   CommentSort = "MOST_REPLIES"
+)
+
+var AllCommentSort = []CommentSort{
+	CommentSortOldest,
+	CommentSortNewest,
+	CommentSortTop,
+	CommentSortControversial,
+	CommentSortMostReplies,
+}
+
+func (e CommentSort) IsValid() bool {
+	switch e {
+	case CommentSortOldest, CommentSortNewest, CommentSortTop, CommentSortControversial, CommentSortMostReplies:
+		return true
+	}
+	return false
+}
+
+func (e CommentSort) String() string {
+	return string(e)
+}
+
+func (e *CommentSort) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentSort", str)
+	}
+	return nil
+}
+
+func (e CommentSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OwnedResource string
+
+const (
+	OwnedResourcePost    OwnedResource = "POST"
+	OwnedResourceComment OwnedResource = "COMMENT"
+)
+
+var AllOwnedResource = []OwnedResource{
+	OwnedResourcePost,
+	OwnedResourceComment,
+}
+
+func (e OwnedResource) IsValid() bool {
+	switch e {
+	case OwnedResourcePost, OwnedResourceComment:
+		return true
+	}
+	return false
+}
+
+func (e OwnedResource) String() string {
+	return string(e)
+}
+
+func (e *OwnedResource) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OwnedResource(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OwnedResource", str)
+	}
+	return nil
+}
+
+func (e OwnedResource) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleUser      Role = "USER"
+	RoleModerator Role = "MODERATOR"
+	RoleAdmin     Role = "ADMIN"
+)
+
+var AllRole = []Role{
+	RoleUser,
+	RoleModerator,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Role", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
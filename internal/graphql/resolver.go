@@ -3,33 +3,41 @@ package graphql
 // THIS CODE WILL BE UPDATED WITH SCHEMA CHANGES. PREVIOUS IMPLEMENTATION FOR SCHEMA CHANGES WILL BE KEPT IN THE COMMENT SECTION. IMPLEMENTATION FOR UNCHANGED SCHEMA WILL BE KEPT.
 
 import (
-	"Habr-comments-server/internal/graphql/loaders"
+	"Habr-comments-server/internal/auth"
 	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/pubsub"
 	"Habr-comments-server/internal/service"
+	"Habr-comments-server/internal/storage"
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Resolver struct {
 	Service *service.Service
-	Loaders *loaders.Loaders
+	Broker  *pubsub.Broker
+	Tokens  *auth.Tokens
 }
 
 // Post is the resolver for the post field.
 func (r *commentResolver) Post(ctx context.Context, obj *models.Comment) (*models.Post, error) {
-	post, err := r.Service.PostService.GetPost(ctx, obj.PostId)
+	post, err := r.dataloaders(ctx).PostByIDLoader.Load(obj.PostId)
 	if err != nil {
 		return nil, err
 	}
+	if post == nil {
+		return nil, fmt.Errorf("post %d: %w", obj.PostId, storage.ErrNotFound)
+	}
 
-	return &post, nil
+	return post, nil
 }
 
 // Author is the resolver for the author field.
 func (r *commentResolver) Author(ctx context.Context, obj *models.Comment) (*models.User, error) {
-	return r.Loaders.UserLoader.Load(obj.AuthorId)
+	return r.dataloaders(ctx).UserLoader.Load(obj.AuthorId)
 }
 
 // Parent is the resolver for the parent field.
@@ -38,86 +46,173 @@ func (r *commentResolver) Parent(ctx context.Context, obj *models.Comment) (*mod
 		return nil, nil
 	}
 
-	comments, err := r.Loaders.CommentLoader.Load(*obj.ParentId)
+	// nil, если родительского комментария нет
+	return r.dataloaders(ctx).CommentByIDLoader.Load(*obj.ParentId)
+}
+
+// CreatedAt is the resolver for the createdAt field.
+func (r *commentResolver) CreatedAt(ctx context.Context, obj *models.Comment) (string, error) {
+	return obj.CreatedAt.Format(time.RFC3339), nil
+}
+
+// EditedAt is the resolver for the editedAt field.
+func (r *commentResolver) EditedAt(ctx context.Context, obj *models.Comment) (*string, error) {
+	if obj.EditedAt == nil {
+		return nil, nil
+	}
+
+	editedAt := obj.EditedAt.Format(time.RFC3339)
+	return &editedAt, nil
+}
+
+// MyVote is the resolver for the myVote field.
+func (r *commentResolver) MyVote(ctx context.Context, obj *models.Comment) (int, error) {
+	userID, ok := viewerID(ctx)
+	if !ok {
+		return 0, nil
+	}
+
+	return r.dataloaders(ctx).Votes(userID).Load(obj.ID)
+}
+
+// RepliesCount is the resolver for the repliesCount field.
+func (r *commentResolver) RepliesCount(ctx context.Context, obj *models.Comment) (int, error) {
+	stats, err := r.dataloaders(ctx).CommentStatsLoader.Load(obj.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return stats.RepliesCount, nil
+}
+
+// DescendantsCount is the resolver for the descendantsCount field.
+func (r *commentResolver) DescendantsCount(ctx context.Context, obj *models.Comment) (int, error) {
+	stats, err := r.dataloaders(ctx).CommentStatsLoader.Load(obj.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return stats.DescendantsCount, nil
+}
+
+// Children is the resolver for the children field.
+func (r *commentResolver) Children(ctx context.Context, obj *models.Comment, first *int, after *string, sort *CommentSort) (*CommentConnection, error) {
+	order := commentSort(sort)
+	page, err := pageParams(first, after, cursorOrder(order))
 	if err != nil {
 		return nil, err
 	}
 
-	if len(comments) == 0 {
-		return nil, nil // Родительского комментария нет
+	comments, err := r.dataloaders(ctx).ChildComments(order, page).Load(obj.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	return comments[0], nil
+	return newCommentConnection(comments, cursorOrder(order)), nil
 }
 
-// CreatedAt is the resolver for the createdAt field.
-func (r *commentResolver) CreatedAt(ctx context.Context, obj *models.Comment) (string, error) {
-	return obj.CreatedAt.Format(time.RFC3339), nil
+// Ancestors is the resolver for the ancestors field.
+func (r *commentResolver) Ancestors(ctx context.Context, obj *models.Comment) ([]*models.Comment, error) {
+	if obj.ParentId == nil {
+		return []*models.Comment{}, nil
+	}
+
+	// Поднимаемся по родителям: на каждом уровне запросы всех комментариев ответа
+	// объединяются в одну пачку, поэтому число запросов ограничено глубиной дерева
+	var ancestors []*models.Comment
+	for parentID := obj.ParentId; parentID != nil; {
+		parent, err := r.dataloaders(ctx).CommentByIDLoader.Load(*parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			break
+		}
+
+		ancestors = append(ancestors, parent)
+		parentID = parent.ParentId
+	}
+
+	// От корня к родителю
+	for i, j := 0, len(ancestors)-1; i < j; i, j = i+1, j-1 {
+		ancestors[i], ancestors[j] = ancestors[j], ancestors[i]
+	}
+
+	return ancestors, nil
 }
 
-// Children is the resolver for the children field.
-func (r *commentResolver) Children(ctx context.Context, obj *models.Comment, limit *int, offset *int) ([]*models.Comment, error) {
-	comments, err := r.Service.CommentService.GetChildComments(ctx, obj.ID)
+// CreateUser is the resolver for the createUser field.
+func (r *mutationResolver) CreateUser(ctx context.Context, username string, password string) (*models.User, error) {
+	user, err := r.Service.UserService.Register(ctx, username, password)
+	if errors.Is(err, storage.ErrAlreadyExists) {
+		return nil, newClientError(storage.ErrAlreadyExists, "username %q is already taken", strings.TrimSpace(username))
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Реализуем пагинацию
-	start := 0
-	if offset != nil {
-		start = *offset
+	r.dataloaders(ctx).PrimeUser(&user)
+	return &user, nil
+}
+
+// Login is the resolver for the login field.
+func (r *mutationResolver) Login(ctx context.Context, username string, password string) (*AuthPayload, error) {
+	user, err := r.Service.UserService.Authenticate(ctx, username, password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		return nil, errInvalidCredentials
 	}
-	end := len(comments)
-	if limit != nil && start+*limit < end {
-		end = start + *limit
+	if err != nil {
+		return nil, err
 	}
 
-	if start > end {
-		return []*models.Comment{}, nil
+	return r.issueToken(&user)
+}
+
+// RefreshToken is the resolver for the refreshToken field.
+func (r *mutationResolver) RefreshToken(ctx context.Context) (*AuthPayload, error) {
+	userID, err := requireViewer(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	commentPtrs := make([]*models.Comment, end-start)
-	for i := range commentPtrs {
-		commentPtrs[i] = &comments[start+i]
+	user, err := r.Service.UserService.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	return commentPtrs, nil
+	return r.issueToken(&user)
 }
 
 // CreatePost is the resolver for the createPost field.
-func (r *mutationResolver) CreatePost(ctx context.Context, authorID string, title string, content string, allowComments bool) (*models.Post, error) {
-	var err error
-	var authorIdInt, postID int
-	var post models.Post
-
-	authorIdInt, err = strconv.Atoi(authorID)
+func (r *mutationResolver) CreatePost(ctx context.Context, title string, content string, allowComments bool) (*models.Post, error) {
+	authorIdInt, err := requireViewer(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	postID, err = r.Service.PostService.CreatePost(ctx, authorIdInt, title, content, allowComments)
+	post, err := r.Service.PostService.CreatePost(ctx, authorIdInt, title, content, allowComments)
 	if err != nil {
 		return nil, err
 	}
 
-	post, err = r.Service.PostService.GetPost(ctx, postID)
-	return &post, err
+	return &post, nil
 }
 
 // CreateComment is the resolver for the createComment field.
-func (r *mutationResolver) CreateComment(ctx context.Context, postID string, authorID string, parentID *string, content string) (*models.Comment, error) {
+func (r *mutationResolver) CreateComment(ctx context.Context, postID string, parentID *string, content string) (*models.Comment, error) {
 	var err error
-	var authorIdInt, commentID, postIdInt int
+	var authorIdInt, postIdInt int
 
-	// Конвертация строковых параметров в int
-	postIdInt, err = strconv.Atoi(postID)
+	// Автор — текущий пользователь, а не аргумент от клиента
+	authorIdInt, err = requireViewer(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("invalid post ID: %w", err)
+		return nil, err
 	}
 
-	authorIdInt, err = strconv.Atoi(authorID)
+	// Конвертация строковых параметров в int
+	postIdInt, err = strconv.Atoi(postID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid author ID: %w", err)
+		return nil, fmt.Errorf("invalid post ID: %w", err)
 	}
 
 	var parentIdInt *int
@@ -129,58 +224,169 @@ func (r *mutationResolver) CreateComment(ctx context.Context, postID string, aut
 		parentIdInt = &pID
 	}
 
-	post, err := r.Service.PostService.GetPost(ctx, postIdInt)
+	comment, err := r.Service.CommentService.CreateComment(ctx, postIdInt, authorIdInt, parentIdInt, content)
+	if errors.Is(err, storage.ErrCommentsBlock) {
+		return nil, newClientError(storage.ErrCommentsBlock, "comments are disabled for this post")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch post: %w", err)
+		return nil, fmt.Errorf("failed to create comment: %w", err)
 	}
 
-	if !post.AllowComments {
-		return nil, fmt.Errorf("comments are disabled for this post")
+	r.dataloaders(ctx).ClearComment(&comment)
+	r.Broker.Publish(&comment)
+	return &comment, nil
+}
+
+// UpdatePost is the resolver for the updatePost field.
+func (r *mutationResolver) UpdatePost(ctx context.Context, id string, title *string, content *string) (*models.Post, error) {
+	postIdInt, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
 	}
 
-	commentID, err = r.Service.CommentService.CreateComment(ctx, postIdInt, authorIdInt, parentIdInt, content)
+	post, err := r.Service.PostService.UpdatePost(ctx, postIdInt, title, content)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create comment: %w", err)
+		return nil, err
 	}
 
-	comments, err := r.Service.CommentService.GetComments(ctx, postIdInt)
+	r.dataloaders(ctx).PrimePost(&post)
+	return &post, nil
+}
+
+// DeletePost is the resolver for the deletePost field.
+func (r *mutationResolver) DeletePost(ctx context.Context, id string) (bool, error) {
+	postIdInt, err := strconv.Atoi(id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch comments: %w", err)
+		return false, err
 	}
 
-	for _, comment := range comments {
-		if comment.ID == commentID {
-			return &comment, nil
-		}
+	if err = r.Service.PostService.DeletePost(ctx, postIdInt); err != nil {
+		return false, err
 	}
 
-	return nil, fmt.Errorf("comment not found after creation")
+	return true, nil
 }
 
 // BlockComments is the resolver for the blockComments field.
-func (r *mutationResolver) BlockComments(ctx context.Context, postID string) (*models.Post, error) {
-	var err error
-	var postIdInt int
+func (r *mutationResolver) BlockComments(ctx context.Context, postID string, reason *string) (*models.Post, error) {
+	postIdInt, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, err
+	}
 
-	postIdInt, err = strconv.Atoi(postID)
+	var reasonStr string
+	if reason != nil {
+		reasonStr = *reason
+	}
+
+	post, err := r.Service.PostService.BlockComments(ctx, postIdInt, reasonStr)
 	if err != nil {
 		return nil, err
 	}
 
-	err = r.Service.PostService.BlockComments(ctx, postIdInt)
+	r.dataloaders(ctx).PrimePost(&post)
+	return &post, nil
+}
+
+// UnblockComments is the resolver for the unblockComments field.
+func (r *mutationResolver) UnblockComments(ctx context.Context, postID string) (*models.Post, error) {
+	postIdInt, err := strconv.Atoi(postID)
 	if err != nil {
 		return nil, err
 	}
 
-	var post models.Post
+	post, err := r.Service.PostService.UnblockComments(ctx, postIdInt)
+	if err != nil {
+		return nil, err
+	}
 
-	post, err = r.Service.PostService.GetPost(ctx, postIdInt)
-	return &post, err
+	r.dataloaders(ctx).PrimePost(&post)
+	return &post, nil
+}
+
+// UpdateComment is the resolver for the updateComment field.
+func (r *mutationResolver) UpdateComment(ctx context.Context, id string, content string) (*models.Comment, error) {
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment ID: %w", err)
+	}
+
+	comment, err := r.Service.CommentService.UpdateComment(ctx, idInt, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	r.dataloaders(ctx).ClearComment(&comment)
+	return &comment, nil
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string) (*models.Comment, error) {
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment ID: %w", err)
+	}
+
+	comment, err := r.Service.CommentService.DeleteComment(ctx, idInt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	r.dataloaders(ctx).ClearComment(&comment)
+	return &comment, nil
+}
+
+// VoteComment is the resolver for the voteComment field.
+func (r *mutationResolver) VoteComment(ctx context.Context, commentID string, value int) (*models.Comment, error) {
+	userID, err := requireViewer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	commentIdInt, err := strconv.Atoi(commentID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment ID: %w", err)
+	}
+
+	comment, err := r.Service.CommentService.VoteComment(ctx, commentIdInt, userID, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to vote: %w", err)
+	}
+
+	r.dataloaders(ctx).ClearComment(&comment)
+	return &comment, nil
+}
+
+// SetUserRole is the resolver for the setUserRole field.
+func (r *mutationResolver) SetUserRole(ctx context.Context, userID string, role Role) (*models.User, error) {
+	userIdInt, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+
+	user, err := r.Service.UserService.SetUserRole(ctx, userIdInt, modelRole(role))
+	if err != nil {
+		return nil, err
+	}
+
+	// Роль могла уже попасть в кеш запроса вместе с автором
+	r.dataloaders(ctx).PrimeUser(&user)
+	return &user, nil
 }
 
 // Author is the resolver for the author field.
 func (r *postResolver) Author(ctx context.Context, obj *models.Post) (*models.User, error) {
-	return r.Loaders.UserLoader.Load(obj.AuthorId)
+	return r.dataloaders(ctx).UserLoader.Load(obj.AuthorId)
+}
+
+// CommentsLockedAt is the resolver for the commentsLockedAt field.
+func (r *postResolver) CommentsLockedAt(ctx context.Context, obj *models.Post) (*string, error) {
+	if obj.CommentsLockedAt == nil {
+		return nil, nil
+	}
+
+	lockedAt := obj.CommentsLockedAt.Format(time.RFC3339)
+	return &lockedAt, nil
 }
 
 // CreatedAt is the resolver for the createdAt field.
@@ -188,42 +394,59 @@ func (r *postResolver) CreatedAt(ctx context.Context, obj *models.Post) (string,
 	return obj.CreatedAt.Format(time.RFC3339), nil
 }
 
-// Comments is the resolver for the comments field.
-func (r *postResolver) Comments(ctx context.Context, obj *models.Post, limit *int, offset *int) ([]*models.Comment, error) {
-	comments, err := r.Loaders.CommentLoader.Load(obj.ID)
+// CommentsCount is the resolver for the commentsCount field.
+func (r *postResolver) CommentsCount(ctx context.Context, obj *models.Post) (int, error) {
+	stats, err := r.dataloaders(ctx).PostStatsLoader.Load(obj.ID)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	// Реализуем пагинацию
-	start := 0
-	if offset != nil {
-		start = *offset
+	return stats.CommentsCount, nil
+}
+
+// LastCommentAt is the resolver for the lastCommentAt field.
+func (r *postResolver) LastCommentAt(ctx context.Context, obj *models.Post) (*string, error) {
+	stats, err := r.dataloaders(ctx).PostStatsLoader.Load(obj.ID)
+	if err != nil {
+		return nil, err
 	}
-	end := len(comments)
-	if limit != nil && start+*limit < end {
-		end = start + *limit
+	if stats.LastCommentAt == nil {
+		return nil, nil
 	}
 
-	if start > end { // Проверяем, не ушли ли мы за границы массива
-		return []*models.Comment{}, nil
+	lastCommentAt := stats.LastCommentAt.Format(time.RFC3339)
+	return &lastCommentAt, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *postResolver) Comments(ctx context.Context, obj *models.Post, first *int, after *string, sort *CommentSort) (*CommentConnection, error) {
+	order := commentSort(sort)
+	page, err := pageParams(first, after, cursorOrder(order))
+	if err != nil {
+		return nil, err
 	}
 
-	return comments[start:end], nil
+	comments, err := r.dataloaders(ctx).RootComments(order, page).Load(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCommentConnection(comments, cursorOrder(order)), nil
 }
 
 // Posts is the resolver for the posts field.
-func (r *queryResolver) Posts(ctx context.Context, limit *int, offset *int) ([]*models.Post, error) {
-	posts, err := r.Service.PostService.GetPosts(ctx, *limit, *offset)
+func (r *queryResolver) Posts(ctx context.Context, first *int, after *string) (*PostConnection, error) {
+	page, err := pageParams(first, after, postsOrder)
 	if err != nil {
 		return nil, err
 	}
 
-	postPtrs := make([]*models.Post, len(posts))
-	for i := range posts {
-		postPtrs[i] = &posts[i]
+	posts, err := r.Service.PostService.GetPosts(ctx, page)
+	if err != nil {
+		return nil, err
 	}
-	return postPtrs, nil
+
+	return newPostConnection(posts), nil
 }
 
 // Post is the resolver for the post field.
@@ -242,34 +465,217 @@ func (r *queryResolver) Post(ctx context.Context, id string) (*models.Post, erro
 }
 
 // Comments is the resolver for the comments field.
-func (r *queryResolver) Comments(ctx context.Context, parentID string, limit *int, offset *int) ([]*models.Comment, error) {
+func (r *queryResolver) Comments(ctx context.Context, parentID string, first *int, after *string, sort *CommentSort) (*CommentConnection, error) {
 	parentIdInt, err := strconv.Atoi(parentID)
 	if err != nil {
 		return nil, err
 	}
 
-	comments, err := r.Service.CommentService.GetChildComments(ctx, parentIdInt)
+	order := commentSort(sort)
+	page, err := pageParams(first, after, cursorOrder(order))
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := r.Service.CommentService.GetChildComments(ctx, parentIdInt, order, page)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCommentConnection(comments, cursorOrder(order)), nil
+}
+
+// CommentTree is the resolver for the commentTree field.
+func (r *queryResolver) CommentTree(ctx context.Context, postID string, maxDepth *int, rootLimit *int) ([]*models.CommentTreeNode, error) {
+	postIdInt, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Без аргументов дерево не ограничено
+	depth, limit := -1, -1
+	if maxDepth != nil {
+		if *maxDepth < 0 {
+			return nil, invalidInput("maxDepth must be non-negative")
+		}
+		depth = *maxDepth
+	}
+	if rootLimit != nil {
+		if *rootLimit < 0 {
+			return nil, invalidInput("rootLimit must be non-negative")
+		}
+		limit = *rootLimit
+	}
+
+	tree, err := r.Service.CommentService.GetCommentTree(ctx, postIdInt, depth, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*models.CommentTreeNode, len(tree))
+	for i := range tree {
+		nodes[i] = &tree[i]
+	}
+
+	return nodes, nil
+}
+
+// CommentSubtree is the resolver for the commentSubtree field.
+func (r *queryResolver) CommentSubtree(ctx context.Context, id string, maxDepth *int) ([]*models.CommentTreeNode, error) {
+	idInt, err := strconv.Atoi(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// Применяем пагинацию (если limit и offset заданы)
-	start := 0
-	if offset != nil {
-		start = *offset
+	// Без maxDepth поддерево не ограничено
+	depth := -1
+	if maxDepth != nil {
+		if *maxDepth < 0 {
+			return nil, invalidInput("maxDepth must be non-negative")
+		}
+		depth = *maxDepth
 	}
-	end := len(comments)
-	if limit != nil && start+*limit < end {
-		end = start + *limit
+
+	tree, err := r.Service.CommentService.GetCommentSubtree(ctx, idInt, depth)
+	if err != nil {
+		return nil, err
 	}
 
-	// Преобразуем []models.Comment -> []*models.Comment
-	commentPtrs := make([]*models.Comment, end-start)
-	for i := range commentPtrs {
-		commentPtrs[i] = &comments[start+i]
+	nodes := make([]*models.CommentTreeNode, len(tree))
+	for i := range tree {
+		nodes[i] = &tree[i]
 	}
 
-	return commentPtrs, nil
+	return nodes, nil
+}
+
+// CommentContext is the resolver for the commentContext field.
+func (r *queryResolver) CommentContext(ctx context.Context, id string, parentsAbove *int, repliesBelow *int) (*models.CommentContext, error) {
+	idInt, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// По умолчанию показываем всю цепочку предков
+	parents, replies := -1, defaultContextReplies
+	if parentsAbove != nil {
+		if *parentsAbove < 0 {
+			return nil, invalidInput("parentsAbove must be non-negative")
+		}
+		parents = *parentsAbove
+	}
+	if repliesBelow != nil {
+		if *repliesBelow < 0 {
+			return nil, invalidInput("repliesBelow must be non-negative")
+		}
+		replies = min(*repliesBelow, maxPageSize)
+	}
+
+	commentCtx, err := r.Service.CommentService.GetCommentContext(ctx, idInt, parents, contextSiblings, replies)
+	if err != nil {
+		return nil, err
+	}
+
+	return &commentCtx, nil
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id string) (*models.User, error) {
+	userIdInt, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := r.Service.UserService.GetUser(ctx, userIdInt)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*models.User, error) {
+	userID, ok := viewerID(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	user, err := r.Service.UserService.GetUser(ctx, userID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// CommentAdded is the resolver for the commentAdded field.
+func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string, parentID *string) (<-chan *models.Comment, error) {
+	postIdInt, err := strconv.Atoi(postID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid post ID: %w", err)
+	}
+
+	var parentIdInt *int
+	if parentID != nil {
+		pID, err := strconv.Atoi(*parentID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid parent ID: %w", err)
+		}
+		parentIdInt = &pID
+	}
+
+	if _, err = r.Service.PostService.GetPost(ctx, postIdInt); err != nil {
+		return nil, fmt.Errorf("failed to fetch post: %w", err)
+	}
+
+	return r.Broker.Subscribe(ctx, postIdInt, parentIdInt), nil
+}
+
+// Role is the resolver for the role field.
+func (r *userResolver) Role(ctx context.Context, obj *models.User) (Role, error) {
+	return Role(strings.ToUpper(string(obj.Role))), nil
+}
+
+// CreatedAt is the resolver for the createdAt field.
+func (r *userResolver) CreatedAt(ctx context.Context, obj *models.User) (string, error) {
+	return obj.CreatedAt.Format(time.RFC3339), nil
+}
+
+// Posts is the resolver for the posts field.
+func (r *userResolver) Posts(ctx context.Context, obj *models.User, first *int, after *string) (*PostConnection, error) {
+	page, err := pageParams(first, after, postsOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := r.dataloaders(ctx).UserPosts(page).Load(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPostConnection(posts), nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *userResolver) Comments(ctx context.Context, obj *models.User, first *int, after *string) (*CommentConnection, error) {
+	page, err := pageParams(first, after, userCommentsOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := r.dataloaders(ctx).UserComments(page).Load(obj.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCommentConnection(comments, userCommentsOrder), nil
 }
 
 // Comment returns CommentResolver implementation.
@@ -284,7 +690,15 @@ func (r *Resolver) Post() PostResolver { return &postResolver{r} }
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
 type commentResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type postResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
@@ -0,0 +1,26 @@
+package graphql
+
+import (
+	"Habr-comments-server/internal/auth"
+	"Habr-comments-server/internal/models"
+	"fmt"
+	"time"
+)
+
+// Одна и та же ошибка для неизвестного имени и неверного пароля,
+// чтобы по ответу нельзя было перебирать существующие имена.
+var errInvalidCredentials = newClientError(auth.ErrUnauthenticated, "invalid username or password")
+
+// issueToken выпускает токен доступа для пользователя.
+func (r *Resolver) issueToken(user *models.User) (*AuthPayload, error) {
+	token, expiresAt, err := r.Tokens.Issue(user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue token: %w", err)
+	}
+
+	return &AuthPayload{
+		Token:     token,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+		User:      user,
+	}, nil
+}
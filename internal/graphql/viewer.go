@@ -0,0 +1,21 @@
+package graphql
+
+import (
+	"Habr-comments-server/internal/auth"
+	"context"
+)
+
+// viewerID возвращает ID текущего пользователя, который auth.Middleware кладет в контекст.
+func viewerID(ctx context.Context) (int, bool) {
+	return auth.UserID(ctx)
+}
+
+// requireViewer возвращает ID текущего пользователя или ошибку для анонимного запроса:
+// без токена API доступно только на чтение.
+func requireViewer(ctx context.Context) (int, error) {
+	userID, ok := viewerID(ctx)
+	if !ok {
+		return 0, auth.ErrUnauthenticated
+	}
+	return userID, nil
+}
@@ -3,10 +3,46 @@ package models
 import "time"
 
 type Comment struct {
-	ID        int       `json:"id"`
-	PostId    int       `json:"post_id"`
-	AuthorId  int       `json:"author_id"`
-	ParentId  *int      `json:"parent_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID        int        `json:"id"`
+	PostId    int        `json:"post_id"`
+	AuthorId  int        `json:"author_id"`
+	ParentId  *int       `json:"parent_id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt"`
+	IsDeleted bool       `json:"isDeleted"` // Удаленный комментарий остается в дереве без текста
+	Upvotes   int        `json:"upvotes"`
+	Downvotes int        `json:"downvotes"`
+}
+
+// Score возвращает рейтинг комментария.
+func (c Comment) Score() int {
+	return c.Upvotes - c.Downvotes
+}
+
+// Cursor возвращает ключ комментария для keyset-пагинации.
+func (c Comment) Cursor() Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+}
+
+// CommentStats — счетчики ответов на комментарий.
+type CommentStats struct {
+	RepliesCount     int `json:"repliesCount"`     // Прямые ответы
+	DescendantsCount int `json:"descendantsCount"` // Все ответы в поддереве
+}
+
+// CommentTreeNode — комментарий в плоском представлении дерева (pre-order).
+type CommentTreeNode struct {
+	Comment Comment `json:"comment"`
+	Depth   int     `json:"depth"` // Глубина: у корневых комментариев 0
+	Path    []int   `json:"path"`  // ID предков от корня к непосредственному родителю
+}
+
+// CommentContext — комментарий с окружением для перехода по прямой ссылке.
+type CommentContext struct {
+	Comment        Comment   `json:"comment"`
+	Ancestors      []Comment `json:"ancestors"`      // От корня к непосредственному родителю
+	SiblingsBefore []Comment `json:"siblingsBefore"` // Соседи того же уровня перед комментарием
+	SiblingsAfter  []Comment `json:"siblingsAfter"`  // Соседи того же уровня после комментария
+	Replies        []Comment `json:"replies"`        // Первые ответы на комментарий
 }
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Cursor — позиция в keyset-пагинации: ключ последней полученной записи.
+// Value — значение основного ключа сортировки (рейтинг, число ответов и т.п.),
+// для сортировки по времени оно равно нулю.
+type Cursor struct {
+	Value     int
+	CreatedAt time.Time
+	ID        int
+}
+
+// PageParams — параметры запроса страницы: сколько записей вернуть и после какой.
+type PageParams struct {
+	First int
+	After *Cursor
+}
+
+// PostPage — страница постов.
+type PostPage struct {
+	Posts       []Post
+	HasNextPage bool
+	TotalCount  int
+}
+
+// CommentPage — страница комментариев.
+type CommentPage struct {
+	Comments    []Comment
+	Cursors     []Cursor // Курсоры комментариев с учетом сортировки, в том же порядке
+	HasNextPage bool
+	TotalCount  int
+}
+
+// CommentSort — порядок сортировки комментариев одного уровня.
+type CommentSort string
+
+const (
+	SortOldest        CommentSort = "OLDEST"        // Сначала старые
+	SortNewest        CommentSort = "NEWEST"        // Сначала новые
+	SortTop           CommentSort = "TOP"           // По рейтингу (upvotes - downvotes)
+	SortControversial CommentSort = "CONTROVERSIAL" // По числу голосов меньшинства: min(upvotes, downvotes)
+	SortMostReplies   CommentSort = "MOST_REPLIES"  // По числу прямых ответов
+)
+
+// Descending сообщает, идет ли сортировка по убыванию ключа.
+func (s CommentSort) Descending() bool {
+	return s != SortOldest
+}
@@ -9,4 +9,18 @@ type Post struct {
 	Content       string    `json:"content"`
 	AllowComments bool      `json:"allowComments"`
 	CreatedAt     time.Time `json:"createdAt"`
+
+	CommentsLockedReason *string    `json:"commentsLockedReason"` // Причина запрета комментариев
+	CommentsLockedAt     *time.Time `json:"commentsLockedAt"`     // Когда комментарии были запрещены
+}
+
+// Cursor возвращает ключ поста для keyset-пагинации.
+func (p Post) Cursor() Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
+
+// PostStats — счетчики комментариев поста.
+type PostStats struct {
+	CommentsCount int        `json:"commentsCount"`
+	LastCommentAt *time.Time `json:"lastCommentAt"` // nil, если комментариев нет
 }
@@ -1,6 +1,43 @@
 package models
 
+import "time"
+
+// Role — роль пользователя; значения совпадают с хранимыми в БД.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// rank задает иерархию ролей: каждая следующая роль включает права предыдущей.
+var rank = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// Valid сообщает, известна ли роль.
+func (r Role) Valid() bool {
+	_, ok := rank[r]
+	return ok
+}
+
+// AtLeast сообщает, что роль r не ниже required.
+func (r Role) AtLeast(required Role) bool {
+	return r.Valid() && rank[r] >= rank[required]
+}
+
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
+	ID           int       `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"` // bcrypt-хеш, наружу не отдается
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// IsStaff сообщает, может ли пользователь модерировать чужие посты и комментарии.
+func (u User) IsStaff() bool {
+	return u.Role.AtLeast(RoleModerator)
 }
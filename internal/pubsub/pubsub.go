@@ -0,0 +1,99 @@
+package pubsub
+
+import (
+	"Habr-comments-server/internal/models"
+	"context"
+	"sync"
+)
+
+// DefaultBuffer — размер буфера канала одного подписчика по умолчанию.
+const DefaultBuffer = 16
+
+type subscriber struct {
+	parentID *int
+	ch       chan *models.Comment
+}
+
+// Broker — внутрипроцессная шина событий о новых комментариях.
+// Публикация никогда не блокируется: если буфер подписчика заполнен,
+// событие для него отбрасывается.
+type Broker struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]map[int]*subscriber // postID -> subscriptionID -> подписчик
+	buffer int
+}
+
+// NewBroker создает шину с заданным размером буфера на подписчика.
+func NewBroker(buffer int) *Broker {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+
+	return &Broker{
+		subs:   make(map[int]map[int]*subscriber),
+		buffer: buffer,
+	}
+}
+
+// Subscribe подписывает на новые комментарии к посту. Если parentID задан,
+// приходят только ответы на этот комментарий. Канал закрывается после отмены ctx.
+func (b *Broker) Subscribe(ctx context.Context, postID int, parentID *int) <-chan *models.Comment {
+	sub := &subscriber{
+		parentID: parentID,
+		ch:       make(chan *models.Comment, b.buffer),
+	}
+
+	b.mu.Lock()
+	b.nextID++
+	id := b.nextID
+	if b.subs[postID] == nil {
+		b.subs[postID] = make(map[int]*subscriber)
+	}
+	b.subs[postID][id] = sub
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.unsubscribe(postID, id)
+	}()
+
+	return sub.ch
+}
+
+func (b *Broker) unsubscribe(postID, id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub, ok := b.subs[postID][id]
+	if !ok {
+		return
+	}
+
+	delete(b.subs[postID], id)
+	if len(b.subs[postID]) == 0 {
+		delete(b.subs, postID)
+	}
+	close(sub.ch)
+}
+
+// Publish рассылает комментарий всем подходящим подписчикам.
+func (b *Broker) Publish(comment *models.Comment) {
+	if comment == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.subs[comment.PostId] {
+		if sub.parentID != nil && (comment.ParentId == nil || *comment.ParentId != *sub.parentID) {
+			continue
+		}
+
+		select {
+		case sub.ch <- comment:
+		default: // медленный подписчик не должен тормозить публикацию
+		}
+	}
+}
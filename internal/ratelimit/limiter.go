@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter — набор token bucket'ов, по одному на ключ (пользователя или IP).
+// Число ключей ограничено: при переполнении вытесняется ключ, который дольше всех не обращался.
+type Limiter struct {
+	rate    float64 // Токенов в секунду
+	burst   float64 // Емкость корзины
+	maxKeys int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List // Спереди — недавно использованные корзины
+}
+
+type bucket struct {
+	key     string
+	tokens  float64
+	updated time.Time
+}
+
+// NewLimiter создает лимитер на limit операций за interval с запасом burst.
+func NewLimiter(limit int, interval time.Duration, burst, maxKeys int) *Limiter {
+	return &Limiter{
+		rate:    float64(limit) / interval.Seconds(),
+		burst:   float64(burst),
+		maxKeys: maxKeys,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Allow списывает токен из корзины key. Если токенов нет, возвращает false
+// и время, через которое появится следующий.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	return l.AllowN(key, 1, now)
+}
+
+// Burst возвращает емкость корзины: больше токенов за раз не списать.
+func (l *Limiter) Burst() int {
+	return int(l.burst)
+}
+
+// AllowN списывает сразу n токенов из корзины key или не списывает ни одного.
+// Если токенов не хватает, возвращает false и время, через которое их станет достаточно.
+// Больше burst токенов не накопится никогда: для такого n возвращается false и нулевое время.
+func (l *Limiter) AllowN(key string, n int, now time.Time) (bool, time.Duration) {
+	if float64(n) > l.burst {
+		return false, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key, now)
+
+	// Пополняем корзину за время с прошлого обращения
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	if b.tokens < float64(n) {
+		wait := time.Duration((float64(n) - b.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens -= float64(n)
+	return true, 0
+}
+
+// Refund возвращает в корзину key n токенов, списанных для операции, которая не выполнялась.
+// Корзина не переполняется сверх burst; если ее уже вытеснили, возвращать некуда.
+func (l *Limiter) Refund(key string, n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.buckets[key]; ok {
+		b := el.Value.(*bucket)
+		b.tokens = math.Min(l.burst, b.tokens+float64(n))
+	}
+}
+
+// bucket возвращает корзину ключа, при необходимости создавая полную и вытесняя самую старую.
+func (l *Limiter) bucket(key string, now time.Time) *bucket {
+	if el, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(el)
+		return el.Value.(*bucket)
+	}
+
+	if l.lru.Len() >= l.maxKeys {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key)
+	}
+
+	b := &bucket{key: key, tokens: l.burst, updated: now}
+	l.buckets[key] = l.lru.PushFront(b)
+	return b
+}
@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"Habr-comments-server/internal/auth"
+	"Habr-comments-server/internal/config"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	PerUser = "user" // Ключ — ID пользователя (для анонимных запросов — IP)
+	PerIP   = "ip"
+)
+
+// Limits ограничивает частоту мутаций по правилам из конфигурации.
+type Limits struct {
+	rules map[string]rule // Имя поля Mutation -> правило
+	now   func() time.Time
+}
+
+type rule struct {
+	per     string
+	limiter *Limiter
+}
+
+// New проверяет правила из конфигурации и создает для каждого лимитер.
+func New(cfg config.RateLimit) (*Limits, error) {
+	const op = "ratelimit.New"
+
+	if cfg.MaxKeys <= 0 {
+		return nil, fmt.Errorf("%s: max_keys must be positive", op)
+	}
+
+	limits := &Limits{
+		rules: make(map[string]rule, len(cfg.Operations)),
+		now:   time.Now,
+	}
+	for field, r := range cfg.Operations {
+		if r.Per != PerUser && r.Per != PerIP {
+			return nil, fmt.Errorf("%s: %s: per must be %q or %q", op, field, PerUser, PerIP)
+		}
+		if r.Limit <= 0 || r.Interval <= 0 {
+			return nil, fmt.Errorf("%s: %s: limit and interval must be positive", op, field)
+		}
+
+		burst := r.Burst
+		if burst <= 0 {
+			burst = r.Limit
+		}
+
+		limits.rules[field] = rule{
+			per:     r.Per,
+			limiter: NewLimiter(r.Limit, r.Interval, burst, cfg.MaxKeys),
+		}
+	}
+
+	return limits, nil
+}
+
+// Middleware — операционный middleware gqlgen: проверяет лимиты до выполнения мутации.
+// Каждое поле Mutation в запросе (в том числе под разными алиасами) расходует отдельный токен.
+// Токены списываются по принципу «все или ничего»: если хотя бы одно поле превышает лимит,
+// операция не выполняется и уже списанные для других полей токены возвращаются.
+// Если полей больше, чем помещается в корзину, повтор не поможет: это ошибка запроса, а не лимит.
+func (l *Limits) Middleware(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil || opCtx.Operation.Operation != ast.Mutation {
+		return next(ctx)
+	}
+
+	// Сколько токенов нужно каждому правилу; поле запоминается для пути в ошибке
+	type charge struct {
+		field graphql.CollectedField
+		rule  rule
+		key   string
+		n     int
+	}
+	var charges []*charge
+	byName := make(map[string]*charge)
+	for _, field := range graphql.CollectFields(opCtx, opCtx.Operation.SelectionSet, []string{"Mutation"}) {
+		r, ok := l.rules[field.Name]
+		if !ok {
+			continue
+		}
+		if c, ok := byName[field.Name]; ok {
+			c.n++
+			continue
+		}
+		c := &charge{field: field, rule: r, key: key(ctx, r.per), n: 1}
+		byName[field.Name] = c
+		charges = append(charges, c)
+	}
+
+	for _, c := range charges {
+		if c.n > c.rule.limiter.Burst() {
+			return graphql.OneShot(&graphql.Response{Errors: gqlerror.List{tooManyFields(c.field, c.rule.limiter.Burst())}})
+		}
+	}
+
+	now := l.now()
+	for i, c := range charges {
+		allowed, retryAfter := c.rule.limiter.AllowN(c.key, c.n, now)
+		if !allowed {
+			for _, taken := range charges[:i] {
+				taken.rule.limiter.Refund(taken.key, taken.n)
+			}
+			return graphql.OneShot(&graphql.Response{Errors: gqlerror.List{rateLimited(c.field, retryAfter)}})
+		}
+	}
+
+	return next(ctx)
+}
+
+func key(ctx context.Context, per string) string {
+	if per == PerUser {
+		if userID, ok := auth.UserID(ctx); ok {
+			return "user:" + strconv.Itoa(userID)
+		}
+	}
+	return "ip:" + clientIP(ctx)
+}
+
+func rateLimited(field graphql.CollectedField, retryAfter time.Duration) *gqlerror.Error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("too many %s requests, retry in %d s", field.Name, seconds),
+		Path:    ast.Path{ast.PathName(field.Alias)},
+		Extensions: map[string]interface{}{
+			"code":       "RATE_LIMITED",
+			"retryAfter": seconds,
+		},
+	}
+}
+
+func tooManyFields(field graphql.CollectedField, burst int) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("too many %s fields in one request, at most %d allowed", field.Name, burst),
+		Path:    ast.Path{ast.PathName(field.Alias)},
+		Extensions: map[string]interface{}{
+			"code": "VALIDATION_FAILED",
+		},
+	}
+}
+
+type ipKey struct{}
+
+// ClientIPMiddleware кладет в контекст IP клиента для лимитов per: ip.
+// Берется адрес TCP-соединения: заголовкам X-Forwarded-For без доверенного прокси верить нельзя.
+func ClientIPMiddleware(next http.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ipKey{}, ip)))
+	})
+}
+
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(ipKey{}).(string)
+	return ip
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/validation"
+	"context"
+	"fmt"
+)
+
+// CommentService — правила работы с комментариями и голосами.
+type CommentService struct {
+	comments  CommentRepository
+	validator *validation.Validator
+}
+
+func NewCommentService(comments CommentRepository, validator *validation.Validator) *CommentService {
+	return &CommentService{
+		comments:  comments,
+		validator: validator,
+	}
+}
+
+func (s *CommentService) GetComment(ctx context.Context, id int) (models.Comment, error) {
+	return s.comments.GetComment(ctx, id)
+}
+
+func (s *CommentService) GetComments(ctx context.Context, postID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	return s.comments.GetComments(ctx, postID, sort, page)
+}
+
+func (s *CommentService) GetChildComments(ctx context.Context, parentID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	return s.comments.GetChildComments(ctx, parentID, sort, page)
+}
+
+// CreateComment проверяет входные данные и добавляет комментарий.
+// Запрет комментариев проверяет хранилище атомарно со вставкой (storage.ErrCommentsBlock).
+func (s *CommentService) CreateComment(ctx context.Context, postID, authorID int, parentID *int, content string) (models.Comment, error) {
+	const op = "service.CommentService.CreateComment"
+
+	if err := s.validator.Comment(ctx, postID, parentID, content); err != nil {
+		return models.Comment{}, err
+	}
+
+	comment, err := s.comments.CreateComment(ctx, postID, authorID, parentID, content)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return comment, nil
+}
+
+// UpdateComment меняет текст комментария.
+func (s *CommentService) UpdateComment(ctx context.Context, id int, content string) (models.Comment, error) {
+	const op = "service.CommentService.UpdateComment"
+
+	if err := s.validator.CommentUpdate(content); err != nil {
+		return models.Comment{}, err
+	}
+
+	if err := s.comments.UpdateComment(ctx, id, content); err != nil {
+		return models.Comment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.comments.GetComment(ctx, id)
+}
+
+// DeleteComment мягко удаляет комментарий и возвращает оставшуюся в дереве заглушку.
+func (s *CommentService) DeleteComment(ctx context.Context, id int) (models.Comment, error) {
+	const op = "service.CommentService.DeleteComment"
+
+	if err := s.comments.DeleteComment(ctx, id); err != nil {
+		return models.Comment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.comments.GetComment(ctx, id)
+}
+
+// VoteComment сохраняет голос пользователя: 1, -1 или 0, чтобы отозвать голос.
+func (s *CommentService) VoteComment(ctx context.Context, commentID, userID, value int) (models.Comment, error) {
+	const op = "service.CommentService.VoteComment"
+
+	if err := s.validator.Vote(value); err != nil {
+		return models.Comment{}, err
+	}
+
+	if err := s.comments.VoteComment(ctx, commentID, userID, value); err != nil {
+		return models.Comment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.comments.GetComment(ctx, commentID)
+}
+
+// GetUserVotes возвращает голоса пользователя в порядке commentIDs (0 — голоса нет).
+func (s *CommentService) GetUserVotes(ctx context.Context, userID int, commentIDs []int) ([]int, error) {
+	return s.comments.GetUserVotes(ctx, userID, commentIDs)
+}
+
+// GetCommentTree возвращает дерево комментариев поста в порядке обхода.
+// Отрицательные maxDepth и rootLimit означают отсутствие ограничения.
+func (s *CommentService) GetCommentTree(ctx context.Context, postID, maxDepth, rootLimit int) ([]models.CommentTreeNode, error) {
+	return s.comments.GetCommentTree(ctx, postID, maxDepth, rootLimit)
+}
+
+// GetCommentSubtree возвращает комментарий и его ответы не глубже maxDepth уровней под ним.
+// Отрицательный maxDepth означает отсутствие ограничения.
+func (s *CommentService) GetCommentSubtree(ctx context.Context, id, maxDepth int) ([]models.CommentTreeNode, error) {
+	return s.comments.GetCommentSubtree(ctx, id, maxDepth)
+}
+
+// GetAncestors возвращает не больше limit ближайших предков комментария от корня к родителю.
+// Отрицательный limit означает всю цепочку.
+func (s *CommentService) GetAncestors(ctx context.Context, id, limit int) ([]models.Comment, error) {
+	return s.comments.GetAncestors(ctx, id, limit)
+}
+
+func (s *CommentService) GetCommentContext(ctx context.Context, id, parentsAbove, siblings, repliesBelow int) (models.CommentContext, error) {
+	return s.comments.GetCommentContext(ctx, id, parentsAbove, siblings, repliesBelow)
+}
+
+func (s *CommentService) GetCommentsByID(ctx context.Context, ids []int) ([]*models.Comment, error) {
+	return s.comments.GetCommentsByID(ctx, ids)
+}
+
+func (s *CommentService) GetCommentStats(ctx context.Context, ids []int) ([]models.CommentStats, error) {
+	return s.comments.GetCommentStats(ctx, ids)
+}
+
+func (s *CommentService) GetRootCommentPages(ctx context.Context, postIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	return s.comments.GetRootCommentPages(ctx, postIDs, sort, page)
+}
+
+func (s *CommentService) GetChildCommentPages(ctx context.Context, parentIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	return s.comments.GetChildCommentPages(ctx, parentIDs, sort, page)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/validation"
+	"context"
+	"fmt"
+)
+
+// PostService — правила работы с постами.
+type PostService struct {
+	posts     PostRepository
+	validator *validation.Validator
+}
+
+func NewPostService(posts PostRepository, validator *validation.Validator) *PostService {
+	return &PostService{
+		posts:     posts,
+		validator: validator,
+	}
+}
+
+func (s *PostService) GetPost(ctx context.Context, id int) (models.Post, error) {
+	return s.posts.GetPost(ctx, id)
+}
+
+func (s *PostService) GetPostsByID(ctx context.Context, ids []int) ([]*models.Post, error) {
+	return s.posts.GetPostsByID(ctx, ids)
+}
+
+func (s *PostService) GetPostStats(ctx context.Context, ids []int) ([]models.PostStats, error) {
+	return s.posts.GetPostStats(ctx, ids)
+}
+
+func (s *PostService) GetPosts(ctx context.Context, page models.PageParams) (models.PostPage, error) {
+	return s.posts.GetPosts(ctx, page)
+}
+
+// CreatePost проверяет заголовок и текст и создает пост от имени authorID.
+func (s *PostService) CreatePost(ctx context.Context, authorID int, title, content string, allowComments bool) (models.Post, error) {
+	const op = "service.PostService.CreatePost"
+
+	if err := s.validator.Post(title, content); err != nil {
+		return models.Post{}, err
+	}
+
+	postID, err := s.posts.CreatePost(ctx, authorID, title, content, allowComments)
+	if err != nil {
+		return models.Post{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.posts.GetPost(ctx, postID)
+}
+
+// UpdatePost меняет заголовок и/или текст поста; nil-поля остаются без изменений.
+func (s *PostService) UpdatePost(ctx context.Context, id int, title, content *string) (models.Post, error) {
+	const op = "service.PostService.UpdatePost"
+
+	if err := s.validator.PostUpdate(title, content); err != nil {
+		return models.Post{}, err
+	}
+
+	if err := s.posts.UpdatePost(ctx, id, title, content); err != nil {
+		return models.Post{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.posts.GetPost(ctx, id)
+}
+
+// DeletePost удаляет пост вместе с комментариями.
+func (s *PostService) DeletePost(ctx context.Context, id int) error {
+	return s.posts.DeletePost(ctx, id)
+}
+
+// BlockComments запрещает новые комментарии к посту; пустая причина не сохраняется.
+func (s *PostService) BlockComments(ctx context.Context, id int, reason string) (models.Post, error) {
+	const op = "service.PostService.BlockComments"
+
+	if err := s.posts.BlockComments(ctx, id, reason); err != nil {
+		return models.Post{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.posts.GetPost(ctx, id)
+}
+
+// UnblockComments снова разрешает комментарии к посту.
+func (s *PostService) UnblockComments(ctx context.Context, id int) (models.Post, error) {
+	const op = "service.PostService.UnblockComments"
+
+	if err := s.posts.UnblockComments(ctx, id); err != nil {
+		return models.Post{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.posts.GetPost(ctx, id)
+}
@@ -2,34 +2,85 @@ package service
 
 import (
 	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/validation"
 	"context"
 )
 
 type Service struct {
-	PostService    PostService
-	CommentService CommentService
+	PostService    *PostService
+	CommentService *CommentService
+	UserService    *UserService
 }
 
-// Конструктор Service
-func NewService(postService PostService, commentService CommentService) *Service {
+// Конструктор Service: сервисы работают с хранилищем через интерфейсы репозиториев.
+func NewService(posts PostRepository, comments CommentRepository, users UserRepository, validator *validation.Validator) *Service {
 	return &Service{
-		PostService:    postService,
-		CommentService: commentService,
+		PostService:    NewPostService(posts, validator),
+		CommentService: NewCommentService(comments, validator),
+		UserService:    NewUserService(users, validator),
 	}
 }
 
-type PostService interface {
+type PostRepository interface {
 	GetPost(ctx context.Context, id int) (models.Post, error)
-	GetPosts(ctx context.Context, limit, offset int) ([]models.Post, error)
+	GetPosts(ctx context.Context, page models.PageParams) (models.PostPage, error)
 	CreatePost(ctx context.Context, authorId int, title, content string, allowComments bool) (int, error)
-	BlockComments(ctx context.Context, id int) error
-	GetUsersByID(ctx context.Context, ids []int) ([]*models.User, error)
+	// UpdatePost меняет заголовок и/или текст поста; nil-поля остаются без изменений.
+	UpdatePost(ctx context.Context, id int, title, content *string) error
+	DeletePost(ctx context.Context, id int) error
+	BlockComments(ctx context.Context, id int, reason string) error
+	UnblockComments(ctx context.Context, id int) error
+	// GetPostsByID возвращает посты в порядке ids; ненайденным соответствует nil.
+	GetPostsByID(ctx context.Context, ids []int) ([]*models.Post, error)
+	// GetPostStats возвращает счетчики комментариев постов в порядке ids.
+	GetPostStats(ctx context.Context, ids []int) ([]models.PostStats, error)
+}
+
+type CommentRepository interface {
+	GetComment(ctx context.Context, id int) (models.Comment, error)
+	GetComments(ctx context.Context, postID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error)
+	GetChildComments(ctx context.Context, parentID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error)
+	// CreateComment возвращает storage.ErrCommentsBlock, если комментарии к посту запрещены,
+	// и storage.ErrNotFound, если нет поста, автора или родителя.
+	CreateComment(ctx context.Context, postID, authorID int, parentID *int, content string) (models.Comment, error)
+	UpdateComment(ctx context.Context, id int, content string) error
+	// DeleteComment мягко удаляет комментарий: он остается в дереве, чтобы ответы не потеряли место.
+	DeleteComment(ctx context.Context, id int) error
+	// VoteComment сохраняет голос пользователя: 1, -1 или 0, чтобы отозвать голос.
+	// Для удаленного комментария возвращает storage.ErrNotFound.
+	VoteComment(ctx context.Context, commentID, userID, value int) error
+	// GetUserVotes возвращает голоса пользователя в порядке commentIDs (0 — голоса нет).
+	GetUserVotes(ctx context.Context, userID int, commentIDs []int) ([]int, error)
+	// GetCommentTree возвращает дерево комментариев поста в порядке обхода.
+	// Отрицательные maxDepth и rootLimit означают отсутствие ограничения.
+	GetCommentTree(ctx context.Context, postID, maxDepth, rootLimit int) ([]models.CommentTreeNode, error)
+	// GetCommentSubtree возвращает комментарий и его ответы в порядке обхода; глубина и путь —
+	// от корня дерева поста. Отрицательный maxDepth означает отсутствие ограничения.
+	GetCommentSubtree(ctx context.Context, id, maxDepth int) ([]models.CommentTreeNode, error)
+	// GetAncestors возвращает не больше limit ближайших предков комментария от корня к родителю.
+	// Отрицательный limit означает всю цепочку.
+	GetAncestors(ctx context.Context, id, limit int) ([]models.Comment, error)
+	GetCommentContext(ctx context.Context, id, parentsAbove, siblings, repliesBelow int) (models.CommentContext, error)
+	// GetCommentsByID возвращает комментарии в порядке ids; ненайденным соответствует nil.
+	GetCommentsByID(ctx context.Context, ids []int) ([]*models.Comment, error)
+	// GetCommentStats возвращает счетчики ответов комментариев в порядке ids.
+	GetCommentStats(ctx context.Context, ids []int) ([]models.CommentStats, error)
+	// GetRootCommentPages и GetChildCommentPages — пакетные GetComments и GetChildComments:
+	// страницы с одинаковыми параметрами для нескольких постов или комментариев в порядке ключей.
+	GetRootCommentPages(ctx context.Context, postIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error)
+	GetChildCommentPages(ctx context.Context, parentIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error)
 }
 
-type CommentService interface {
-	GetComments(ctx context.Context, postID int) ([]models.Comment, error)
-	GetChildComments(ctx context.Context, parentID int) ([]models.Comment, error)
-	CreateComment(ctx context.Context, postID, authorID int, parentID *int, content string) (int, error)
-	GetChildCommentsByParentID(ctx context.Context, parentIDs []int) ([][]*models.Comment, error)
-	GetCommentsByPostID(ctx context.Context, postIDs []int) ([][]*models.Comment, error)
+type UserRepository interface {
+	// CreateUser возвращает storage.ErrAlreadyExists, если имя уже занято.
+	CreateUser(ctx context.Context, username, passwordHash string, role models.Role) (int, error)
+	GetUser(ctx context.Context, id int) (models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (models.User, error)
+	SetUserRole(ctx context.Context, id int, role models.Role) error
+	GetUsersByID(ctx context.Context, ids []int) ([]*models.User, error)
+	GetUserPosts(ctx context.Context, userID int, page models.PageParams) (models.PostPage, error)
+	GetUserComments(ctx context.Context, userID int, page models.PageParams) (models.CommentPage, error)
+	// GetUserPostPages и GetUserCommentPages — пакетные GetUserPosts и GetUserComments в порядке userIDs.
+	GetUserPostPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.PostPage, error)
+	GetUserCommentPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.CommentPage, error)
 }
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"Habr-comments-server/internal/auth"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	"Habr-comments-server/internal/validation"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials возвращается и для неизвестного имени, и для неверного пароля,
+// чтобы по ответу нельзя было перебирать существующие имена.
+var ErrInvalidCredentials = fmt.Errorf("invalid username or password: %w", auth.ErrUnauthenticated)
+
+// UserService — регистрация, вход и профили пользователей.
+type UserService struct {
+	users     UserRepository
+	validator *validation.Validator
+}
+
+func NewUserService(users UserRepository, validator *validation.Validator) *UserService {
+	return &UserService{
+		users:     users,
+		validator: validator,
+	}
+}
+
+// Register проверяет имя и пароль и создает пользователя; пароль хранится в виде bcrypt-хеша.
+// Новый пользователь всегда получает роль USER: роль по выбранному имени дала бы права
+// администратора тому, кто первым зарегистрирует это имя. Администратора назначают
+// вне API — флагом сервера -make-admin.
+func (s *UserService) Register(ctx context.Context, username, password string) (models.User, error) {
+	const op = "service.UserService.Register"
+
+	username = strings.TrimSpace(username)
+	if err := s.validator.User(username, password); err != nil {
+		return models.User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: failed to hash password: %w", op, err)
+	}
+
+	userID, err := s.users.CreateUser(ctx, username, string(hash), models.RoleUser)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.users.GetUser(ctx, userID)
+}
+
+// Authenticate возвращает пользователя, если пароль верный.
+func (s *UserService) Authenticate(ctx context.Context, username, password string) (models.User, error) {
+	user, err := s.users.GetUserByUsername(ctx, strings.TrimSpace(username))
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	// У пользователей из сидов пароля нет, войти под ними нельзя
+	if user.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return models.User{}, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+func (s *UserService) GetUser(ctx context.Context, id int) (models.User, error) {
+	return s.users.GetUser(ctx, id)
+}
+
+// SetUserRole назначает роль и возвращает обновленного пользователя.
+func (s *UserService) SetUserRole(ctx context.Context, id int, role models.Role) (models.User, error) {
+	const op = "service.UserService.SetUserRole"
+
+	if !role.Valid() {
+		return models.User{}, fmt.Errorf("%s: unknown role %q: %w", op, role, storage.ErrValidation)
+	}
+
+	if err := s.users.SetUserRole(ctx, id, role); err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return s.users.GetUser(ctx, id)
+}
+
+func (s *UserService) GetUsersByID(ctx context.Context, ids []int) ([]*models.User, error) {
+	return s.users.GetUsersByID(ctx, ids)
+}
+
+func (s *UserService) GetUserPosts(ctx context.Context, userID int, page models.PageParams) (models.PostPage, error) {
+	return s.users.GetUserPosts(ctx, userID, page)
+}
+
+func (s *UserService) GetUserComments(ctx context.Context, userID int, page models.PageParams) (models.CommentPage, error) {
+	return s.users.GetUserComments(ctx, userID, page)
+}
+
+func (s *UserService) GetUserPostPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.PostPage, error) {
+	return s.users.GetUserPostPages(ctx, userIDs, page)
+}
+
+func (s *UserService) GetUserCommentPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.CommentPage, error) {
+	return s.users.GetUserCommentPages(ctx, userIDs, page)
+}
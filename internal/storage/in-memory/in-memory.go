@@ -3,54 +3,104 @@ package in_memory
 import (
 	"Habr-comments-server/internal/models"
 	"Habr-comments-server/internal/service"
+	"Habr-comments-server/internal/storage"
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
+	"unicode/utf8"
 )
 
-var _ service.PostService = (*InMemoryStorage)(nil)
-var _ service.CommentService = (*InMemoryStorage)(nil)
+var _ service.PostRepository = (*InMemoryStorage)(nil)
+var _ service.CommentRepository = (*InMemoryStorage)(nil)
+var _ service.UserRepository = (*InMemoryStorage)(nil)
+
+// maxCommentLen повторяет CHECK (char_length(content) <= 2000) из миграций.
+const maxCommentLen = 2000
 
 type InMemoryStorage struct {
-	posts    map[int]models.Post
-	comments map[int][]models.Comment
+	mu sync.RWMutex
+
+	posts    map[int]*models.Post
+	comments map[int]*models.Comment // Индекс комментариев по ID
 	users    map[int]models.User
-	mu       sync.RWMutex
+	votes    map[int]map[int]int // commentID -> userID -> голос
+
+	// Индексы хранят ID по возрастанию (createdAt, id), как индексы keyset-пагинации в PostgreSQL
+	postOrder    []int          // Все посты
+	postComments map[int][]int  // postID -> все комментарии поста
+	roots        map[int][]int  // postID -> корневые комментарии
+	children     map[int][]int  // parentID -> ответы
+	userPosts    map[int][]int  // authorID -> посты пользователя
+	userComments map[int][]int  // authorID -> комментарии пользователя
+	usernames    map[string]int // username -> userID
+
+	// Счетчики обновляются вместе с комментариями, как триггеры в PostgreSQL
+	postStats    map[int]models.PostStats
+	commentStats map[int]models.CommentStats
+
+	// Последовательности ID, как SERIAL: не уменьшаются при удалении, поэтому ID не переиспользуются
+	lastPostID    int
+	lastCommentID int
+	lastUserID    int
+
+	// Журнал изменений и снимки; nil, если данные хранятся только в памяти (см. Open)
+	wal     *wal
+	durable *durability
 }
 
 func NewInMemoryStorage() *InMemoryStorage {
 	return &InMemoryStorage{
-		posts:    make(map[int]models.Post),
-		comments: make(map[int][]models.Comment),
+		posts:    make(map[int]*models.Post),
+		comments: make(map[int]*models.Comment),
 		users:    make(map[int]models.User),
+		votes:    make(map[int]map[int]int),
+
+		postComments: make(map[int][]int),
+		roots:        make(map[int][]int),
+		children:     make(map[int][]int),
+		userPosts:    make(map[int][]int),
+		userComments: make(map[int][]int),
+		usernames:    make(map[string]int),
+
+		postStats:    make(map[int]models.PostStats),
+		commentStats: make(map[int]models.CommentStats),
 	}
 }
 
-// GetPosts возвращает посты с поддержкой пагинации (limit и offset).
-func (s *InMemoryStorage) GetPosts(ctx context.Context, limit, offset int) ([]models.Post, error) {
+// GetPosts возвращает страницу постов, новые сверху (как в pg.Storage).
+func (s *InMemoryStorage) GetPosts(ctx context.Context, page models.PageParams) (models.PostPage, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// Преобразуем карту постов в срез для сортировки
-	var posts []models.Post
-	for _, post := range s.posts {
-		posts = append(posts, post)
+	return s.pagePosts(s.postOrder, page), nil
+}
+
+// pagePosts вырезает страницу из упорядоченного индекса постов, новые сверху.
+// Вызывается под блокировкой.
+func (s *InMemoryStorage) pagePosts(ids []int, page models.PageParams) models.PostPage {
+	// Индекс идет от старых к новым: страница — это page.First постов перед курсором
+	end := len(ids)
+	if page.After != nil {
+		end = sort.Search(len(ids), func(i int) bool {
+			return !cursorLess(s.postCursor(ids[i]), *page.After)
+		})
 	}
 
-	// Пагинация: если offset больше длины среза, возвращаем пустой срез
-	if offset >= len(posts) {
-		return []models.Post{}, nil
+	result := models.PostPage{TotalCount: len(ids)}
+	start := end - page.First
+	if start > 0 {
+		result.HasNextPage = true
+	} else {
+		start = 0
 	}
 
-	// Вычисляем конечный индекс с учетом лимита
-	end := offset + limit
-	if end > len(posts) {
-		end = len(posts)
+	for i := end - 1; i >= start; i-- {
+		result.Posts = append(result.Posts, *s.posts[ids[i]])
 	}
 
-	// Возвращаем срез с постами согласно пагинации
-	return posts[offset:end], nil
+	return result
 }
 
 // GetPost возвращает пост по ID.
@@ -60,9 +110,9 @@ func (s *InMemoryStorage) GetPost(ctx context.Context, id int) (models.Post, err
 
 	post, ok := s.posts[id]
 	if !ok {
-		return models.Post{}, fmt.Errorf("post not found")
+		return models.Post{}, fmt.Errorf("post %d: %w", id, storage.ErrNotFound)
 	}
-	return post, nil
+	return *post, nil
 }
 
 // CreatePost создает новый пост.
@@ -70,127 +120,676 @@ func (s *InMemoryStorage) CreatePost(ctx context.Context, authorId int, title, c
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	id := len(s.posts) + 1
+	if _, ok := s.users[authorId]; !ok {
+		return 0, fmt.Errorf("user %d: %w", authorId, storage.ErrNotFound)
+	}
+
 	post := models.Post{
-		ID:            id,
+		ID:            s.lastPostID + 1,
 		AuthorId:      authorId,
 		Title:         title,
 		Content:       content,
 		AllowComments: allowComments,
-		CreatedAt:     time.Now(),
+		CreatedAt:     now(),
+	}
+	if !allowComments {
+		lockedAt := post.CreatedAt
+		post.CommentsLockedAt = &lockedAt
+	}
+
+	if err := s.commit(walRecord{Op: opCreatePost, Post: &post}); err != nil {
+		return 0, err
+	}
+	return post.ID, nil
+}
+
+// applyCreatePost добавляет пост в данные и индексы. Вызывается под блокировкой.
+func (s *InMemoryStorage) applyCreatePost(post models.Post) {
+	s.posts[post.ID] = &post
+	s.lastPostID = max(s.lastPostID, post.ID)
+	s.postOrder = insertSorted(s.postOrder, post.ID, s.postCursor)
+	s.userPosts[post.AuthorId] = insertSorted(s.userPosts[post.AuthorId], post.ID, s.postCursor)
+}
+
+// UpdatePost меняет заголовок и/или текст поста; nil-поля не трогаются.
+func (s *InMemoryStorage) UpdatePost(ctx context.Context, id int, title, content *string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[id]; !ok {
+		return fmt.Errorf("post %d: %w", id, storage.ErrNotFound)
+	}
+	return s.commit(walRecord{Op: opUpdatePost, ID: id, Title: title, Content: content})
+}
+
+// applyUpdatePost меняет заголовок и/или текст поста. Вызывается под блокировкой.
+func (s *InMemoryStorage) applyUpdatePost(id int, title, content *string) {
+	post := s.posts[id]
+	if title != nil {
+		post.Title = *title
+	}
+	if content != nil {
+		post.Content = *content
+	}
+}
+
+// DeletePost удаляет пост вместе с его комментариями и голосами (как ON DELETE CASCADE).
+// Если на комментарий поста отвечают из другого поста, пост не удаляется: внешний ключ
+// parent_id в SQL-хранилищах — NO ACTION, и они возвращают ту же ошибку.
+func (s *InMemoryStorage) DeletePost(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[id]; !ok {
+		return fmt.Errorf("post %d: %w", id, storage.ErrNotFound)
+	}
+	for _, commentID := range s.postComments[id] {
+		for _, childID := range s.children[commentID] {
+			if s.comments[childID].PostId != id {
+				return fmt.Errorf("post %d: comment %d has reply %d in another post: %w", id, commentID, childID, storage.ErrValidation)
+			}
+		}
+	}
+	return s.commit(walRecord{Op: opDeletePost, ID: id})
+}
+
+// applyDeletePost удаляет пост, его комментарии и голоса из данных и индексов.
+// Вызывается под блокировкой.
+func (s *InMemoryStorage) applyDeletePost(id int) {
+	post := s.posts[id]
+	for _, commentID := range s.postComments[id] {
+		s.deleteComment(s.comments[commentID])
+	}
+	for _, commentID := range s.postComments[id] {
+		delete(s.comments, commentID)
+	}
+
+	delete(s.postComments, id)
+	delete(s.roots, id)
+	delete(s.postStats, id)
+	delete(s.posts, id)
+	s.postOrder = removeID(s.postOrder, id)
+	s.userPosts[post.AuthorId] = removeID(s.userPosts[post.AuthorId], id)
+}
+
+// deleteComment убирает комментарий удаляемого поста из индексов. Ответов из других постов
+// у него нет (это проверяет DeletePost), а если сам он отвечает на комментарий другого поста,
+// уцелевшие предки теряют его поддерево, как в триггере comments_counters_delete.
+// Вызывается под блокировкой.
+func (s *InMemoryStorage) deleteComment(comment *models.Comment) {
+	if comment.ParentId != nil {
+		if parent := s.comments[*comment.ParentId]; parent.PostId != comment.PostId {
+			s.children[parent.ID] = removeID(s.children[parent.ID], comment.ID)
+
+			lost := 1 + s.commentStats[comment.ID].DescendantsCount
+			stats := s.commentStats[parent.ID]
+			stats.RepliesCount--
+			stats.DescendantsCount -= lost
+			s.commentStats[parent.ID] = stats
+			for _, ancestor := range s.ancestors(parent.ID, -1) {
+				stats := s.commentStats[ancestor.ID]
+				stats.DescendantsCount -= lost
+				s.commentStats[ancestor.ID] = stats
+			}
+		}
 	}
-	s.posts[id] = post
-	return id, nil
+
+	delete(s.children, comment.ID)
+	delete(s.votes, comment.ID)
+	delete(s.commentStats, comment.ID)
+	s.userComments[comment.AuthorId] = removeID(s.userComments[comment.AuthorId], comment.ID)
 }
 
 // BlockComments блокирует комментарии для поста.
-func (s *InMemoryStorage) BlockComments(ctx context.Context, id int) error {
+func (s *InMemoryStorage) BlockComments(ctx context.Context, id int, reason string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	post, ok := s.posts[id]
-	if !ok {
-		return fmt.Errorf("post not found")
+	if _, ok := s.posts[id]; !ok {
+		return fmt.Errorf("post %d: %w", id, storage.ErrNotFound)
 	}
+	lockedAt := now()
+	return s.commit(walRecord{Op: opBlockComments, ID: id, Reason: reason, At: &lockedAt})
+}
+
+// applyBlockComments запрещает комментарии к посту. Вызывается под блокировкой.
+func (s *InMemoryStorage) applyBlockComments(id int, reason string, lockedAt time.Time) {
+	post := s.posts[id]
 	post.AllowComments = false
-	s.posts[id] = post
-	return nil
+	post.CommentsLockedAt = &lockedAt
+	post.CommentsLockedReason = nil
+	if reason != "" {
+		post.CommentsLockedReason = &reason
+	}
 }
 
-// GetComments возвращает комментарии для поста.
-func (s *InMemoryStorage) GetComments(ctx context.Context, postID int) ([]models.Comment, error) {
+// UnblockComments снова разрешает комментарии к посту.
+func (s *InMemoryStorage) UnblockComments(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.posts[id]; !ok {
+		return fmt.Errorf("post %d: %w", id, storage.ErrNotFound)
+	}
+	return s.commit(walRecord{Op: opUnblockComments, ID: id})
+}
+
+// applyUnblockComments снова разрешает комментарии к посту. Вызывается под блокировкой.
+func (s *InMemoryStorage) applyUnblockComments(id int) {
+	post := s.posts[id]
+	post.AllowComments = true
+	post.CommentsLockedAt = nil
+	post.CommentsLockedReason = nil
+}
+
+// GetComments возвращает страницу корневых комментариев поста.
+func (s *InMemoryStorage) GetComments(ctx context.Context, postID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.pageComments(s.roots[postID], sort, page)
+}
+
+// GetChildComments возвращает страницу дочерних комментариев для комментария.
+func (s *InMemoryStorage) GetChildComments(ctx context.Context, parentID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	comments, ok := s.comments[postID]
+	return s.pageComments(s.children[parentID], sort, page)
+}
+
+// pageComments упорядочивает комментарии из индекса так же, как pg.Storage,
+// и вырезает страницу после курсора. Вызывается под блокировкой.
+func (s *InMemoryStorage) pageComments(ids []int, order models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	type entry struct {
+		comment *models.Comment
+		cursor  models.Cursor
+	}
+
+	entries := make([]entry, len(ids))
+	for i, id := range ids {
+		c := s.comments[id]
+		cursor := c.Cursor()
+		switch order {
+		case models.SortOldest, models.SortNewest:
+		case models.SortTop:
+			cursor.Value = c.Score()
+		case models.SortControversial:
+			cursor.Value = min(c.Upvotes, c.Downvotes)
+		case models.SortMostReplies:
+			cursor.Value = s.commentStats[c.ID].RepliesCount
+		default:
+			return models.CommentPage{}, fmt.Errorf("unknown sort %q: %w", order, storage.ErrValidation)
+		}
+		entries[i] = entry{comment: c, cursor: cursor}
+	}
+
+	// before сообщает, идет ли a раньше b в выбранном порядке
+	before := func(a, b models.Cursor) bool {
+		if order.Descending() {
+			return cursorLess(b, a)
+		}
+		return cursorLess(a, b)
+	}
+
+	// Индекс уже упорядочен по времени; пересортировка нужна только для ключей-счетчиков
+	switch order {
+	case models.SortOldest:
+	case models.SortNewest:
+		for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+			entries[i], entries[j] = entries[j], entries[i]
+		}
+	default:
+		sort.Slice(entries, func(i, j int) bool {
+			return before(entries[i].cursor, entries[j].cursor)
+		})
+	}
+
+	start := 0
+	if page.After != nil {
+		start = sort.Search(len(entries), func(i int) bool {
+			return before(*page.After, entries[i].cursor)
+		})
+	}
+
+	result := models.CommentPage{TotalCount: len(entries)}
+	end := start + page.First
+	if end < len(entries) {
+		result.HasNextPage = true
+	} else {
+		end = len(entries)
+	}
+
+	for _, e := range entries[start:end] {
+		result.Comments = append(result.Comments, *e.comment)
+		result.Cursors = append(result.Cursors, e.cursor)
+	}
+
+	return result, nil
+}
+
+// GetCommentTree возвращает дерево комментариев поста в порядке обхода pre-order.
+func (s *InMemoryStorage) GetCommentTree(ctx context.Context, postID, maxDepth, rootLimit int) ([]models.CommentTreeNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roots := s.roots[postID]
+	if rootLimit >= 0 && rootLimit < len(roots) {
+		roots = roots[:rootLimit]
+	}
+
+	var tree []models.CommentTreeNode
+	for _, root := range roots {
+		tree = s.appendSubtree(tree, root, 0, []int{}, maxDepth)
+	}
+
+	return tree, nil
+}
+
+// GetCommentSubtree возвращает комментарий и его ответы в порядке обхода pre-order.
+// maxDepth ограничивает число уровней ответов под комментарием, отрицательный — без ограничения.
+// Глубина и путь узлов считаются от корня дерева поста, как в GetCommentTree.
+func (s *InMemoryStorage) GetCommentSubtree(ctx context.Context, id, maxDepth int) ([]models.CommentTreeNode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.comments[id]; !ok {
+		return nil, nil
+	}
+
+	ancestors := s.ancestors(id, -1)
+	path := make([]int, len(ancestors))
+	for i, a := range ancestors {
+		path[i] = a.ID
+	}
+
+	return s.appendSubtree(nil, id, len(path), path, maxDepth), nil
+}
+
+// appendSubtree дописывает к tree комментарий id и его ответы не глубже levels уровней
+// (отрицательный levels — без ограничения).
+func (s *InMemoryStorage) appendSubtree(tree []models.CommentTreeNode, id, depth int, path []int, levels int) []models.CommentTreeNode {
+	tree = append(tree, models.CommentTreeNode{Comment: *s.comments[id], Depth: depth, Path: path})
+	if levels == 0 {
+		return tree
+	}
+
+	childPath := append(append(make([]int, 0, len(path)+1), path...), id)
+	for _, child := range s.children[id] {
+		tree = s.appendSubtree(tree, child, depth+1, childPath, levels-1)
+	}
+	return tree
+}
+
+// GetComment возвращает комментарий по ID.
+func (s *InMemoryStorage) GetComment(ctx context.Context, id int) (models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comment, ok := s.comments[id]
 	if !ok {
-		return nil, fmt.Errorf("comments not found for post")
+		return models.Comment{}, fmt.Errorf("comment %d: %w", id, storage.ErrNotFound)
 	}
-	return comments, nil
+	return *comment, nil
 }
 
-// GetChildComments возвращает дочерние комментарии для комментария.
-func (s *InMemoryStorage) GetChildComments(ctx context.Context, parentID int) ([]models.Comment, error) {
+// GetAncestors возвращает предков комментария от корня к родителю.
+func (s *InMemoryStorage) GetAncestors(ctx context.Context, id, limit int) ([]models.Comment, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var childComments []models.Comment
-	for _, comment := range s.comments {
-		for _, c := range comment {
-			if c.ParentId != nil && *c.ParentId == parentID {
-				childComments = append(childComments, c)
-			}
+	return s.ancestors(id, limit), nil
+}
+
+// GetCommentContext возвращает комментарий с предками, соседями того же уровня и первыми ответами.
+func (s *InMemoryStorage) GetCommentContext(ctx context.Context, id, parentsAbove, siblings, repliesBelow int) (models.CommentContext, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comment, ok := s.comments[id]
+	if !ok {
+		return models.CommentContext{}, fmt.Errorf("comment %d: %w", id, storage.ErrNotFound)
+	}
+
+	result := models.CommentContext{
+		Comment:   *comment,
+		Ancestors: s.ancestors(id, parentsAbove),
+	}
+
+	// Соседи — комментарии того же поста с тем же родителем (или тоже корневые)
+	level := s.roots[comment.PostId]
+	if comment.ParentId != nil {
+		level = s.children[*comment.ParentId]
+	}
+	pos := sort.Search(len(level), func(i int) bool {
+		return !cursorLess(s.commentCursor(level[i]), comment.Cursor())
+	})
+
+	for i := pos - 1; i >= 0 && len(result.SiblingsBefore) < siblings; i-- {
+		if c := s.comments[level[i]]; c.PostId == comment.PostId {
+			result.SiblingsBefore = append(result.SiblingsBefore, *c)
+		}
+	}
+	before := result.SiblingsBefore
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+
+	for i := pos + 1; i < len(level) && len(result.SiblingsAfter) < siblings; i++ {
+		if c := s.comments[level[i]]; c.PostId == comment.PostId {
+			result.SiblingsAfter = append(result.SiblingsAfter, *c)
+		}
+	}
+
+	replies, err := s.pageComments(s.children[id], models.SortOldest, models.PageParams{First: repliesBelow})
+	if err != nil {
+		return models.CommentContext{}, err
+	}
+	result.Replies = replies.Comments
+
+	return result, nil
+}
+
+// ancestors поднимается по parentId не больше чем на limit уровней (limit < 0 — до корня)
+// и возвращает предков от корня к родителю. Вызывается под блокировкой.
+func (s *InMemoryStorage) ancestors(id, limit int) []models.Comment {
+	if limit == 0 {
+		return []models.Comment{}
+	}
+
+	var result []models.Comment
+	comment, ok := s.comments[id]
+	for ok && comment.ParentId != nil && (limit < 0 || len(result) < limit) {
+		comment, ok = s.comments[*comment.ParentId]
+		if ok {
+			result = append(result, *comment)
 		}
 	}
-	return childComments, nil
+
+	// Собирали от родителя к корню — разворачиваем
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
 }
 
-// CreateComment создает новый комментарий.
-func (s *InMemoryStorage) CreateComment(ctx context.Context, postID, authorID int, parentID *int, content string) (int, error) {
+// CreateComment создает новый комментарий и возвращает его.
+// Разрешение комментариев проверяется под той же блокировкой, что и вставка.
+func (s *InMemoryStorage) CreateComment(ctx context.Context, postID, authorID int, parentID *int, content string) (models.Comment, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	id := len(s.comments) + 1
+	// Те же проверки, что дают внешние ключи и CHECK в PostgreSQL
+	post, ok := s.posts[postID]
+	if !ok {
+		return models.Comment{}, fmt.Errorf("post %d: %w", postID, storage.ErrNotFound)
+	}
+	if !post.AllowComments {
+		return models.Comment{}, fmt.Errorf("post %d: %w", postID, storage.ErrCommentsBlock)
+	}
+	if utf8.RuneCountInString(content) > maxCommentLen {
+		return models.Comment{}, fmt.Errorf("comment is longer than %d characters: %w", maxCommentLen, storage.ErrValidation)
+	}
+	if _, ok := s.users[authorID]; !ok {
+		return models.Comment{}, fmt.Errorf("user %d: %w", authorID, storage.ErrNotFound)
+	}
+	if parentID != nil {
+		if _, ok := s.comments[*parentID]; !ok {
+			return models.Comment{}, fmt.Errorf("parent comment %d: %w", *parentID, storage.ErrNotFound)
+		}
+	}
+
 	comment := models.Comment{
-		ID:        id,
+		ID:        s.lastCommentID + 1,
 		PostId:    postID,
 		AuthorId:  authorID,
-		ParentId:  parentID,
 		Content:   content,
-		CreatedAt: time.Now(),
+		CreatedAt: now(),
+	}
+	if parentID != nil {
+		parent := *parentID
+		comment.ParentId = &parent
+	}
+
+	if err := s.commit(walRecord{Op: opCreateComment, Comment: &comment}); err != nil {
+		return models.Comment{}, err
+	}
+	return comment, nil
+}
+
+// applyCreateComment добавляет комментарий в данные, индексы и счетчики. Вызывается под блокировкой.
+func (s *InMemoryStorage) applyCreateComment(comment models.Comment) {
+	id := comment.ID
+	s.comments[id] = &comment
+	s.lastCommentID = max(s.lastCommentID, id)
+
+	s.postComments[comment.PostId] = insertSorted(s.postComments[comment.PostId], id, s.commentCursor)
+	if comment.ParentId == nil {
+		s.roots[comment.PostId] = insertSorted(s.roots[comment.PostId], id, s.commentCursor)
+	} else {
+		s.children[*comment.ParentId] = insertSorted(s.children[*comment.ParentId], id, s.commentCursor)
+	}
+	s.userComments[comment.AuthorId] = insertSorted(s.userComments[comment.AuthorId], id, s.commentCursor)
+
+	s.countComment(comment)
+}
+
+// countComment обновляет счетчики поста и предков нового комментария. Вызывается под блокировкой.
+func (s *InMemoryStorage) countComment(comment models.Comment) {
+	postStats := s.postStats[comment.PostId]
+	postStats.CommentsCount++
+	if postStats.LastCommentAt == nil || comment.CreatedAt.After(*postStats.LastCommentAt) {
+		createdAt := comment.CreatedAt
+		postStats.LastCommentAt = &createdAt
+	}
+	s.postStats[comment.PostId] = postStats
+
+	if comment.ParentId == nil {
+		return
+	}
+
+	parentStats := s.commentStats[*comment.ParentId]
+	parentStats.RepliesCount++
+	parentStats.DescendantsCount++
+	s.commentStats[*comment.ParentId] = parentStats
+
+	for _, ancestor := range s.ancestors(*comment.ParentId, -1) {
+		stats := s.commentStats[ancestor.ID]
+		stats.DescendantsCount++
+		s.commentStats[ancestor.ID] = stats
+	}
+}
+
+// UpdateComment меняет текст комментария и отмечает время редактирования.
+func (s *InMemoryStorage) UpdateComment(ctx context.Context, id int, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.comments[id]
+	if !ok || comment.IsDeleted {
+		return fmt.Errorf("comment %d: %w", id, storage.ErrNotFound)
+	}
+	if utf8.RuneCountInString(content) > maxCommentLen {
+		return fmt.Errorf("comment is longer than %d characters: %w", maxCommentLen, storage.ErrValidation)
+	}
+
+	editedAt := now()
+	return s.commit(walRecord{Op: opUpdateComment, ID: id, Content: &content, At: &editedAt})
+}
+
+// applyUpdateComment меняет текст комментария. Вызывается под блокировкой.
+func (s *InMemoryStorage) applyUpdateComment(id int, content string, editedAt time.Time) {
+	comment := s.comments[id]
+	comment.Content = content
+	comment.EditedAt = &editedAt
+}
+
+// DeleteComment мягко удаляет комментарий: он остается в дереве без текста.
+func (s *InMemoryStorage) DeleteComment(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.comments[id]; !ok {
+		return fmt.Errorf("comment %d: %w", id, storage.ErrNotFound)
+	}
+	return s.commit(walRecord{Op: opDeleteComment, ID: id})
+}
+
+// applyDeleteComment стирает текст комментария. Вызывается под блокировкой.
+func (s *InMemoryStorage) applyDeleteComment(id int) {
+	comment := s.comments[id]
+	comment.Content = ""
+	comment.IsDeleted = true
+}
+
+// VoteComment сохраняет голос пользователя (1, -1 или 0 — отозвать) и обновляет счетчики комментария.
+func (s *InMemoryStorage) VoteComment(ctx context.Context, commentID, userID, value int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// За удаленный комментарий голосовать нельзя, как за несуществующий
+	if comment, ok := s.comments[commentID]; !ok || comment.IsDeleted {
+		return fmt.Errorf("comment %d: %w", commentID, storage.ErrNotFound)
 	}
 
-	s.comments[postID] = append(s.comments[postID], comment)
-	return id, nil
+	if s.votes[commentID][userID] == value {
+		return nil
+	}
+
+	// CHECK на значение голоса срабатывает раньше внешнего ключа на пользователя
+	if value != 0 {
+		if value < -1 || value > 1 {
+			return fmt.Errorf("vote value %d: %w", value, storage.ErrValidation)
+		}
+		if _, ok := s.users[userID]; !ok {
+			return fmt.Errorf("user %d: %w", userID, storage.ErrNotFound)
+		}
+	}
+	return s.commit(walRecord{Op: opVoteComment, ID: commentID, UserID: userID, Value: value})
 }
 
-// GetUsersByID возвращает пользователей по ID.
-func (s *InMemoryStorage) GetUsersByID(ctx context.Context, ids []int) ([]*models.User, error) {
+// applyVoteComment сохраняет голос и обновляет счетчики комментария. Вызывается под блокировкой.
+func (s *InMemoryStorage) applyVoteComment(commentID, userID, value int) {
+	old := s.votes[commentID][userID]
+	if value == 0 {
+		delete(s.votes[commentID], userID)
+	} else {
+		if s.votes[commentID] == nil {
+			s.votes[commentID] = make(map[int]int)
+		}
+		s.votes[commentID][userID] = value
+	}
+
+	comment := s.comments[commentID]
+
+	switch old {
+	case 1:
+		comment.Upvotes--
+	case -1:
+		comment.Downvotes--
+	}
+	switch value {
+	case 1:
+		comment.Upvotes++
+	case -1:
+		comment.Downvotes++
+	}
+}
+
+// GetUserVotes возвращает голоса пользователя в порядке commentIDs (0 — голоса нет).
+func (s *InMemoryStorage) GetUserVotes(ctx context.Context, userID int, commentIDs []int) ([]int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var users []*models.User
-	for _, id := range ids {
-		user, ok := s.users[id]
-		if ok {
-			users = append(users, &user)
+	result := make([]int, len(commentIDs))
+	for i, id := range commentIDs {
+		result[i] = s.votes[id][userID]
+	}
+	return result, nil
+}
+
+// GetPostsByID возвращает посты в порядке ids; ненайденным соответствует nil.
+func (s *InMemoryStorage) GetPostsByID(ctx context.Context, ids []int) ([]*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := make([]*models.Post, len(ids))
+	for i, id := range ids {
+		if post, ok := s.posts[id]; ok {
+			p := *post
+			posts[i] = &p
 		}
 	}
-	return users, nil
+	return posts, nil
 }
 
-// GetCommentsByPostID возвращает комментарии для нескольких постов.
-func (s *InMemoryStorage) GetCommentsByPostID(ctx context.Context, postIDs []int) ([][]*models.Comment, error) {
+// GetCommentsByID возвращает комментарии в порядке ids; ненайденным соответствует nil.
+func (s *InMemoryStorage) GetCommentsByID(ctx context.Context, ids []int) ([]*models.Comment, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var result [][]*models.Comment
-	for _, postID := range postIDs {
-		var comments []*models.Comment
-		for _, c := range s.comments[postID] {
-			comments = append(comments, &c)
+	comments := make([]*models.Comment, len(ids))
+	for i, id := range ids {
+		if comment, ok := s.comments[id]; ok {
+			c := *comment
+			comments[i] = &c
 		}
-		result = append(result, comments)
 	}
-	return result, nil
+	return comments, nil
 }
 
-// GetChildCommentsByParentID возвращает дочерние комментарии для нескольких комментариев.
-func (s *InMemoryStorage) GetChildCommentsByParentID(ctx context.Context, parentIDs []int) ([][]*models.Comment, error) {
+// GetPostStats возвращает счетчики комментариев постов в порядке ids.
+func (s *InMemoryStorage) GetPostStats(ctx context.Context, ids []int) ([]models.PostStats, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var result [][]*models.Comment
-	for _, parentID := range parentIDs {
-		var comments []*models.Comment
-		for _, commentList := range s.comments {
-			for _, c := range commentList {
-				if c.ParentId != nil && *c.ParentId == parentID {
-					comments = append(comments, &c)
-				}
-			}
+	stats := make([]models.PostStats, len(ids))
+	for i, id := range ids {
+		stats[i] = s.postStats[id]
+	}
+	return stats, nil
+}
+
+// GetCommentStats возвращает счетчики ответов комментариев в порядке ids.
+func (s *InMemoryStorage) GetCommentStats(ctx context.Context, ids []int) ([]models.CommentStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make([]models.CommentStats, len(ids))
+	for i, id := range ids {
+		stats[i] = s.commentStats[id]
+	}
+	return stats, nil
+}
+
+// GetRootCommentPages возвращает страницы корневых комментариев для нескольких постов.
+func (s *InMemoryStorage) GetRootCommentPages(ctx context.Context, postIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pages := make([]models.CommentPage, len(postIDs))
+	for i, postID := range postIDs {
+		p, err := s.pageComments(s.roots[postID], sort, page)
+		if err != nil {
+			return nil, err
 		}
-		result = append(result, comments)
+		pages[i] = p
 	}
-	return result, nil
+	return pages, nil
+}
+
+// GetChildCommentPages возвращает страницы ответов для нескольких комментариев.
+func (s *InMemoryStorage) GetChildCommentPages(ctx context.Context, parentIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pages := make([]models.CommentPage, len(parentIDs))
+	for i, parentID := range parentIDs {
+		p, err := s.pageComments(s.children[parentID], sort, page)
+		if err != nil {
+			return nil, err
+		}
+		pages[i] = p
+	}
+	return pages, nil
 }
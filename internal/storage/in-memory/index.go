@@ -0,0 +1,58 @@
+package in_memory
+
+import (
+	"Habr-comments-server/internal/models"
+	"sort"
+	"time"
+)
+
+// now возвращает текущее время с точностью TIMESTAMP в PostgreSQL (микросекунды, UTC),
+// чтобы курсоры и сравнения времени вели себя так же, как в pg.Storage.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// insertSorted вставляет id в список, упорядоченный по возрастанию cursor.
+// Новые записи почти всегда оказываются в конце, так что обычно это просто append.
+func insertSorted(ids []int, id int, cursor func(id int) models.Cursor) []int {
+	key := cursor(id)
+	i := sort.Search(len(ids), func(i int) bool {
+		return cursorLess(key, cursor(ids[i]))
+	})
+
+	ids = append(ids, 0)
+	copy(ids[i+1:], ids[i:])
+	ids[i] = id
+	return ids
+}
+
+// removeID удаляет id из списка, сохраняя порядок остальных.
+func removeID(ids []int, id int) []int {
+	for i, v := range ids {
+		if v == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+// cursorLess сравнивает ключи пагинации так же, как row-сравнение (value, created_at, id) в Postgres.
+func cursorLess(a, b models.Cursor) bool {
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// postCursor возвращает ключ поста для индексов. Вызывается под блокировкой.
+func (s *InMemoryStorage) postCursor(id int) models.Cursor {
+	return s.posts[id].Cursor()
+}
+
+// commentCursor возвращает ключ комментария для индексов. Вызывается под блокировкой.
+func (s *InMemoryStorage) commentCursor(id int) models.Cursor {
+	return s.comments[id].Cursor()
+}
@@ -0,0 +1,358 @@
+package in_memory
+
+import (
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const snapshotFile = "snapshot.json"
+
+// snapshot — сжатое состояние хранилища. Покрывает все сегменты журнала с номерами меньше Segment.
+type snapshot struct {
+	Segment int `json:"segment"`
+
+	LastPostID    int `json:"lastPostId"`
+	LastCommentID int `json:"lastCommentId"`
+	LastUserID    int `json:"lastUserId"`
+
+	Users    []userRecord     `json:"users"`
+	Posts    []models.Post    `json:"posts"`
+	Comments []models.Comment `json:"comments"` // По возрастанию ID: родитель всегда раньше ответов
+	Votes    []voteRecord     `json:"votes"`
+}
+
+type voteRecord struct {
+	CommentID int `json:"commentId"`
+	UserID    int `json:"userId"`
+	Value     int `json:"value"`
+}
+
+// durability — фоновые fsync и снимки долговременного режима.
+type durability struct {
+	cfg config.InMemory
+	log *slog.Logger
+
+	snapshotMu sync.Mutex // Снимки по таймеру и при остановке не пишутся одновременно
+	stopOnce   sync.Once
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// Open создает хранилище. Если задан cfg.DataDir, состояние восстанавливается из последнего
+// снимка и хвоста журнала, а все последующие изменения пишутся в журнал до применения.
+func Open(cfg config.InMemory, log *slog.Logger) (*InMemoryStorage, error) {
+	const op = "storage.in-memory.Open"
+
+	s := NewInMemoryStorage()
+	if cfg.DataDir == "" {
+		return s, nil
+	}
+
+	switch cfg.Fsync {
+	case FsyncAlways, FsyncNever:
+	case FsyncInterval:
+		if cfg.FsyncInterval <= 0 {
+			return nil, fmt.Errorf("%s: fsync_interval must be positive", op)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown fsync mode %q", op, cfg.Fsync)
+	}
+
+	if err := os.MkdirAll(cfg.DataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("%s: failed to create data dir: %w", op, err)
+	}
+
+	w, err := s.recover(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	s.wal = w
+
+	s.durable = &durability{
+		cfg:  cfg,
+		log:  log,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go s.background()
+
+	return s, nil
+}
+
+// recover загружает снимок, применяет сегменты журнала после него и открывает последний сегмент на дозапись.
+func (s *InMemoryStorage) recover(cfg config.InMemory) (*wal, error) {
+	segment, err := s.loadSnapshot(filepath.Join(cfg.DataDir, snapshotFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	segments, err := listSegments(cfg.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log segments: %w", err)
+	}
+
+	// Записи из журнала еще не вошли в снимок: их число учитывается при следующем снимке
+	replayed := 0
+	apply := func(rec walRecord) error {
+		replayed++
+		return s.apply(rec)
+	}
+
+	last, size := segment, int64(0)
+	for _, n := range segments {
+		path := filepath.Join(cfg.DataDir, segmentName(n))
+		if n < segment {
+			// Сегмент уже в снимке; остался, если процесс упал сразу после снимка
+			if err = os.Remove(path); err != nil {
+				return nil, fmt.Errorf("failed to remove old log segment: %w", err)
+			}
+			continue
+		}
+
+		last = n
+		size, err = replaySegment(path, apply)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replay log: %w", err)
+		}
+	}
+
+	w, err := openWAL(cfg.DataDir, cfg.Fsync, last, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log: %w", err)
+	}
+	w.records = replayed
+	return w, nil
+}
+
+// loadSnapshot восстанавливает данные из снимка и возвращает номер первого не вошедшего в него сегмента.
+// Без снимка хранилище остается пустым, а журнал читается с начала.
+func (s *InMemoryStorage) loadSnapshot(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var snap snapshot
+	if err = json.Unmarshal(data, &snap); err != nil {
+		return 0, err
+	}
+
+	// Индексы и счетчики не хранятся в снимке, а строятся заново теми же функциями, что и при записи
+	for _, user := range snap.Users {
+		s.applyCreateUser(user.user())
+	}
+	for _, post := range snap.Posts {
+		s.applyCreatePost(post)
+	}
+	for _, comment := range snap.Comments {
+		s.applyCreateComment(comment)
+	}
+	for _, vote := range snap.Votes {
+		if s.votes[vote.CommentID] == nil {
+			s.votes[vote.CommentID] = make(map[int]int)
+		}
+		s.votes[vote.CommentID][vote.UserID] = vote.Value
+	}
+
+	// Последовательности не откатываются к ID удаленных записей
+	s.lastPostID = max(s.lastPostID, snap.LastPostID)
+	s.lastCommentID = max(s.lastCommentID, snap.LastCommentID)
+	s.lastUserID = max(s.lastUserID, snap.LastUserID)
+
+	return snap.Segment, nil
+}
+
+// Snapshot сохраняет снимок данных и удаляет покрытые им сегменты журнала.
+// Если изменений с прошлого снимка не было, ничего не делает.
+func (s *InMemoryStorage) Snapshot() error {
+	const op = "storage.in-memory.Snapshot"
+
+	if s.durable == nil {
+		return nil
+	}
+
+	s.durable.snapshotMu.Lock()
+	defer s.durable.snapshotMu.Unlock()
+
+	// Под блокировкой только копируем данные и переключаем сегмент; запись файла идет без нее
+	s.mu.Lock()
+	if s.wal == nil || s.wal.records == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	snap := s.copyState()
+	segment, err := s.wal.rotate()
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("%s: failed to rotate log: %w", op, err)
+	}
+	snap.Segment = segment
+
+	dir := s.durable.cfg.DataDir
+	if err = writeSnapshot(dir, snap); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return fmt.Errorf("%s: failed to list log segments: %w", op, err)
+	}
+	for _, n := range segments {
+		if n >= segment {
+			break
+		}
+		if err = os.Remove(filepath.Join(dir, segmentName(n))); err != nil {
+			return fmt.Errorf("%s: failed to remove old log segment: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// copyState копирует текущее состояние для снимка. Вызывается под блокировкой.
+func (s *InMemoryStorage) copyState() snapshot {
+	snap := snapshot{
+		LastPostID:    s.lastPostID,
+		LastCommentID: s.lastCommentID,
+		LastUserID:    s.lastUserID,
+		Users:         make([]userRecord, 0, len(s.users)),
+		Posts:         make([]models.Post, 0, len(s.posts)),
+		Comments:      make([]models.Comment, 0, len(s.comments)),
+		Votes:         []voteRecord{},
+	}
+
+	for _, user := range s.users {
+		snap.Users = append(snap.Users, *newUserRecord(user))
+	}
+	for _, id := range s.postOrder {
+		snap.Posts = append(snap.Posts, *s.posts[id])
+	}
+	for _, comment := range s.comments {
+		snap.Comments = append(snap.Comments, *comment)
+	}
+	for commentID, votes := range s.votes {
+		for userID, value := range votes {
+			snap.Votes = append(snap.Votes, voteRecord{CommentID: commentID, UserID: userID, Value: value})
+		}
+	}
+
+	sort.Slice(snap.Users, func(i, j int) bool { return snap.Users[i].ID < snap.Users[j].ID })
+	sort.Slice(snap.Comments, func(i, j int) bool { return snap.Comments[i].ID < snap.Comments[j].ID })
+
+	return snap
+}
+
+// writeSnapshot атомарно заменяет файл снимка: пишет во временный файл и переименовывает его.
+func writeSnapshot(dir string, snap snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmp := filepath.Join(dir, snapshotFile+".tmp")
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err = os.Rename(tmp, filepath.Join(dir, snapshotFile)); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+	if err = syncDir(dir); err != nil {
+		return fmt.Errorf("failed to sync data dir: %w", err)
+	}
+
+	return nil
+}
+
+// background сбрасывает журнал на диск и делает снимки по таймерам из конфигурации.
+func (s *InMemoryStorage) background() {
+	defer close(s.durable.done)
+
+	cfg := s.durable.cfg
+
+	var fsyncC, snapshotC <-chan time.Time
+	if cfg.Fsync == FsyncInterval {
+		ticker := time.NewTicker(cfg.FsyncInterval)
+		defer ticker.Stop()
+		fsyncC = ticker.C
+	}
+	if cfg.SnapshotInterval > 0 {
+		ticker := time.NewTicker(cfg.SnapshotInterval)
+		defer ticker.Stop()
+		snapshotC = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.durable.stop:
+			return
+		case <-fsyncC:
+			if err := s.wal.sync(); err != nil {
+				s.durable.log.Error("Failed to sync in-memory log", slog.Any("error", err))
+			}
+		case <-snapshotC:
+			if err := s.Snapshot(); err != nil {
+				s.durable.log.Error("Failed to take in-memory snapshot", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Stop останавливает фоновые задачи, делает последний снимок и закрывает журнал.
+// Для хранилища без DataDir ничего не делает.
+func (s *InMemoryStorage) Stop(ctx context.Context) error {
+	const op = "storage.in-memory.Stop"
+
+	if s.durable == nil {
+		return nil
+	}
+
+	s.durable.stopOnce.Do(func() { close(s.durable.stop) })
+	select {
+	case <-s.durable.done:
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+
+	// Снимок при остановке ускоряет следующий запуск; если он не удался, данные остаются в журнале
+	snapshotErr := s.Snapshot()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return snapshotErr
+	}
+	if err := s.wal.close(); err != nil {
+		return errors.Join(snapshotErr, fmt.Errorf("%s: failed to close log: %w", op, err))
+	}
+	s.wal = nil
+
+	return snapshotErr
+}
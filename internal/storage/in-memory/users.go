@@ -0,0 +1,144 @@
+package in_memory
+
+import (
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	"context"
+	"fmt"
+)
+
+// CreateUser регистрирует пользователя; имя должно быть уникальным.
+func (s *InMemoryStorage) CreateUser(ctx context.Context, username, passwordHash string, role models.Role) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// CHECK на роль в PostgreSQL проверяется раньше уникальности имени
+	if !role.Valid() {
+		return 0, fmt.Errorf("role %q: %w", role, storage.ErrValidation)
+	}
+	if _, ok := s.usernames[username]; ok {
+		return 0, fmt.Errorf("user %q: %w", username, storage.ErrAlreadyExists)
+	}
+
+	user := models.User{
+		ID:           s.lastUserID + 1,
+		Username:     username,
+		PasswordHash: passwordHash,
+		Role:         role,
+		CreatedAt:    now(),
+	}
+	if err := s.commit(walRecord{Op: opCreateUser, User: newUserRecord(user)}); err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// applyCreateUser добавляет пользователя. Вызывается под блокировкой.
+func (s *InMemoryStorage) applyCreateUser(user models.User) {
+	s.users[user.ID] = user
+	s.usernames[user.Username] = user.ID
+	s.lastUserID = max(s.lastUserID, user.ID)
+}
+
+// GetUser возвращает пользователя по ID.
+func (s *InMemoryStorage) GetUser(ctx context.Context, id int) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return models.User{}, fmt.Errorf("user %d: %w", id, storage.ErrNotFound)
+	}
+	return user, nil
+}
+
+// GetUserByUsername возвращает пользователя по имени.
+func (s *InMemoryStorage) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.usernames[username]
+	if !ok {
+		return models.User{}, fmt.Errorf("user %q: %w", username, storage.ErrNotFound)
+	}
+	return s.users[id], nil
+}
+
+// SetUserRole назначает роль пользователю.
+func (s *InMemoryStorage) SetUserRole(ctx context.Context, id int, role models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[id]; !ok {
+		return fmt.Errorf("user %d: %w", id, storage.ErrNotFound)
+	}
+	if !role.Valid() {
+		return fmt.Errorf("role %q: %w", role, storage.ErrValidation)
+	}
+	return s.commit(walRecord{Op: opSetUserRole, ID: id, Role: role})
+}
+
+// applySetUserRole назначает роль пользователю. Вызывается под блокировкой.
+func (s *InMemoryStorage) applySetUserRole(id int, role models.Role) {
+	user := s.users[id]
+	user.Role = role
+	s.users[id] = user
+}
+
+// GetUsersByID возвращает пользователей в порядке ids; ненайденным соответствует nil.
+func (s *InMemoryStorage) GetUsersByID(ctx context.Context, ids []int) ([]*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*models.User, len(ids))
+	for i, id := range ids {
+		if user, ok := s.users[id]; ok {
+			users[i] = &user
+		}
+	}
+	return users, nil
+}
+
+// GetUserPosts возвращает страницу постов пользователя, новые сверху.
+func (s *InMemoryStorage) GetUserPosts(ctx context.Context, userID int, page models.PageParams) (models.PostPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.pagePosts(s.userPosts[userID], page), nil
+}
+
+// GetUserComments возвращает страницу комментариев пользователя, новые сверху.
+func (s *InMemoryStorage) GetUserComments(ctx context.Context, userID int, page models.PageParams) (models.CommentPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.pageComments(s.userComments[userID], models.SortNewest, page)
+}
+
+// GetUserPostPages возвращает страницы постов для нескольких пользователей.
+func (s *InMemoryStorage) GetUserPostPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.PostPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pages := make([]models.PostPage, len(userIDs))
+	for i, userID := range userIDs {
+		pages[i] = s.pagePosts(s.userPosts[userID], page)
+	}
+	return pages, nil
+}
+
+// GetUserCommentPages возвращает страницы комментариев для нескольких пользователей.
+func (s *InMemoryStorage) GetUserCommentPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.CommentPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pages := make([]models.CommentPage, len(userIDs))
+	for i, userID := range userIDs {
+		p, err := s.pageComments(s.userComments[userID], models.SortNewest, page)
+		if err != nil {
+			return nil, err
+		}
+		pages[i] = p
+	}
+	return pages, nil
+}
@@ -0,0 +1,377 @@
+package in_memory
+
+import (
+	"Habr-comments-server/internal/models"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Режимы fsync журнала.
+const (
+	FsyncAlways   = "always"   // После каждой записи: изменение не теряется даже при сбое ОС
+	FsyncInterval = "interval" // Раз в FsyncInterval: при сбое ОС теряются последние изменения
+	FsyncNever    = "never"    // Сброс на диск остается на усмотрение ОС
+)
+
+// Операции журнала: по одной на каждый изменяющий метод хранилища.
+const (
+	opCreateUser      = "createUser"
+	opSetUserRole     = "setUserRole"
+	opCreatePost      = "createPost"
+	opUpdatePost      = "updatePost"
+	opDeletePost      = "deletePost"
+	opBlockComments   = "blockComments"
+	opUnblockComments = "unblockComments"
+	opCreateComment   = "createComment"
+	opUpdateComment   = "updateComment"
+	opDeleteComment   = "deleteComment"
+	opVoteComment     = "voteComment"
+)
+
+// walRecord — одна строка журнала. Запись содержит уже вычисленные ID и время,
+// поэтому повторное применение при восстановлении дает то же состояние.
+type walRecord struct {
+	Op string `json:"op"`
+
+	User    *userRecord     `json:"user,omitempty"`
+	Post    *models.Post    `json:"post,omitempty"`
+	Comment *models.Comment `json:"comment,omitempty"`
+
+	ID      int         `json:"id,omitempty"`
+	UserID  int         `json:"userId,omitempty"`
+	Title   *string     `json:"title,omitempty"`
+	Content *string     `json:"content,omitempty"`
+	Reason  string      `json:"reason,omitempty"`
+	Role    models.Role `json:"role,omitempty"`
+	Value   int         `json:"value,omitempty"`
+	At      *time.Time  `json:"at,omitempty"`
+}
+
+// userRecord — пользователь вместе с хешем пароля, который models.User не сериализует.
+type userRecord struct {
+	ID           int         `json:"id"`
+	Username     string      `json:"username"`
+	PasswordHash string      `json:"passwordHash"`
+	Role         models.Role `json:"role"`
+	CreatedAt    time.Time   `json:"createdAt"`
+}
+
+func newUserRecord(user models.User) *userRecord {
+	return &userRecord{
+		ID:           user.ID,
+		Username:     user.Username,
+		PasswordHash: user.PasswordHash,
+		Role:         user.Role,
+		CreatedAt:    user.CreatedAt,
+	}
+}
+
+func (u userRecord) user() models.User {
+	return models.User{
+		ID:           u.ID,
+		Username:     u.Username,
+		PasswordHash: u.PasswordHash,
+		Role:         u.Role,
+		CreatedAt:    u.CreatedAt,
+	}
+}
+
+// commit записывает изменение в журнал (если он включен) и применяет его к данным.
+// Проверки выполняются до commit, поэтому применение записи не может завершиться ошибкой
+// из-за данных. Вызывается под блокировкой на запись.
+func (s *InMemoryStorage) commit(rec walRecord) error {
+	if s.wal != nil {
+		if err := s.wal.append(rec); err != nil {
+			return fmt.Errorf("failed to write ahead log: %w", err)
+		}
+	}
+	return s.apply(rec)
+}
+
+// apply применяет запись журнала к данным. Вызывается под блокировкой на запись.
+func (s *InMemoryStorage) apply(rec walRecord) error {
+	// При восстановлении запись могла прийти из поврежденного файла
+	missing := func(what string, id int) error {
+		return fmt.Errorf("%s: %s %d does not exist", rec.Op, what, id)
+	}
+
+	switch rec.Op {
+	case opCreateUser:
+		if rec.User == nil {
+			return fmt.Errorf("%s: no user in record", rec.Op)
+		}
+		s.applyCreateUser(rec.User.user())
+	case opSetUserRole:
+		if _, ok := s.users[rec.ID]; !ok {
+			return missing("user", rec.ID)
+		}
+		s.applySetUserRole(rec.ID, rec.Role)
+	case opCreatePost:
+		if rec.Post == nil {
+			return fmt.Errorf("%s: no post in record", rec.Op)
+		}
+		s.applyCreatePost(*rec.Post)
+	case opUpdatePost, opDeletePost, opBlockComments, opUnblockComments:
+		if _, ok := s.posts[rec.ID]; !ok {
+			return missing("post", rec.ID)
+		}
+		switch rec.Op {
+		case opUpdatePost:
+			s.applyUpdatePost(rec.ID, rec.Title, rec.Content)
+		case opDeletePost:
+			s.applyDeletePost(rec.ID)
+		case opBlockComments:
+			if rec.At == nil {
+				return fmt.Errorf("%s: no time in record", rec.Op)
+			}
+			s.applyBlockComments(rec.ID, rec.Reason, *rec.At)
+		case opUnblockComments:
+			s.applyUnblockComments(rec.ID)
+		}
+	case opCreateComment:
+		if rec.Comment == nil {
+			return fmt.Errorf("%s: no comment in record", rec.Op)
+		}
+		if _, ok := s.posts[rec.Comment.PostId]; !ok {
+			return missing("post", rec.Comment.PostId)
+		}
+		if parentID := rec.Comment.ParentId; parentID != nil {
+			if _, ok := s.comments[*parentID]; !ok {
+				return missing("parent comment", *parentID)
+			}
+		}
+		s.applyCreateComment(*rec.Comment)
+	case opUpdateComment, opDeleteComment, opVoteComment:
+		if _, ok := s.comments[rec.ID]; !ok {
+			return missing("comment", rec.ID)
+		}
+		switch rec.Op {
+		case opUpdateComment:
+			if rec.Content == nil || rec.At == nil {
+				return fmt.Errorf("%s: no content or time in record", rec.Op)
+			}
+			s.applyUpdateComment(rec.ID, *rec.Content, *rec.At)
+		case opDeleteComment:
+			s.applyDeleteComment(rec.ID)
+		case opVoteComment:
+			s.applyVoteComment(rec.ID, rec.UserID, rec.Value)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", rec.Op)
+	}
+
+	return nil
+}
+
+// wal — журнал изменений, разбитый на сегменты wal-<N>.log. Снимок покрывает все
+// сегменты до своего номера, после снимка старые сегменты удаляются.
+type wal struct {
+	dir   string
+	fsync string
+
+	mu      sync.Mutex // Защищает файл от одновременных append и фонового fsync
+	file    *os.File
+	segment int   // Номер текущего сегмента
+	size    int64 // Длина текущего сегмента, до которой файл откатывается при ошибке записи
+	dirty   bool  // Есть записи, еще не сброшенные на диск
+	records int   // Записей с последнего снимка
+}
+
+func segmentName(segment int) string {
+	return fmt.Sprintf("wal-%016d.log", segment)
+}
+
+// listSegments возвращает номера сегментов журнала в dir по возрастанию.
+func listSegments(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "wal-") || !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		segment, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "wal-"), ".log"))
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	sort.Ints(segments)
+
+	return segments, nil
+}
+
+// openWAL открывает сегмент на дозапись; size — длина его корректной части.
+func openWAL(dir, fsync string, segment int, size int64) (*wal, error) {
+	file, err := os.OpenFile(filepath.Join(dir, segmentName(segment)), os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Отрезаем недописанную при сбое запись
+	if err = file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err = file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err = syncDir(dir); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &wal{dir: dir, fsync: fsync, file: file, segment: segment, size: size}, nil
+}
+
+// append дописывает запись в журнал. Если запись не удалась, файл откатывается
+// к прежней длине, чтобы в журнале не осталось оборванной строки.
+func (w *wal) append(rec walRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err = w.file.Write(line); err == nil && w.fsync == FsyncAlways {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		if rollbackErr := w.rollback(); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+
+	w.size += int64(len(line))
+	w.dirty = w.fsync != FsyncAlways
+	w.records++
+	return nil
+}
+
+// rollback возвращает текущий сегмент к длине w.size. Вызывается под w.mu.
+func (w *wal) rollback() error {
+	if err := w.file.Truncate(w.size); err != nil {
+		return err
+	}
+	_, err := w.file.Seek(w.size, io.SeekStart)
+	return err
+}
+
+// sync сбрасывает на диск записи, накопленные с прошлого вызова.
+func (w *wal) sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.dirty {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+// rotate закрывает текущий сегмент и начинает следующий; возвращает номер нового сегмента.
+// Вызывается под блокировкой хранилища на запись, чтобы новые записи не попали в снимок дважды.
+func (w *wal) rotate() (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next := w.segment + 1
+	file, err := os.OpenFile(filepath.Join(w.dir, segmentName(next)), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	if err = syncDir(w.dir); err != nil {
+		file.Close()
+		return 0, err
+	}
+
+	// Предыдущий сегмент должен быть на диске раньше, чем появится снимок, который на него опирается
+	err = w.file.Sync()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+
+	w.file, w.segment, w.size, w.dirty, w.records = file, next, 0, false, 0
+	return next, nil
+}
+
+// close сбрасывает журнал на диск и закрывает файл.
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.file.Sync()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// replaySegment применяет записи сегмента через apply и возвращает длину корректной части файла.
+// Последняя строка без перевода строки — запись, оборванная сбоем: она отбрасывается.
+func replaySegment(path string, apply func(walRecord) error) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var size int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return size, nil // Хвост без '\n' (если есть) недописан
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var rec walRecord
+		if err = json.Unmarshal(bytes.TrimSpace(data), &rec); err != nil {
+			return 0, fmt.Errorf("%s:%d: %w", filepath.Base(path), line, err)
+		}
+		if err = apply(rec); err != nil {
+			return 0, fmt.Errorf("%s:%d: %w", filepath.Base(path), line, err)
+		}
+		size += int64(len(data))
+	}
+}
+
+// syncDir сбрасывает на диск содержимое каталога, чтобы созданные и переименованные файлы пережили сбой.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
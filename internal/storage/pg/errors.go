@@ -0,0 +1,56 @@
+package pg
+
+import (
+	"Habr-comments-server/internal/storage"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Коды SQLSTATE, которые переводятся в ошибки storage
+const (
+	stringDataRightTruncation = "22001"
+	notNullViolation          = "23502"
+	foreignKeyViolation       = "23503"
+	uniqueViolation           = "23505"
+	checkViolation            = "23514"
+	programLimitExceeded      = "54000" // Например, строка индекса длиннее допустимой
+)
+
+// MapDeleteError переводит ошибки удаления. Нарушение внешнего ключа здесь означает, что
+// на удаляемую запись еще ссылаются (parent_id комментариев — NO ACTION), а не что запись не найдена.
+func MapDeleteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+		return fmt.Errorf("%w: %w", storage.ErrValidation, err)
+	}
+
+	return MapError(err)
+}
+
+// MapError переводит ошибки pgx и PostgreSQL в ошибки storage; исходная ошибка, в том числе
+// *pgconn.PgError с кодом и ограничением, остается в цепочке. Экспортирована для тестов перевода кодов.
+// Нарушение внешнего ключа означает, что запись, на которую ссылаются (пост, комментарий, пользователь), не найдена.
+func MapError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case uniqueViolation:
+		return fmt.Errorf("%w: %w", storage.ErrAlreadyExists, err)
+	case foreignKeyViolation:
+		return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+	case checkViolation, notNullViolation, stringDataRightTruncation, programLimitExceeded:
+		return fmt.Errorf("%w: %w", storage.ErrValidation, err)
+	}
+
+	return err
+}
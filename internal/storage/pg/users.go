@@ -0,0 +1,147 @@
+package pg
+
+import (
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	"context"
+	"fmt"
+)
+
+// Регистрация пользователя
+func (s *Storage) CreateUser(ctx context.Context, username, passwordHash string, role models.Role) (int, error) {
+	const op = "storage.db.CreateUser"
+
+	query := `INSERT INTO users (username, password_hash, role) VALUES ($1, $2, $3) RETURNING id;`
+
+	var userID int
+	err := s.db.QueryRow(ctx, query, username, passwordHash, role).Scan(&userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to insert user: %w", op, MapError(err))
+	}
+
+	return userID, nil
+}
+
+// Получение пользователя по ID
+func (s *Storage) GetUser(ctx context.Context, id int) (models.User, error) {
+	const op = "storage.db.GetUser"
+
+	query := `SELECT id, username, COALESCE(password_hash, ''), role, created_at FROM users WHERE id = $1;`
+
+	var user models.User
+	err := s.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: failed to query user: %w", op, MapError(err))
+	}
+
+	return user, nil
+}
+
+// Получение пользователя по имени (для входа)
+func (s *Storage) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	const op = "storage.db.GetUserByUsername"
+
+	query := `SELECT id, username, COALESCE(password_hash, ''), role, created_at FROM users WHERE username = $1;`
+
+	var user models.User
+	err := s.db.QueryRow(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: failed to query user: %w", op, MapError(err))
+	}
+
+	return user, nil
+}
+
+// Назначение роли пользователю
+func (s *Storage) SetUserRole(ctx context.Context, id int, role models.Role) error {
+	const op = "storage.db.SetUserRole"
+
+	query := `UPDATE users SET role = $2 WHERE id = $1;`
+
+	tag, err := s.db.Exec(ctx, query, id, role)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update role: %w", op, MapError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetUsersByID(ctx context.Context, ids []int) ([]*models.User, error) {
+	query := `
+		SELECT id, username, role, created_at FROM users WHERE id = ANY($1);
+	`
+
+	rows, err := s.db.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("storage.db.GetUsersByID: failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make(map[int]*models.User)
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("storage.db.GetUsersByID: failed to scan user: %w", err)
+		}
+		users[user.ID] = &user
+	}
+
+	// Собираем пользователей в правильном порядке (как в `ids`)
+	result := make([]*models.User, len(ids))
+	for i, id := range ids {
+		result[i] = users[id] // Если нет в БД, останется `nil`
+	}
+
+	return result, nil
+}
+
+// Посты пользователя с keyset-пагинацией (новые сверху)
+func (s *Storage) GetUserPosts(ctx context.Context, userID int, page models.PageParams) (models.PostPage, error) {
+	const op = "storage.db.GetUserPosts"
+
+	result, err := s.queryPostPage(ctx, "author_id = k.key", userID, page)
+	if err != nil {
+		return models.PostPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// Комментарии пользователя с keyset-пагинацией (новые сверху)
+func (s *Storage) GetUserComments(ctx context.Context, userID int, page models.PageParams) (models.CommentPage, error) {
+	const op = "storage.db.GetUserComments"
+
+	result, err := s.queryCommentPage(ctx, "author_id = k.key", userID, models.SortNewest, page)
+	if err != nil {
+		return models.CommentPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// Страницы постов для нескольких пользователей (для DataLoader)
+func (s *Storage) GetUserPostPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.PostPage, error) {
+	const op = "storage.db.GetUserPostPages"
+
+	pages, err := s.queryPostPages(ctx, "author_id = k.key", userIDs, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pages, nil
+}
+
+// Страницы комментариев для нескольких пользователей (для DataLoader)
+func (s *Storage) GetUserCommentPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.CommentPage, error) {
+	const op = "storage.db.GetUserCommentPages"
+
+	pages, err := s.queryCommentPages(ctx, "author_id = k.key", userIDs, models.SortNewest, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pages, nil
+}
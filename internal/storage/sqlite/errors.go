@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"Habr-comments-server/internal/storage"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// mapDeleteError переводит ошибки удаления так же, как pg.mapDeleteError: нарушение внешнего
+// ключа означает, что на удаляемую запись еще ссылаются.
+func mapDeleteError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey {
+		return fmt.Errorf("%w: %s", storage.ErrValidation, sqliteErr.Error())
+	}
+
+	return mapError(err)
+}
+
+// mapError переводит ошибки database/sql и SQLite в ошибки storage так же, как pg.mapError;
+// исходная ошибка остается в цепочке.
+func mapError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return fmt.Errorf("%w: %s", storage.ErrAlreadyExists, sqliteErr.Error())
+	case sqlite3.ErrConstraintForeignKey:
+		return fmt.Errorf("%w: %s", storage.ErrNotFound, sqliteErr.Error())
+	case sqlite3.ErrConstraintCheck, sqlite3.ErrConstraintNotNull:
+		return fmt.Errorf("%w: %s", storage.ErrValidation, sqliteErr.Error())
+	}
+
+	return err
+}
@@ -0,0 +1,52 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// Миграции SQLite хранятся отдельно от миграций PostgreSQL и встроены в бинарник,
+// чтобы для небольших установок хватало одного файла базы без отдельного мигратора.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrateUp применяет недостающие миграции через отдельное соединение:
+// закрытие migrate закрывает и переданную ему базу.
+func migrateUp(dsn string) error {
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return err
+	}
+
+	source, err := iofs.New(migrations, "migrations")
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to init migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "sqlite3", driver)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to init migrations: %w", err)
+	}
+	defer m.Close()
+
+	if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	return nil
+}
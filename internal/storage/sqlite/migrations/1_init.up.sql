@@ -0,0 +1,62 @@
+-- Схема повторяет итоговое состояние миграций PostgreSQL.
+-- AUTOINCREMENT, как SERIAL, не выдает повторно ID удаленных строк.
+-- Время хранится текстом 'YYYY-MM-DD HH:MM:SS.ffffff' (UTC): при фиксированной
+-- длине строковое сравнение совпадает с хронологическим, что нужно keyset-пагинации.
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT UNIQUE NOT NULL,
+    password_hash TEXT,
+    role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE posts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    author_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    allow_comments BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL,
+    comments_locked_reason TEXT,
+    comments_locked_at TIMESTAMP,
+    comments_count INTEGER NOT NULL DEFAULT 0,
+    last_comment_at TIMESTAMP
+);
+
+-- Комментарии удаляются мягко; ответы физически удаляются только вместе с постом
+CREATE TABLE comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id INTEGER REFERENCES comments(id),
+    content TEXT NOT NULL CHECK (length(content) <= 2000),
+    created_at TIMESTAMP NOT NULL,
+    edited_at TIMESTAMP,
+    is_deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    upvotes INTEGER NOT NULL DEFAULT 0,
+    downvotes INTEGER NOT NULL DEFAULT 0,
+    replies_count INTEGER NOT NULL DEFAULT 0,
+    descendants_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE comment_votes (
+    comment_id INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    value INTEGER NOT NULL CHECK (value IN (-1, 1)),
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX idx_posts_created ON posts(created_at DESC, id DESC);
+
+CREATE INDEX idx_posts_author_created ON posts(author_id, created_at DESC, id DESC);
+
+CREATE INDEX idx_comments_post ON comments(post_id);
+
+CREATE INDEX idx_comments_post_root_created ON comments(post_id, created_at, id) WHERE parent_id IS NULL;
+
+CREATE INDEX idx_comments_parent_created ON comments(parent_id, created_at, id);
+
+CREATE INDEX idx_comments_author_created ON comments(author_id, created_at DESC, id DESC);
+
+CREATE INDEX idx_comment_votes_user ON comment_votes(user_id);
@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS comments_counters_delete;
+DROP TRIGGER IF EXISTS comments_counters_insert;
@@ -0,0 +1,48 @@
+-- Счетчики комментариев поддерживаются триггерами, как в PostgreSQL (9_comment_counters).
+-- Время фиксированной длины, поэтому max() по строкам дает самый поздний комментарий.
+CREATE TRIGGER comments_counters_insert
+    AFTER INSERT ON comments
+BEGIN
+    UPDATE posts
+    SET comments_count = comments_count + 1,
+        last_comment_at = max(COALESCE(last_comment_at, NEW.created_at), NEW.created_at)
+    WHERE id = NEW.post_id;
+
+    UPDATE comments SET replies_count = replies_count + 1 WHERE id = NEW.parent_id;
+
+    UPDATE comments SET descendants_count = descendants_count + 1
+    WHERE id IN (
+        WITH RECURSIVE ancestors(id, parent_id) AS (
+            SELECT id, parent_id FROM comments WHERE id = NEW.parent_id
+            UNION ALL
+            SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+        )
+        SELECT id FROM ancestors
+    );
+END;
+
+-- Внешний ключ parent_id без ON DELETE (NO ACTION), как в PostgreSQL: комментарий удаляется
+-- только вместе со всеми ответами в том же запросе. В SQLite триггер срабатывает сразу после
+-- удаления строки, и порядок строк не важен: если ответ удален раньше родителя, он уже вычел
+-- свое поддерево из родителя и предков, и родитель вычитает только остаток descendants_count.
+-- В итоге уцелевшие предки теряют все поддерево ровно один раз.
+CREATE TRIGGER comments_counters_delete
+    AFTER DELETE ON comments
+BEGIN
+    UPDATE posts
+    SET comments_count = comments_count - 1,
+        last_comment_at = (SELECT max(created_at) FROM comments WHERE post_id = OLD.post_id)
+    WHERE id = OLD.post_id;
+
+    UPDATE comments SET replies_count = replies_count - 1 WHERE id = OLD.parent_id;
+
+    UPDATE comments SET descendants_count = descendants_count - 1 - OLD.descendants_count
+    WHERE id IN (
+        WITH RECURSIVE ancestors(id, parent_id) AS (
+            SELECT id, parent_id FROM comments WHERE id = OLD.parent_id
+            UNION ALL
+            SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+        )
+        SELECT id FROM ancestors
+    );
+END;
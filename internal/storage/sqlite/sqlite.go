@@ -0,0 +1,1155 @@
+package sqlite
+
+import (
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/service"
+	"Habr-comments-server/internal/storage"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var _ service.PostRepository = (*Storage)(nil)
+var _ service.CommentRepository = (*Storage)(nil)
+var _ service.UserRepository = (*Storage)(nil)
+
+// timeFormat — формат хранения времени. Время всегда в UTC и с микросекундами, как TIMESTAMP
+// в PostgreSQL: строки одной длины сравниваются в хронологическом порядке.
+const timeFormat = "2006-01-02 15:04:05.000000"
+
+// Storage — хранилище в одном файле SQLite с той же сортировкой, пагинацией
+// и ошибками, что и pg.Storage.
+type Storage struct {
+	db *sql.DB
+}
+
+func New(cfg config.SQLite) (*Storage, error) {
+	const op = "storage.sqlite.New"
+
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("%s: failed to create data dir: %w", op, err)
+	}
+
+	// Транзакции сразу берут блокировку на запись (_txlock=immediate): проверка и вставка
+	// в CreateComment и VoteComment не пересекаются с параллельными изменениями
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=%d&_txlock=immediate",
+		cfg.Path, cfg.BusyTimeout.Milliseconds())
+
+	if err := migrateUp(dsn); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to open database: %w", op, err)
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: failed to ping database: %w", op, err)
+	}
+
+	return &Storage{db: db}, nil
+}
+
+// Stop закрывает базу, дождавшись выполняющихся запросов. Если ctx истекает раньше,
+// Stop возвращает ошибку, а база дозакрывается в фоне.
+func (s *Storage) Stop(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.db.Close()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("storage.sqlite.Stop: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("storage.sqlite.Stop: %w", ctx.Err())
+	}
+}
+
+// now возвращает текущее время в формате хранения.
+func now() string {
+	return formatTime(time.Now())
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// keysArg передает список ключей одним параметром: запросы разворачивают его через json_each.
+func keysArg(keys []int) string {
+	data, _ := json.Marshal(keys)
+	return string(data)
+}
+
+// Получение постов с keyset-пагинацией (новые сверху)
+func (s *Storage) GetPosts(ctx context.Context, page models.PageParams) (models.PostPage, error) {
+	const op = "storage.sqlite.GetPosts"
+
+	result, err := s.queryPostPage(ctx, "0", "TRUE", 0, page)
+	if err != nil {
+		return models.PostPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// queryPostPage выполняет keyset-запрос страницы постов (новые сверху) и подсчет их общего числа.
+// key — выражение, значения которого перечислены в keys; filter — дополнительное условие выборки.
+func (s *Storage) queryPostPage(ctx context.Context, key, filter string, keyValue int, page models.PageParams) (models.PostPage, error) {
+	pages, err := s.queryPostPages(ctx, key, filter, []int{keyValue}, page)
+	if err != nil {
+		return models.PostPage{}, err
+	}
+
+	return pages[0], nil
+}
+
+// queryPostPages выполняет queryPostPage сразу для нескольких ключей двумя запросами;
+// страницы возвращаются в порядке keys. Вместо LATERAL, которого нет в SQLite,
+// страница каждого ключа отбирается оконной функцией ROW_NUMBER.
+func (s *Storage) queryPostPages(ctx context.Context, key, filter string, keys []int, page models.PageParams) ([]models.PostPage, error) {
+	query := fmt.Sprintf(`
+	SELECT key, id, author_id, title, content, allow_comments, created_at, comments_locked_reason, comments_locked_at
+	FROM (
+		SELECT %[1]s AS key, id, author_id, title, content, allow_comments, created_at, comments_locked_reason, comments_locked_at,
+		       ROW_NUMBER() OVER (PARTITION BY %[1]s ORDER BY created_at DESC, id DESC) AS rn
+		FROM posts
+		WHERE %[1]s IN (SELECT value FROM json_each(?1)) AND (%[2]s)
+		  AND (?2 IS NULL OR (created_at, id) < (?2, ?3))
+	)
+	WHERE rn <= ?4
+	ORDER BY key, rn;
+	`, key, filter)
+
+	afterTime, afterID := cursorArgs(page.After)
+
+	rows, err := s.db.QueryContext(ctx, query, keysArg(keys), afterTime, afterID, page.First+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query posts: %w", err)
+	}
+	defer rows.Close()
+
+	pages := make(map[int]*models.PostPage, len(keys))
+	for _, key := range keys {
+		pages[key] = &models.PostPage{}
+	}
+
+	for rows.Next() {
+		var key int
+		var post models.Post
+		if err := rows.Scan(
+			&key,
+			&post.ID,
+			&post.AuthorId,
+			&post.Title,
+			&post.Content,
+			&post.AllowComments,
+			&post.CreatedAt,
+			&post.CommentsLockedReason,
+			&post.CommentsLockedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan post: %w", err)
+		}
+		pages[key].Posts = append(pages[key].Posts, post)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	for _, p := range pages {
+		if len(p.Posts) > page.First {
+			p.Posts = p.Posts[:page.First]
+			p.HasNextPage = true
+		}
+	}
+
+	countQuery := fmt.Sprintf(`SELECT %[1]s, count(*) FROM posts WHERE %[1]s IN (SELECT value FROM json_each(?1)) AND (%[2]s) GROUP BY 1;`, key, filter)
+	if err = s.scanCounts(ctx, countQuery, keys, func(key, count int) { pages[key].TotalCount = count }); err != nil {
+		return nil, fmt.Errorf("failed to count posts: %w", err)
+	}
+
+	result := make([]models.PostPage, len(keys))
+	for i, key := range keys {
+		result[i] = *pages[key]
+	}
+
+	return result, nil
+}
+
+// scanCounts выполняет запрос вида (key, count) по списку ключей ?1 и передает каждую строку в set.
+// Ключи без строк в результат не попадают, и их счетчик остается нулевым.
+func (s *Storage) scanCounts(ctx context.Context, query string, keys []int, set func(key, count int)) error {
+	rows, err := s.db.QueryContext(ctx, query, keysArg(keys))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return err
+		}
+		set(key, count)
+	}
+
+	return rows.Err()
+}
+
+// Получение одного поста по ID
+func (s *Storage) GetPost(ctx context.Context, idPost int) (models.Post, error) {
+	const op = "storage.sqlite.GetPost"
+
+	query := `
+	SELECT id, author_id, title, content, allow_comments, created_at, comments_locked_reason, comments_locked_at
+	FROM posts WHERE id = ?1;
+	`
+
+	var post models.Post
+	err := s.db.QueryRowContext(ctx, query, idPost).Scan(
+		&post.ID,
+		&post.AuthorId,
+		&post.Title,
+		&post.Content,
+		&post.AllowComments,
+		&post.CreatedAt,
+		&post.CommentsLockedReason,
+		&post.CommentsLockedAt,
+	)
+	if err != nil {
+		return models.Post{}, fmt.Errorf("%s: failed to query post: %w", op, mapError(err))
+	}
+
+	return post, nil
+}
+
+// Создание нового поста
+func (s *Storage) CreatePost(ctx context.Context, authorId int, title, content string, allowComments bool) (int, error) {
+	const op = "storage.sqlite.CreatePost"
+
+	query := `
+		INSERT INTO posts (author_id, title, content, allow_comments, created_at, comments_locked_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, CASE WHEN ?4 THEN NULL ELSE ?5 END)
+		RETURNING id;
+	`
+
+	var postID int
+	err := s.db.QueryRowContext(ctx, query, authorId, title, content, allowComments, now()).Scan(&postID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to insert post: %w", op, mapError(err))
+	}
+
+	return postID, nil
+}
+
+// Редактирование поста: nil-поля остаются без изменений
+func (s *Storage) UpdatePost(ctx context.Context, id int, title, content *string) error {
+	const op = "storage.sqlite.UpdatePost"
+
+	query := `
+	UPDATE posts
+	SET title = COALESCE(?2, title), content = COALESCE(?3, content)
+	WHERE id = ?1;
+	`
+
+	res, err := s.db.ExecContext(ctx, query, id, title, content)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update post: %w", op, mapError(err))
+	}
+
+	return checkAffected(op, res)
+}
+
+// Удаление поста вместе с комментариями (ON DELETE CASCADE)
+func (s *Storage) DeletePost(ctx context.Context, id int) error {
+	const op = "storage.sqlite.DeletePost"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM posts WHERE id = ?1;`, id)
+	if err != nil {
+		return fmt.Errorf("%s: failed to delete post: %w", op, mapDeleteError(err))
+	}
+
+	return checkAffected(op, res)
+}
+
+// Блокировка комментариев к посту (reason может быть пустым)
+func (s *Storage) BlockComments(ctx context.Context, id int, reason string) error {
+	const op = "storage.sqlite.BlockComments"
+
+	query := `
+	UPDATE posts
+	SET allow_comments = FALSE, comments_locked_reason = NULLIF(?2, ''), comments_locked_at = ?3
+	WHERE id = ?1;
+	`
+
+	res, err := s.db.ExecContext(ctx, query, id, reason, now())
+	if err != nil {
+		return fmt.Errorf("%s: failed to block comments: %w", op, err)
+	}
+
+	return checkAffected(op, res)
+}
+
+// Снятие блокировки комментариев
+func (s *Storage) UnblockComments(ctx context.Context, id int) error {
+	const op = "storage.sqlite.UnblockComments"
+
+	query := `
+	UPDATE posts
+	SET allow_comments = TRUE, comments_locked_reason = NULL, comments_locked_at = NULL
+	WHERE id = ?1;
+	`
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("%s: failed to unblock comments: %w", op, err)
+	}
+
+	return checkAffected(op, res)
+}
+
+// checkAffected возвращает storage.ErrNotFound, если запрос не изменил ни одной строки.
+func checkAffected(op string, res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get affected rows: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// Получение корневых комментариев к посту с keyset-пагинацией
+func (s *Storage) GetComments(ctx context.Context, postID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	const op = "storage.sqlite.GetComments"
+
+	result, err := s.queryCommentPage(ctx, "post_id", "parent_id IS NULL", postID, sort, page)
+	if err != nil {
+		return models.CommentPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// Получение дочерних комментариев по parentID с keyset-пагинацией
+func (s *Storage) GetChildComments(ctx context.Context, parentID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	const op = "storage.sqlite.GetChildComments"
+
+	result, err := s.queryCommentPage(ctx, "parent_id", "TRUE", parentID, sort, page)
+	if err != nil {
+		return models.CommentPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// Выражения основного ключа сортировки комментариев; после него всегда идут created_at и id.
+// Для сортировок по времени дополнительного ключа нет.
+var commentSortKeys = map[models.CommentSort]string{
+	models.SortOldest:        "",
+	models.SortNewest:        "",
+	models.SortTop:           "(upvotes - downvotes)",
+	models.SortControversial: "MIN(upvotes, downvotes)",
+	models.SortMostReplies:   "replies_count",
+}
+
+// queryCommentPage выполняет keyset-запрос страницы комментариев и подсчет их общего числа.
+// key — выражение, значения которого перечислены в keys; filter — дополнительное условие выборки.
+func (s *Storage) queryCommentPage(ctx context.Context, key, filter string, keyValue int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	pages, err := s.queryCommentPages(ctx, key, filter, []int{keyValue}, sort, page)
+	if err != nil {
+		return models.CommentPage{}, err
+	}
+
+	return pages[0], nil
+}
+
+// queryCommentPages выполняет queryCommentPage сразу для нескольких ключей двумя запросами;
+// страницы возвращаются в порядке keys.
+func (s *Storage) queryCommentPages(ctx context.Context, key, filter string, keys []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	sortKey, ok := commentSortKeys[sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q: %w", sort, storage.ErrValidation)
+	}
+
+	cmp, dir := ">", "ASC"
+	if sort.Descending() {
+		cmp, dir = "<", "DESC"
+	}
+
+	afterTime, afterID := cursorArgs(page.After)
+	args := []interface{}{keysArg(keys), afterTime, afterID, page.First + 1}
+
+	// Ключ keyset-сравнения и сортировки: [значение,] created_at, id
+	value, keyCols, keyArgs := "0", "created_at, id", "?2, ?3"
+	order := fmt.Sprintf("created_at %[1]s, id %[1]s", dir)
+	if sortKey != "" {
+		var afterValue int
+		if page.After != nil {
+			afterValue = page.After.Value
+		}
+		args = append(args, afterValue)
+
+		value = sortKey
+		keyCols = sortKey + ", " + keyCols
+		keyArgs = "?5, " + keyArgs
+		order = sortKey + " " + dir + ", " + order
+	}
+
+	query := fmt.Sprintf(`
+	SELECT key, id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted,
+	       upvotes, downvotes, sort_value
+	FROM (
+		SELECT %[1]s AS key, id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes,
+		       %[3]s AS sort_value,
+		       ROW_NUMBER() OVER (PARTITION BY %[1]s ORDER BY %[7]s) AS rn
+		FROM comments
+		WHERE %[1]s IN (SELECT value FROM json_each(?1)) AND (%[2]s)
+		  AND (?2 IS NULL OR (%[4]s) %[5]s (%[6]s))
+	)
+	WHERE rn <= ?4
+	ORDER BY key, rn;
+	`, key, filter, value, keyCols, cmp, keyArgs, order)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comments: %w", err)
+	}
+	defer rows.Close()
+
+	pages := make(map[int]*models.CommentPage, len(keys))
+	for _, key := range keys {
+		pages[key] = &models.CommentPage{}
+	}
+
+	for rows.Next() {
+		var key, value int
+		var c models.Comment
+		err := rows.Scan(&key, &c.ID, &c.PostId, &c.AuthorId, &c.ParentId, &c.Content, &c.CreatedAt, &c.EditedAt, &c.IsDeleted, &c.Upvotes, &c.Downvotes, &value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		p := pages[key]
+		p.Comments = append(p.Comments, c)
+		p.Cursors = append(p.Cursors, models.Cursor{Value: value, CreatedAt: c.CreatedAt, ID: c.ID})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	for _, p := range pages {
+		if len(p.Comments) > page.First {
+			p.Comments = p.Comments[:page.First]
+			p.Cursors = p.Cursors[:page.First]
+			p.HasNextPage = true
+		}
+	}
+
+	countQuery := fmt.Sprintf(`SELECT %[1]s, count(*) FROM comments WHERE %[1]s IN (SELECT value FROM json_each(?1)) AND (%[2]s) GROUP BY 1;`, key, filter)
+	if err = s.scanCounts(ctx, countQuery, keys, func(key, count int) { pages[key].TotalCount = count }); err != nil {
+		return nil, fmt.Errorf("failed to count comments: %w", err)
+	}
+
+	result := make([]models.CommentPage, len(keys))
+	for i, key := range keys {
+		result[i] = *pages[key]
+	}
+
+	return result, nil
+}
+
+// collectComments читает все строки вида (id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes)
+func collectComments(rows *sql.Rows) ([]models.Comment, error) {
+	defer rows.Close()
+
+	var comments []models.Comment
+	for rows.Next() {
+		var comment models.Comment
+		err := rows.Scan(&comment.ID, &comment.PostId, &comment.AuthorId, &comment.ParentId, &comment.Content, &comment.CreatedAt, &comment.EditedAt, &comment.IsDeleted, &comment.Upvotes, &comment.Downvotes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return comments, nil
+}
+
+// cursorArgs раскладывает курсор на параметры запроса (NULL, если курсора нет)
+func cursorArgs(cursor *models.Cursor) (interface{}, int) {
+	if cursor == nil {
+		return nil, 0
+	}
+
+	return formatTime(cursor.CreatedAt), cursor.ID
+}
+
+// Получение дерева комментариев поста одним рекурсивным запросом.
+// Ключ сортировки — строка из (created_at, id) всех предков фиксированной длины,
+// что дает тот же порядок обхода pre-order, что и массив в PostgreSQL.
+func (s *Storage) GetCommentTree(ctx context.Context, postID, maxDepth, rootLimit int) ([]models.CommentTreeNode, error) {
+	const op = "storage.sqlite.GetCommentTree"
+
+	query := `
+	WITH RECURSIVE tree AS (
+		SELECT id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes,
+		       0 AS depth,
+		       '' AS path,
+		       created_at || printf('%010d', id) AS sort_key
+		FROM (
+			SELECT * FROM comments
+			WHERE post_id = ?1 AND parent_id IS NULL
+			ORDER BY created_at, id
+			LIMIT ?2
+		) roots
+		UNION ALL
+		SELECT c.id, c.post_id, c.author_id, c.parent_id, c.content, c.created_at, c.edited_at, c.is_deleted, c.upvotes, c.downvotes,
+		       t.depth + 1,
+		       t.path || t.id || ',',
+		       t.sort_key || c.created_at || printf('%010d', c.id)
+		FROM comments c
+		JOIN tree t ON c.parent_id = t.id
+		WHERE ?3 IS NULL OR t.depth < ?3
+	)
+	SELECT id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes, depth, path
+	FROM tree
+	ORDER BY sort_key;
+	`
+
+	var depthArg interface{} // NULL — без ограничения
+	if maxDepth >= 0 {
+		depthArg = maxDepth
+	}
+	if rootLimit < 0 {
+		rootLimit = -1 // LIMIT -1 в SQLite — без ограничения
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, postID, rootLimit, depthArg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query comment tree: %w", op, err)
+	}
+
+	tree, err := collectTree(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tree, nil
+}
+
+// Получение поддерева комментария (вместе с ним самим) в порядке обхода pre-order.
+// maxDepth ограничивает число уровней ответов под комментарием, отрицательный — без ограничения.
+// Глубина и путь узлов считаются от корня дерева поста, как в GetCommentTree:
+// сначала поднимаемся по предкам, затем спускаемся от комментария тем же рекурсивным запросом.
+func (s *Storage) GetCommentSubtree(ctx context.Context, id, maxDepth int) ([]models.CommentTreeNode, error) {
+	const op = "storage.sqlite.GetCommentSubtree"
+
+	query := `
+	WITH RECURSIVE up AS (
+		SELECT id, parent_id, 0 AS depth, '' AS path
+		FROM comments
+		WHERE id = ?1
+		UNION ALL
+		SELECT c.id, c.parent_id, u.depth + 1, c.id || ',' || u.path
+		FROM comments c
+		JOIN up u ON c.id = u.parent_id
+	),
+	tree AS (
+		SELECT c.id, c.post_id, c.author_id, c.parent_id, c.content, c.created_at, c.edited_at, c.is_deleted, c.upvotes, c.downvotes,
+		       top.depth,
+		       top.path,
+		       0 AS level,
+		       '' AS sort_key
+		FROM comments c, (SELECT depth, path FROM up ORDER BY depth DESC LIMIT 1) top
+		WHERE c.id = ?1
+		UNION ALL
+		SELECT c.id, c.post_id, c.author_id, c.parent_id, c.content, c.created_at, c.edited_at, c.is_deleted, c.upvotes, c.downvotes,
+		       t.depth + 1,
+		       t.path || t.id || ',',
+		       t.level + 1,
+		       t.sort_key || c.created_at || printf('%010d', c.id)
+		FROM comments c
+		JOIN tree t ON c.parent_id = t.id
+		WHERE ?2 IS NULL OR t.level < ?2
+	)
+	SELECT id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes, depth, path
+	FROM tree
+	ORDER BY sort_key;
+	`
+
+	var depthArg interface{} // NULL — без ограничения
+	if maxDepth >= 0 {
+		depthArg = maxDepth
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, id, depthArg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query subtree: %w", op, err)
+	}
+
+	tree, err := collectTree(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return tree, nil
+}
+
+// collectTree читает строки вида (поля комментария..., depth, path) в узлы дерева
+func collectTree(rows *sql.Rows) ([]models.CommentTreeNode, error) {
+	defer rows.Close()
+
+	var tree []models.CommentTreeNode
+	for rows.Next() {
+		var node models.CommentTreeNode
+		var path string
+		c := &node.Comment
+		err := rows.Scan(&c.ID, &c.PostId, &c.AuthorId, &c.ParentId, &c.Content, &c.CreatedAt, &c.EditedAt, &c.IsDeleted, &c.Upvotes, &c.Downvotes, &node.Depth, &path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		if node.Path, err = parsePath(path); err != nil {
+			return nil, err
+		}
+		tree = append(tree, node)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return tree, nil
+}
+
+// parsePath разбирает путь вида "1,5,12," в ID предков; у корневых комментариев путь пустой.
+func parsePath(path string) ([]int, error) {
+	ids := []int{}
+	for _, part := range strings.Split(strings.TrimSuffix(path, ","), ",") {
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid comment path %q: %w", path, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// Получение комментария по ID
+func (s *Storage) GetComment(ctx context.Context, id int) (models.Comment, error) {
+	const op = "storage.sqlite.GetComment"
+
+	query := `
+	SELECT id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes
+	FROM comments WHERE id = ?1;
+	`
+
+	var comment models.Comment
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&comment.ID,
+		&comment.PostId,
+		&comment.AuthorId,
+		&comment.ParentId,
+		&comment.Content,
+		&comment.CreatedAt,
+		&comment.EditedAt,
+		&comment.IsDeleted,
+		&comment.Upvotes,
+		&comment.Downvotes,
+	)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("%s: failed to query comment: %w", op, mapError(err))
+	}
+
+	return comment, nil
+}
+
+// Получение цепочки предков комментария (от корня к родителю)
+func (s *Storage) GetAncestors(ctx context.Context, id, limit int) ([]models.Comment, error) {
+	const op = "storage.sqlite.GetAncestors"
+
+	if limit == 0 {
+		return []models.Comment{}, nil
+	}
+
+	query := `
+	WITH RECURSIVE ancestors AS (
+		SELECT p.id, p.post_id, p.author_id, p.parent_id, p.content, p.created_at, p.edited_at, p.is_deleted, p.upvotes, p.downvotes, 1 AS level
+		FROM comments c
+		JOIN comments p ON p.id = c.parent_id
+		WHERE c.id = ?1
+		UNION ALL
+		SELECT p.id, p.post_id, p.author_id, p.parent_id, p.content, p.created_at, p.edited_at, p.is_deleted, p.upvotes, p.downvotes, a.level + 1
+		FROM comments p
+		JOIN ancestors a ON p.id = a.parent_id
+		WHERE ?2 IS NULL OR a.level < ?2
+	)
+	SELECT id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes
+	FROM ancestors
+	ORDER BY level DESC;
+	`
+
+	var limitArg interface{} // NULL — вся цепочка
+	if limit > 0 {
+		limitArg = limit
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, id, limitArg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query ancestors: %w", op, err)
+	}
+
+	ancestors, err := collectComments(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ancestors, nil
+}
+
+// Получение комментария вместе с предками, соседями того же уровня и первыми ответами
+func (s *Storage) GetCommentContext(ctx context.Context, id, parentsAbove, siblings, repliesBelow int) (models.CommentContext, error) {
+	const op = "storage.sqlite.GetCommentContext"
+
+	comment, err := s.GetComment(ctx, id)
+	if err != nil {
+		return models.CommentContext{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	result := models.CommentContext{Comment: comment}
+
+	result.Ancestors, err = s.GetAncestors(ctx, id, parentsAbove)
+	if err != nil {
+		return models.CommentContext{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Соседи — комментарии того же поста с тем же родителем (или тоже корневые)
+	beforeQuery := `
+	SELECT id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes
+	FROM comments
+	WHERE post_id = ?1 AND parent_id IS ?2
+	  AND (created_at, id) < (?3, ?4)
+	ORDER BY created_at DESC, id DESC
+	LIMIT ?5;
+	`
+	afterQuery := `
+	SELECT id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes
+	FROM comments
+	WHERE post_id = ?1 AND parent_id IS ?2
+	  AND (created_at, id) > (?3, ?4)
+	ORDER BY created_at, id
+	LIMIT ?5;
+	`
+
+	createdAt := formatTime(comment.CreatedAt)
+
+	rows, err := s.db.QueryContext(ctx, beforeQuery, comment.PostId, comment.ParentId, createdAt, comment.ID, siblings)
+	if err != nil {
+		return models.CommentContext{}, fmt.Errorf("%s: failed to query siblings: %w", op, err)
+	}
+	before, err := collectComments(rows)
+	if err != nil {
+		return models.CommentContext{}, fmt.Errorf("%s: %w", op, err)
+	}
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+	result.SiblingsBefore = before
+
+	rows, err = s.db.QueryContext(ctx, afterQuery, comment.PostId, comment.ParentId, createdAt, comment.ID, siblings)
+	if err != nil {
+		return models.CommentContext{}, fmt.Errorf("%s: failed to query siblings: %w", op, err)
+	}
+	result.SiblingsAfter, err = collectComments(rows)
+	if err != nil {
+		return models.CommentContext{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	replies, err := s.GetChildComments(ctx, id, models.SortOldest, models.PageParams{First: repliesBelow})
+	if err != nil {
+		return models.CommentContext{}, fmt.Errorf("%s: %w", op, err)
+	}
+	result.Replies = replies.Comments
+
+	return result, nil
+}
+
+// Создание комментария. Проверка AllowComments и вставка выполняются в одной транзакции,
+// которая сразу берет блокировку базы на запись: параллельный blockComments дождется
+// завершения вставки или вставка увидит уже заблокированный пост.
+func (s *Storage) CreateComment(ctx context.Context, postID int, authorID int, parentID *int, content string) (models.Comment, error) {
+	const op = "storage.sqlite.CreateComment"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	var allowComments bool
+	err = tx.QueryRowContext(ctx, `SELECT allow_comments FROM posts WHERE id = ?1;`, postID).Scan(&allowComments)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("%s: failed to query post: %w", op, mapError(err))
+	}
+	if !allowComments {
+		return models.Comment{}, fmt.Errorf("%s: post %d: %w", op, postID, storage.ErrCommentsBlock)
+	}
+
+	query := `
+	INSERT INTO comments (post_id, author_id, parent_id, content, created_at) VALUES (?1, ?2, ?3, ?4, ?5)
+	RETURNING id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes;
+	`
+
+	var comment models.Comment
+	err = tx.QueryRowContext(ctx, query, postID, authorID, parentID, content, now()).Scan(
+		&comment.ID,
+		&comment.PostId,
+		&comment.AuthorId,
+		&comment.ParentId,
+		&comment.Content,
+		&comment.CreatedAt,
+		&comment.EditedAt,
+		&comment.IsDeleted,
+		&comment.Upvotes,
+		&comment.Downvotes,
+	)
+	if err != nil {
+		return models.Comment{}, fmt.Errorf("%s: failed to insert comment: %w", op, mapError(err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return models.Comment{}, fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return comment, nil
+}
+
+// Редактирование текста комментария (удаленные комментарии не редактируются)
+func (s *Storage) UpdateComment(ctx context.Context, id int, content string) error {
+	const op = "storage.sqlite.UpdateComment"
+
+	query := `UPDATE comments SET content = ?2, edited_at = ?3 WHERE id = ?1 AND NOT is_deleted;`
+
+	res, err := s.db.ExecContext(ctx, query, id, content, now())
+	if err != nil {
+		return fmt.Errorf("%s: failed to update comment: %w", op, mapError(err))
+	}
+
+	return checkAffected(op, res)
+}
+
+// Мягкое удаление комментария: запись остается в дереве, текст стирается
+func (s *Storage) DeleteComment(ctx context.Context, id int) error {
+	const op = "storage.sqlite.DeleteComment"
+
+	query := `UPDATE comments SET content = '', is_deleted = TRUE WHERE id = ?1;`
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("%s: failed to delete comment: %w", op, err)
+	}
+
+	return checkAffected(op, res)
+}
+
+// Голос пользователя за комментарий: 1, -1 или 0 (отозвать голос).
+// Счетчики upvotes/downvotes в comments обновляются в той же транзакции.
+func (s *Storage) VoteComment(ctx context.Context, commentID, userID, value int) error {
+	const op = "storage.sqlite.VoteComment"
+
+	// Транзакция сразу блокирует базу на запись, поэтому голоса применяются последовательно
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: failed to begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// За удаленный комментарий голосовать нельзя, как за несуществующий
+	err = tx.QueryRowContext(ctx, `SELECT id FROM comments WHERE id = ?1 AND NOT is_deleted;`, commentID).Scan(&commentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: failed to query comment: %w", op, err)
+	}
+
+	var old int
+	err = tx.QueryRowContext(ctx, `SELECT value FROM comment_votes WHERE comment_id = ?1 AND user_id = ?2;`, commentID, userID).Scan(&old)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: failed to query vote: %w", op, err)
+	}
+
+	if old == value {
+		return tx.Commit()
+	}
+
+	if value == 0 {
+		_, err = tx.ExecContext(ctx, `DELETE FROM comment_votes WHERE comment_id = ?1 AND user_id = ?2;`, commentID, userID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO comment_votes (comment_id, user_id, value, created_at) VALUES (?1, ?2, ?3, ?4)
+		ON CONFLICT (comment_id, user_id) DO UPDATE SET value = excluded.value, created_at = excluded.created_at;
+		`, commentID, userID, value, now())
+	}
+	if err != nil {
+		return fmt.Errorf("%s: failed to save vote: %w", op, mapError(err))
+	}
+
+	upDelta, downDelta := voteDelta(old, value)
+	_, err = tx.ExecContext(ctx, `UPDATE comments SET upvotes = upvotes + ?2, downvotes = downvotes + ?3 WHERE id = ?1;`,
+		commentID, upDelta, downDelta)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update counters: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: failed to commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// Голоса пользователя за комментарии в порядке commentIDs (0 — голоса нет)
+func (s *Storage) GetUserVotes(ctx context.Context, userID int, commentIDs []int) ([]int, error) {
+	const op = "storage.sqlite.GetUserVotes"
+
+	query := `SELECT comment_id, value FROM comment_votes WHERE user_id = ?1 AND comment_id IN (SELECT value FROM json_each(?2));`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, keysArg(commentIDs))
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query votes: %w", op, err)
+	}
+	defer rows.Close()
+
+	votes := make(map[int]int)
+	for rows.Next() {
+		var commentID, value int
+		if err := rows.Scan(&commentID, &value); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan vote: %w", op, err)
+		}
+		votes[commentID] = value
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows error: %w", op, err)
+	}
+
+	result := make([]int, len(commentIDs))
+	for i, id := range commentIDs {
+		result[i] = votes[id]
+	}
+
+	return result, nil
+}
+
+// voteDelta считает изменение счетчиков при смене голоса old -> value
+func voteDelta(old, value int) (up, down int) {
+	switch old {
+	case 1:
+		up--
+	case -1:
+		down--
+	}
+	switch value {
+	case 1:
+		up++
+	case -1:
+		down++
+	}
+	return up, down
+}
+
+// Посты в порядке ids; ненайденным соответствует nil
+func (s *Storage) GetPostsByID(ctx context.Context, ids []int) ([]*models.Post, error) {
+	const op = "storage.sqlite.GetPostsByID"
+
+	query := `
+	SELECT id, author_id, title, content, allow_comments, created_at, comments_locked_reason, comments_locked_at
+	FROM posts WHERE id IN (SELECT value FROM json_each(?1));
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, keysArg(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query posts: %w", op, err)
+	}
+	defer rows.Close()
+
+	posts := make(map[int]*models.Post, len(ids))
+	for rows.Next() {
+		var post models.Post
+		if err := rows.Scan(
+			&post.ID,
+			&post.AuthorId,
+			&post.Title,
+			&post.Content,
+			&post.AllowComments,
+			&post.CreatedAt,
+			&post.CommentsLockedReason,
+			&post.CommentsLockedAt,
+		); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan post: %w", op, err)
+		}
+		posts[post.ID] = &post
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows error: %w", op, err)
+	}
+
+	result := make([]*models.Post, len(ids))
+	for i, id := range ids {
+		result[i] = posts[id]
+	}
+
+	return result, nil
+}
+
+// Комментарии в порядке ids; ненайденным соответствует nil
+func (s *Storage) GetCommentsByID(ctx context.Context, ids []int) ([]*models.Comment, error) {
+	const op = "storage.sqlite.GetCommentsByID"
+
+	query := `
+	SELECT id, post_id, author_id, parent_id, content, created_at, edited_at, is_deleted, upvotes, downvotes
+	FROM comments WHERE id IN (SELECT value FROM json_each(?1));
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, keysArg(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query comments: %w", op, err)
+	}
+
+	list, err := collectComments(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	comments := make(map[int]*models.Comment, len(list))
+	for i := range list {
+		comments[list[i].ID] = &list[i]
+	}
+
+	result := make([]*models.Comment, len(ids))
+	for i, id := range ids {
+		result[i] = comments[id]
+	}
+
+	return result, nil
+}
+
+// Счетчики комментариев постов в порядке ids (поддерживаются триггерами)
+func (s *Storage) GetPostStats(ctx context.Context, ids []int) ([]models.PostStats, error) {
+	const op = "storage.sqlite.GetPostStats"
+
+	query := `SELECT id, comments_count, last_comment_at FROM posts WHERE id IN (SELECT value FROM json_each(?1));`
+
+	rows, err := s.db.QueryContext(ctx, query, keysArg(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query stats: %w", op, err)
+	}
+	defer rows.Close()
+
+	stats := make(map[int]models.PostStats, len(ids))
+	for rows.Next() {
+		var id int
+		var st models.PostStats
+		if err := rows.Scan(&id, &st.CommentsCount, &st.LastCommentAt); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan stats: %w", op, err)
+		}
+		stats[id] = st
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows error: %w", op, err)
+	}
+
+	result := make([]models.PostStats, len(ids))
+	for i, id := range ids {
+		result[i] = stats[id]
+	}
+
+	return result, nil
+}
+
+// Счетчики ответов комментариев в порядке ids (поддерживаются триггерами)
+func (s *Storage) GetCommentStats(ctx context.Context, ids []int) ([]models.CommentStats, error) {
+	const op = "storage.sqlite.GetCommentStats"
+
+	query := `SELECT id, replies_count, descendants_count FROM comments WHERE id IN (SELECT value FROM json_each(?1));`
+
+	rows, err := s.db.QueryContext(ctx, query, keysArg(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query stats: %w", op, err)
+	}
+	defer rows.Close()
+
+	stats := make(map[int]models.CommentStats, len(ids))
+	for rows.Next() {
+		var id int
+		var st models.CommentStats
+		if err := rows.Scan(&id, &st.RepliesCount, &st.DescendantsCount); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan stats: %w", op, err)
+		}
+		stats[id] = st
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows error: %w", op, err)
+	}
+
+	result := make([]models.CommentStats, len(ids))
+	for i, id := range ids {
+		result[i] = stats[id]
+	}
+
+	return result, nil
+}
+
+// Страницы корневых комментариев для нескольких постов (для DataLoader)
+func (s *Storage) GetRootCommentPages(ctx context.Context, postIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	const op = "storage.sqlite.GetRootCommentPages"
+
+	pages, err := s.queryCommentPages(ctx, "post_id", "parent_id IS NULL", postIDs, sort, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pages, nil
+}
+
+// Страницы ответов для нескольких комментариев (для DataLoader)
+func (s *Storage) GetChildCommentPages(ctx context.Context, parentIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	const op = "storage.sqlite.GetChildCommentPages"
+
+	pages, err := s.queryCommentPages(ctx, "parent_id", "TRUE", parentIDs, sort, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pages, nil
+}
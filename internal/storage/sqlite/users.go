@@ -0,0 +1,147 @@
+package sqlite
+
+import (
+	"Habr-comments-server/internal/models"
+	"context"
+	"fmt"
+)
+
+// Регистрация пользователя
+func (s *Storage) CreateUser(ctx context.Context, username, passwordHash string, role models.Role) (int, error) {
+	const op = "storage.sqlite.CreateUser"
+
+	query := `INSERT INTO users (username, password_hash, role, created_at) VALUES (?1, ?2, ?3, ?4) RETURNING id;`
+
+	var userID int
+	err := s.db.QueryRowContext(ctx, query, username, passwordHash, role, now()).Scan(&userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to insert user: %w", op, mapError(err))
+	}
+
+	return userID, nil
+}
+
+// Получение пользователя по ID
+func (s *Storage) GetUser(ctx context.Context, id int) (models.User, error) {
+	const op = "storage.sqlite.GetUser"
+
+	query := `SELECT id, username, COALESCE(password_hash, ''), role, created_at FROM users WHERE id = ?1;`
+
+	var user models.User
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: failed to query user: %w", op, mapError(err))
+	}
+
+	return user, nil
+}
+
+// Получение пользователя по имени (для входа)
+func (s *Storage) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	const op = "storage.sqlite.GetUserByUsername"
+
+	query := `SELECT id, username, COALESCE(password_hash, ''), role, created_at FROM users WHERE username = ?1;`
+
+	var user models.User
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: failed to query user: %w", op, mapError(err))
+	}
+
+	return user, nil
+}
+
+// Назначение роли пользователю
+func (s *Storage) SetUserRole(ctx context.Context, id int, role models.Role) error {
+	const op = "storage.sqlite.SetUserRole"
+
+	query := `UPDATE users SET role = ?2 WHERE id = ?1;`
+
+	res, err := s.db.ExecContext(ctx, query, id, role)
+	if err != nil {
+		return fmt.Errorf("%s: failed to update role: %w", op, mapError(err))
+	}
+
+	return checkAffected(op, res)
+}
+
+// Пользователи в порядке ids; ненайденным соответствует nil
+func (s *Storage) GetUsersByID(ctx context.Context, ids []int) ([]*models.User, error) {
+	const op = "storage.sqlite.GetUsersByID"
+
+	query := `SELECT id, username, role, created_at FROM users WHERE id IN (SELECT value FROM json_each(?1));`
+
+	rows, err := s.db.QueryContext(ctx, query, keysArg(ids))
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to query users: %w", op, err)
+	}
+	defer rows.Close()
+
+	users := make(map[int]*models.User, len(ids))
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Role, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: failed to scan user: %w", op, err)
+		}
+		users[user.ID] = &user
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows error: %w", op, err)
+	}
+
+	result := make([]*models.User, len(ids))
+	for i, id := range ids {
+		result[i] = users[id]
+	}
+
+	return result, nil
+}
+
+// Посты пользователя с keyset-пагинацией (новые сверху)
+func (s *Storage) GetUserPosts(ctx context.Context, userID int, page models.PageParams) (models.PostPage, error) {
+	const op = "storage.sqlite.GetUserPosts"
+
+	result, err := s.queryPostPage(ctx, "author_id", "TRUE", userID, page)
+	if err != nil {
+		return models.PostPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// Комментарии пользователя с keyset-пагинацией (новые сверху)
+func (s *Storage) GetUserComments(ctx context.Context, userID int, page models.PageParams) (models.CommentPage, error) {
+	const op = "storage.sqlite.GetUserComments"
+
+	result, err := s.queryCommentPage(ctx, "author_id", "TRUE", userID, models.SortNewest, page)
+	if err != nil {
+		return models.CommentPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return result, nil
+}
+
+// Страницы постов для нескольких пользователей (для DataLoader)
+func (s *Storage) GetUserPostPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.PostPage, error) {
+	const op = "storage.sqlite.GetUserPostPages"
+
+	pages, err := s.queryPostPages(ctx, "author_id", "TRUE", userIDs, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pages, nil
+}
+
+// Страницы комментариев для нескольких пользователей (для DataLoader)
+func (s *Storage) GetUserCommentPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.CommentPage, error) {
+	const op = "storage.sqlite.GetUserCommentPages"
+
+	pages, err := s.queryCommentPages(ctx, "author_id", "TRUE", userIDs, models.SortNewest, page)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return pages, nil
+}
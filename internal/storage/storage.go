@@ -6,4 +6,5 @@ var (
 	ErrAlreadyExists = errors.New("already exists")
 	ErrNotFound      = errors.New("not found")
 	ErrCommentsBlock = errors.New("comments block")
+	ErrValidation    = errors.New("validation failed") // Данные нарушают ограничения хранилища
 )
@@ -0,0 +1,205 @@
+package validation
+
+import (
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Имена полей совпадают с аргументами мутаций в schema.graphql
+const (
+	FieldTitle    = "title"
+	FieldContent  = "content"
+	FieldParentID = "parentId"
+	FieldValue    = "value"
+	FieldUsername = "username"
+	FieldPassword = "password"
+)
+
+const (
+	minUsernameLen = 3
+	maxUsernameLen = 32
+	minPasswordLen = 8
+	maxPasswordLen = 72 // bcrypt игнорирует все, что дальше 72 байт
+)
+
+// FieldError — ошибка в одном аргументе.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error — все ошибки проверки входных данных; в цепочке ошибок это storage.ErrValidation.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *Error) Unwrap() error { return storage.ErrValidation }
+
+func (e *Error) add(field, format string, args ...any) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err возвращает nil, если ошибок нет: иначе типизированный nil попал бы в интерфейс error.
+func (e *Error) err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// CommentFinder — то, что нужно Validator для проверки родительского комментария.
+type CommentFinder interface {
+	GetComment(ctx context.Context, id int) (models.Comment, error)
+	GetAncestors(ctx context.Context, id, limit int) ([]models.Comment, error)
+}
+
+// Validator проверяет аргументы мутаций одинаково для всех хранилищ.
+type Validator struct {
+	limits   config.Validation
+	comments CommentFinder
+}
+
+// New создает Validator; limits должны пройти config.Validation.Validate, это проверяется при загрузке конфигурации.
+func New(limits config.Validation, comments CommentFinder) *Validator {
+	return &Validator{
+		limits:   limits,
+		comments: comments,
+	}
+}
+
+// Post проверяет заголовок и текст нового поста.
+func (v *Validator) Post(title, content string) error {
+	e := &Error{}
+	v.checkTitle(e, title)
+	v.checkText(e, FieldContent, content, v.limits.MaxPostLen)
+	return e.err()
+}
+
+// PostUpdate проверяет переданные поля при редактировании поста.
+func (v *Validator) PostUpdate(title, content *string) error {
+	e := &Error{}
+	if title != nil {
+		v.checkTitle(e, *title)
+	}
+	if content != nil {
+		v.checkText(e, FieldContent, *content, v.limits.MaxPostLen)
+	}
+	return e.err()
+}
+
+// Comment проверяет текст нового комментария и то, что родитель существует, относится к тому же посту
+// и ответ на него не глубже MaxCommentDepth.
+func (v *Validator) Comment(ctx context.Context, postID int, parentID *int, content string) error {
+	e := &Error{}
+	v.checkText(e, FieldContent, content, v.limits.MaxCommentLen)
+
+	if parentID != nil {
+		parent, err := v.comments.GetComment(ctx, *parentID)
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			e.add(FieldParentID, "comment %d does not exist", *parentID)
+		case err != nil:
+			return fmt.Errorf("failed to fetch parent comment: %w", err)
+		case parent.PostId != postID:
+			e.add(FieldParentID, "comment %d belongs to another post", *parentID)
+		case parent.ParentId != nil:
+			if err := v.checkDepth(ctx, e, *parentID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return e.err()
+}
+
+// checkDepth проверяет, что ответ на комментарий parentID не глубже MaxCommentDepth.
+// Глубина ответа на единицу больше числа предков родителя, поэтому больше MaxCommentDepth предков не читается.
+func (v *Validator) checkDepth(ctx context.Context, e *Error, parentID int) error {
+	ancestors, err := v.comments.GetAncestors(ctx, parentID, v.limits.MaxCommentDepth)
+	if err != nil {
+		return fmt.Errorf("failed to fetch parent ancestors: %w", err)
+	}
+	if len(ancestors) >= v.limits.MaxCommentDepth {
+		e.add(FieldParentID, "replies may be nested at most %d levels deep", v.limits.MaxCommentDepth)
+	}
+	return nil
+}
+
+// CommentUpdate проверяет новый текст комментария.
+func (v *Validator) CommentUpdate(content string) error {
+	e := &Error{}
+	v.checkText(e, FieldContent, content, v.limits.MaxCommentLen)
+	return e.err()
+}
+
+// Vote проверяет значение голоса: 1, -1 или 0, чтобы отозвать голос.
+func (v *Validator) Vote(value int) error {
+	e := &Error{}
+	if value < -1 || value > 1 {
+		e.add(FieldValue, "must be -1, 0 or 1")
+	}
+	return e.err()
+}
+
+// User проверяет имя и пароль при регистрации.
+func (v *Validator) User(username, password string) error {
+	e := &Error{}
+
+	if n := utf8.RuneCountInString(username); n < minUsernameLen || n > maxUsernameLen {
+		e.add(FieldUsername, "must be %d to %d characters long", minUsernameLen, maxUsernameLen)
+	} else if strings.IndexFunc(username, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.'
+	}) >= 0 {
+		e.add(FieldUsername, "may contain only letters, digits, '_', '-' and '.'")
+	}
+
+	if len(password) < minPasswordLen {
+		e.add(FieldPassword, "must be at least %d characters long", minPasswordLen)
+	} else if len(password) > maxPasswordLen {
+		e.add(FieldPassword, "must be at most %d bytes long", maxPasswordLen)
+	}
+
+	return e.err()
+}
+
+func (v *Validator) checkTitle(e *Error, title string) {
+	if strings.IndexFunc(title, unicode.IsControl) >= 0 {
+		e.add(FieldTitle, "must not contain control characters")
+		return
+	}
+	v.checkText(e, FieldTitle, title, v.limits.MaxTitleLen)
+}
+
+// checkText проверяет, что текст не пустой, не длиннее maxLen символов
+// и не содержит управляющих символов, кроме переводов строк и табуляции.
+func (v *Validator) checkText(e *Error, field, text string, maxLen int) {
+	switch {
+	case !utf8.ValidString(text):
+		e.add(field, "must be valid UTF-8")
+	case strings.TrimSpace(text) == "":
+		e.add(field, "must not be blank")
+	case utf8.RuneCountInString(text) > maxLen:
+		e.add(field, "must be at most %d characters long", maxLen)
+	case strings.IndexFunc(text, isForbiddenControl) >= 0:
+		e.add(field, "must not contain control characters")
+	}
+}
+
+func isForbiddenControl(r rune) bool {
+	return unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t'
+}
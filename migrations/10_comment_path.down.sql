@@ -0,0 +1,8 @@
+DROP TRIGGER comments_path_insert ON comments;
+DROP FUNCTION comments_path_insert();
+
+DROP INDEX idx_comments_post_path;
+
+ALTER TABLE comments DROP COLUMN path;
+
+DROP FUNCTION comment_path_key(TIMESTAMP, INT);
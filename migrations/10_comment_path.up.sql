@@ -0,0 +1,48 @@
+-- Материализованный путь комментария: ключи всех предков от корня и самого комментария через точку.
+-- Ключ — created_at в микросекундах от начала эпохи (14 шестнадцатеричных цифр) и id (8 цифр),
+-- то есть порядок соседей в ветке (created_at, id). Все ключи одной длины, поэтому при побайтовом
+-- сравнении (COLLATE "C") сортировка по path дает обход дерева pre-order, а поддерево комментария
+-- занимает диапазон [path, path || '/'): символ '/' идет сразу после '.'.
+-- Путь хранится в индексе (post_id, path), а строка btree-индекса ограничена примерно 2,7 КБ:
+-- при 23 байтах на уровень это чуть больше сотни уровней. Поэтому глубина ответов ограничена
+-- настройкой validation.max_comment_depth (не больше 100): путь такой глубины занимает 2322 байта.
+CREATE FUNCTION comment_path_key(created_at TIMESTAMP, id INT) RETURNS TEXT AS $$
+    SELECT lpad(to_hex((extract(epoch FROM created_at) * 1000000)::bigint), 14, '0')
+        || lpad(to_hex(id), 8, '0');
+$$ LANGUAGE sql IMMUTABLE;
+
+ALTER TABLE comments ADD COLUMN path TEXT COLLATE "C";
+
+WITH RECURSIVE tree AS (
+    SELECT id, comment_path_key(created_at, id) AS path
+    FROM comments
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT c.id, t.path || '.' || comment_path_key(c.created_at, c.id)
+    FROM comments c
+    JOIN tree t ON c.parent_id = t.id
+)
+UPDATE comments c
+SET path = t.path
+FROM tree t
+WHERE c.id = t.id;
+
+ALTER TABLE comments ALTER COLUMN path SET NOT NULL;
+
+CREATE INDEX idx_comments_post_path ON comments(post_id, path);
+
+-- Путь вычисляется до вставки: id и created_at уже заполнены значениями по умолчанию.
+-- Если родителя нет, путь считается как у корня, и вставку отклонит внешний ключ parent_id.
+-- Родитель и время создания комментария не меняются, поэтому путь пересчитывать не нужно.
+CREATE FUNCTION comments_path_insert() RETURNS trigger AS $$
+BEGIN
+    NEW.path := COALESCE((SELECT path || '.' FROM comments WHERE id = NEW.parent_id), '')
+        || comment_path_key(NEW.created_at, NEW.id);
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER comments_path_insert
+    BEFORE INSERT ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_path_insert();
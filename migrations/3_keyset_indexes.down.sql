@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_comments_parent_created;
+
+DROP INDEX IF EXISTS idx_comments_post_root_created;
+
+DROP INDEX IF EXISTS idx_posts_created;
@@ -0,0 +1,5 @@
+CREATE INDEX idx_posts_created ON posts(created_at DESC, id DESC);
+
+CREATE INDEX idx_comments_post_root_created ON comments(post_id, created_at, id) WHERE parent_id IS NULL;
+
+CREATE INDEX idx_comments_parent_created ON comments(parent_id, created_at, id);
@@ -0,0 +1,7 @@
+ALTER TABLE comments
+    DROP CONSTRAINT comments_parent_id_fkey,
+    ADD CONSTRAINT comments_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE SET NULL;
+
+ALTER TABLE comments
+    DROP COLUMN is_deleted,
+    DROP COLUMN edited_at;
@@ -0,0 +1,9 @@
+ALTER TABLE comments
+    ADD COLUMN edited_at TIMESTAMP,
+    ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Комментарии удаляются мягко, поэтому ответы больше не должны превращаться в корневые.
+-- NO ACTION проверяется в конце запроса, так что каскадное удаление поста по-прежнему работает.
+ALTER TABLE comments
+    DROP CONSTRAINT comments_parent_id_fkey,
+    ADD CONSTRAINT comments_parent_id_fkey FOREIGN KEY (parent_id) REFERENCES comments(id);
@@ -0,0 +1,3 @@
+ALTER TABLE posts
+    DROP COLUMN comments_locked_at,
+    DROP COLUMN comments_locked_reason;
@@ -0,0 +1,5 @@
+ALTER TABLE posts
+    ADD COLUMN comments_locked_reason TEXT,
+    ADD COLUMN comments_locked_at TIMESTAMP;
+
+UPDATE posts SET comments_locked_at = created_at WHERE NOT allow_comments;
@@ -0,0 +1,5 @@
+ALTER TABLE comments
+    DROP COLUMN downvotes,
+    DROP COLUMN upvotes;
+
+DROP TABLE IF EXISTS comment_votes;
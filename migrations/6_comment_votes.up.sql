@@ -0,0 +1,14 @@
+CREATE TABLE comment_votes (
+                               comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+                               user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                               value SMALLINT NOT NULL CHECK (value IN (-1, 1)),
+                               created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+                               PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX idx_comment_votes_user ON comment_votes(user_id);
+
+-- Агрегированные счетчики, чтобы списки комментариев отдавали рейтинг без дополнительных запросов
+ALTER TABLE comments
+    ADD COLUMN upvotes INT NOT NULL DEFAULT 0,
+    ADD COLUMN downvotes INT NOT NULL DEFAULT 0;
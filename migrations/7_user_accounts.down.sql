@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_comments_author_created;
+
+DROP INDEX IF EXISTS idx_posts_author_created;
+
+ALTER TABLE users
+    DROP COLUMN created_at,
+    DROP COLUMN password_hash;
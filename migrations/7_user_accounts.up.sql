@@ -0,0 +1,8 @@
+-- У пользователей из сидов пароля нет: войти под ними нельзя
+ALTER TABLE users
+    ADD COLUMN password_hash TEXT,
+    ADD COLUMN created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_posts_author_created ON posts(author_id, created_at DESC, id DESC);
+
+CREATE INDEX idx_comments_author_created ON comments(author_id, created_at DESC, id DESC);
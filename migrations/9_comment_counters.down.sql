@@ -0,0 +1,12 @@
+DROP TRIGGER comments_counters_delete ON comments;
+DROP TRIGGER comments_counters_insert ON comments;
+DROP FUNCTION comments_counters_delete();
+DROP FUNCTION comments_counters_insert();
+
+ALTER TABLE comments
+    DROP COLUMN descendants_count,
+    DROP COLUMN replies_count;
+
+ALTER TABLE posts
+    DROP COLUMN last_comment_at,
+    DROP COLUMN comments_count;
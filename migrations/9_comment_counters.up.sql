@@ -0,0 +1,89 @@
+-- Денормализованные счетчики: бейджи "42 комментария" и ссылки "показать 7 ответов"
+-- без загрузки самих комментариев. Поддерживаются триггерами на comments.
+ALTER TABLE posts
+    ADD COLUMN comments_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN last_comment_at TIMESTAMP;
+
+ALTER TABLE comments
+    ADD COLUMN replies_count INT NOT NULL DEFAULT 0,     -- Прямые ответы
+    ADD COLUMN descendants_count INT NOT NULL DEFAULT 0; -- Все ответы в поддереве
+
+UPDATE posts p
+SET comments_count = s.cnt, last_comment_at = s.last
+FROM (SELECT post_id, count(*) AS cnt, max(created_at) AS last FROM comments GROUP BY post_id) s
+WHERE p.id = s.post_id;
+
+UPDATE comments c
+SET replies_count = s.cnt
+FROM (SELECT parent_id, count(*) AS cnt FROM comments WHERE parent_id IS NOT NULL GROUP BY parent_id) s
+WHERE c.id = s.parent_id;
+
+WITH RECURSIVE tree AS (
+    SELECT id AS ancestor_id, id FROM comments
+    UNION ALL
+    SELECT t.ancestor_id, c.id FROM comments c JOIN tree t ON c.parent_id = t.id
+)
+UPDATE comments c
+SET descendants_count = s.cnt
+FROM (SELECT ancestor_id, count(*) - 1 AS cnt FROM tree GROUP BY ancestor_id) s
+WHERE c.id = s.ancestor_id;
+
+CREATE FUNCTION comments_counters_insert() RETURNS trigger AS $$
+BEGIN
+    UPDATE posts
+    SET comments_count = comments_count + 1,
+        last_comment_at = GREATEST(last_comment_at, NEW.created_at)
+    WHERE id = NEW.post_id;
+
+    IF NEW.parent_id IS NOT NULL THEN
+        UPDATE comments SET replies_count = replies_count + 1 WHERE id = NEW.parent_id;
+
+        WITH RECURSIVE ancestors AS (
+            SELECT id, parent_id FROM comments WHERE id = NEW.parent_id
+            UNION ALL
+            SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+        )
+        UPDATE comments SET descendants_count = descendants_count + 1
+        WHERE id IN (SELECT id FROM ancestors);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Комментарии удаляются физически только вместе с постом. Внешний ключ parent_id — NO ACTION
+-- (4_comment_edit_delete), поэтому комментарий удаляется только вместе со всеми ответами в том же
+-- запросе, иначе запрос отклоняется. AFTER-триггеры строк срабатывают в конце запроса, когда все
+-- поддерево уже удалено: у ответов удаленного комментария родителя нет, и обход предков пуст,
+-- а уцелевшие предки вершины поддерева (родитель из другого поста) теряют ее вместе со всеми
+-- ответами ровно один раз.
+CREATE FUNCTION comments_counters_delete() RETURNS trigger AS $$
+BEGIN
+    UPDATE posts
+    SET comments_count = comments_count - 1,
+        last_comment_at = (SELECT max(created_at) FROM comments WHERE post_id = OLD.post_id)
+    WHERE id = OLD.post_id;
+
+    IF OLD.parent_id IS NOT NULL THEN
+        UPDATE comments SET replies_count = replies_count - 1 WHERE id = OLD.parent_id;
+
+        WITH RECURSIVE ancestors AS (
+            SELECT id, parent_id FROM comments WHERE id = OLD.parent_id
+            UNION ALL
+            SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+        )
+        UPDATE comments SET descendants_count = descendants_count - 1 - OLD.descendants_count
+        WHERE id IN (SELECT id FROM ancestors);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER comments_counters_insert
+    AFTER INSERT ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_counters_insert();
+
+CREATE TRIGGER comments_counters_delete
+    AFTER DELETE ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_counters_delete();
@@ -0,0 +1,558 @@
+package conformance
+
+import (
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCreateComment(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post := f.post(author, true)
+
+	root, err := f.s.CreateComment(f.ctx, post, author, nil, "root")
+	require.NoError(t, err)
+	assert.Equal(t, post, root.PostId)
+	assert.Equal(t, author, root.AuthorId)
+	assert.Nil(t, root.ParentId)
+	assert.Equal(t, "root", root.Content)
+	assert.False(t, root.CreatedAt.IsZero())
+	assert.Nil(t, root.EditedAt)
+	assert.False(t, root.IsDeleted)
+	assert.Zero(t, root.Upvotes)
+	assert.Zero(t, root.Downvotes)
+
+	got, err := f.s.GetComment(f.ctx, root.ID)
+	require.NoError(t, err)
+	assert.Equal(t, root.ID, got.ID)
+	assert.Equal(t, root.Content, got.Content)
+	assert.True(t, root.CreatedAt.Equal(got.CreatedAt))
+
+	reply, err := f.s.CreateComment(f.ctx, post, author, &root.ID, "reply")
+	require.NoError(t, err)
+	require.NotNil(t, reply.ParentId)
+	assert.Equal(t, root.ID, *reply.ParentId)
+
+	_, err = f.s.GetComment(f.ctx, missingID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Пост, автор и родитель должны существовать
+	_, err = f.s.CreateComment(f.ctx, missingID, author, nil, "text")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = f.s.CreateComment(f.ctx, post, missingID, nil, "text")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = f.s.CreateComment(f.ctx, post, author, ptr(missingID), "text")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Длина ограничена в символах, а не в байтах
+	_, err = f.s.CreateComment(f.ctx, post, author, nil, strings.Repeat("я", 2000))
+	assert.NoError(t, err)
+	_, err = f.s.CreateComment(f.ctx, post, author, nil, strings.Repeat("я", 2001))
+	assert.ErrorIs(t, err, storage.ErrValidation)
+}
+
+func testEditAndDeleteComment(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post := f.post(author, true)
+	root := f.comment(post, author, nil)
+	reply := f.comment(post, author, &root.ID)
+
+	require.NoError(t, f.s.UpdateComment(f.ctx, root.ID, "edited"))
+	got, err := f.s.GetComment(f.ctx, root.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "edited", got.Content)
+	assert.NotNil(t, got.EditedAt)
+
+	assert.ErrorIs(t, f.s.UpdateComment(f.ctx, missingID, "edited"), storage.ErrNotFound)
+
+	// Удаленный комментарий остается в дереве без текста, ответы сохраняются
+	require.NoError(t, f.s.DeleteComment(f.ctx, root.ID))
+	got, err = f.s.GetComment(f.ctx, root.ID)
+	require.NoError(t, err)
+	assert.True(t, got.IsDeleted)
+	assert.Empty(t, got.Content)
+
+	_, err = f.s.GetComment(f.ctx, reply.ID)
+	assert.NoError(t, err)
+
+	tree, err := f.s.GetCommentTree(f.ctx, post, -1, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{root.ID, reply.ID}, treeIDs(tree))
+
+	assert.ErrorIs(t, f.s.UpdateComment(f.ctx, root.ID, "again"), storage.ErrNotFound)
+	assert.NoError(t, f.s.DeleteComment(f.ctx, root.ID))
+	assert.ErrorIs(t, f.s.DeleteComment(f.ctx, missingID), storage.ErrNotFound)
+}
+
+func testCommentSorts(t *testing.T, f *fixture) {
+	author := f.user("author")
+	voters := []int{f.user("v1"), f.user("v2"), f.user("v3")}
+	post := f.post(author, true)
+
+	roots := f.comments(post, author, nil, 4)
+	r1, r2, r3, r4 := roots[0], roots[1], roots[2], roots[3]
+
+	// r1: +1; r2: +2 -1; r3: -1; r4 без голосов
+	f.vote(r1, voters[0], 1)
+	f.vote(r2, voters[0], 1)
+	f.vote(r2, voters[1], 1)
+	f.vote(r2, voters[2], -1)
+	f.vote(r3, voters[0], -1)
+
+	// r3: 2 ответа, r1: 1 ответ
+	f.comments(post, author, &r3, 2)
+	f.comment(post, author, &r1)
+
+	tests := []struct {
+		sort models.CommentSort
+		want []int
+	}{
+		{models.SortOldest, []int{r1, r2, r3, r4}},
+		{models.SortNewest, []int{r4, r3, r2, r1}},
+		// Рейтинги 1, 1, -1, 0: при равенстве ключа новые выше
+		{models.SortTop, []int{r2, r1, r4, r3}},
+		// Голоса меньшинства 0, 1, 0, 0
+		{models.SortControversial, []int{r2, r4, r3, r1}},
+		// Ответы 1, 0, 2, 0
+		{models.SortMostReplies, []int{r3, r1, r4, r2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.sort), func(t *testing.T) {
+			page, err := f.s.GetComments(f.ctx, post, tt.sort, models.PageParams{First: 10})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, commentIDs(page.Comments))
+			assert.Len(t, page.Cursors, len(tt.want))
+			assert.False(t, page.HasNextPage)
+			assert.Equal(t, 4, page.TotalCount)
+
+			// Постраничный обход по курсорам дает тот же порядок
+			var got []int
+			params := models.PageParams{First: 1}
+			for {
+				page, err := f.s.GetComments(f.ctx, post, tt.sort, params)
+				require.NoError(t, err)
+				require.Len(t, page.Comments, 1)
+				got = append(got, page.Comments[0].ID)
+				if !page.HasNextPage {
+					break
+				}
+				require.Less(t, len(got), len(tt.want))
+				params.After = &page.Cursors[0]
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := f.s.GetComments(f.ctx, post, models.CommentSort("RANDOM"), models.PageParams{First: 10})
+	assert.ErrorIs(t, err, storage.ErrValidation)
+}
+
+func testCommentPagination(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post := f.post(author, true)
+
+	page, err := f.s.GetComments(f.ctx, post, models.SortOldest, models.PageParams{First: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Comments)
+	assert.False(t, page.HasNextPage)
+	assert.Equal(t, 0, page.TotalCount)
+
+	roots := f.comments(post, author, nil, 3)
+	replies := f.comments(post, author, &roots[0], 3)
+
+	// Ответы не попадают в список корневых комментариев
+	page, err = f.s.GetComments(f.ctx, post, models.SortOldest, models.PageParams{First: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{roots[0], roots[1]}, commentIDs(page.Comments))
+	assert.True(t, page.HasNextPage)
+	assert.Equal(t, 3, page.TotalCount)
+
+	page, err = f.s.GetComments(f.ctx, post, models.SortOldest, models.PageParams{First: 2, After: &page.Cursors[1]})
+	require.NoError(t, err)
+	assert.Equal(t, []int{roots[2]}, commentIDs(page.Comments))
+	assert.False(t, page.HasNextPage)
+
+	page, err = f.s.GetComments(f.ctx, post, models.SortOldest, models.PageParams{First: 2, After: &page.Cursors[0]})
+	require.NoError(t, err)
+	assert.Empty(t, page.Comments)
+	assert.False(t, page.HasNextPage)
+	assert.Equal(t, 3, page.TotalCount)
+
+	page, err = f.s.GetComments(f.ctx, post, models.SortOldest, models.PageParams{First: 3})
+	require.NoError(t, err)
+	assert.Len(t, page.Comments, 3)
+	assert.False(t, page.HasNextPage)
+
+	page, err = f.s.GetComments(f.ctx, post, models.SortOldest, models.PageParams{First: 0})
+	require.NoError(t, err)
+	assert.Empty(t, page.Comments)
+	assert.True(t, page.HasNextPage)
+
+	children, err := f.s.GetChildComments(f.ctx, roots[0], models.SortNewest, models.PageParams{First: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{replies[2], replies[1]}, commentIDs(children.Comments))
+	assert.True(t, children.HasNextPage)
+	assert.Equal(t, 3, children.TotalCount)
+
+	children, err = f.s.GetChildComments(f.ctx, roots[0], models.SortNewest, models.PageParams{First: 2, After: &children.Cursors[1]})
+	require.NoError(t, err)
+	assert.Equal(t, []int{replies[0]}, commentIDs(children.Comments))
+	assert.False(t, children.HasNextPage)
+
+	children, err = f.s.GetChildComments(f.ctx, roots[1], models.SortOldest, models.PageParams{First: 2})
+	require.NoError(t, err)
+	assert.Empty(t, children.Comments)
+	assert.False(t, children.HasNextPage)
+	assert.Equal(t, 0, children.TotalCount)
+}
+
+func testVotes(t *testing.T, f *fixture) {
+	author, voter := f.user("author"), f.user("voter")
+	post := f.post(author, true)
+	comment := f.comment(post, author, nil)
+
+	steps := []struct {
+		value      int
+		up, down   int
+		storedVote int
+	}{
+		{1, 1, 0, 1},
+		{1, 1, 0, 1}, // повторный голос ничего не меняет
+		{-1, 0, 1, -1},
+		{0, 0, 0, 0},
+		{0, 0, 0, 0},
+	}
+
+	for _, step := range steps {
+		f.vote(comment.ID, voter, step.value)
+
+		got, err := f.s.GetComment(f.ctx, comment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, step.up, got.Upvotes, "after vote %d", step.value)
+		assert.Equal(t, step.down, got.Downvotes, "after vote %d", step.value)
+
+		votes, err := f.s.GetUserVotes(f.ctx, voter, []int{comment.ID})
+		require.NoError(t, err)
+		assert.Equal(t, []int{step.storedVote}, votes)
+	}
+
+	other := f.comment(post, author, nil)
+	f.vote(other.ID, voter, -1)
+	f.vote(comment.ID, author, 1)
+
+	votes, err := f.s.GetUserVotes(f.ctx, voter, []int{comment.ID, missingID, other.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 0, -1}, votes)
+
+	assert.ErrorIs(t, f.s.VoteComment(f.ctx, missingID, voter, 1), storage.ErrNotFound)
+	assert.ErrorIs(t, f.s.VoteComment(f.ctx, comment.ID, voter, 2), storage.ErrValidation)
+	assert.ErrorIs(t, f.s.VoteComment(f.ctx, comment.ID, missingID, 1), storage.ErrNotFound)
+}
+
+func testVoteDeletedComment(t *testing.T, f *fixture) {
+	author, voter := f.user("author"), f.user("voter")
+	post := f.post(author, true)
+	comment := f.comment(post, author, nil)
+	f.vote(comment.ID, voter, 1)
+
+	require.NoError(t, f.s.DeleteComment(f.ctx, comment.ID))
+
+	// За удаленный комментарий нельзя ни проголосовать, ни изменить голос
+	for _, value := range []int{1, -1, 0} {
+		assert.ErrorIs(t, f.s.VoteComment(f.ctx, comment.ID, voter, value), storage.ErrNotFound)
+		assert.ErrorIs(t, f.s.VoteComment(f.ctx, comment.ID, author, value), storage.ErrNotFound)
+	}
+
+	got, err := f.s.GetComment(f.ctx, comment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Upvotes)
+	assert.Equal(t, 0, got.Downvotes)
+}
+
+func testCommentTree(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post := f.post(author, true)
+
+	r1 := f.comment(post, author, nil).ID
+	r2 := f.comment(post, author, nil).ID
+	x1 := f.comment(post, author, &r1).ID
+	y1 := f.comment(post, author, &r2).ID
+	r3 := f.comment(post, author, nil).ID
+	x2 := f.comment(post, author, &r1).ID
+	x11 := f.comment(post, author, &x1).ID
+
+	// Обход в глубину; соседи одного уровня — от старых к новым
+	tree, err := f.s.GetCommentTree(f.ctx, post, -1, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{r1, x1, x11, x2, r2, y1, r3}, treeIDs(tree))
+
+	depths := make([]int, len(tree))
+	for i, node := range tree {
+		depths[i] = node.Depth
+	}
+	assert.Equal(t, []int{0, 1, 2, 1, 0, 1, 0}, depths)
+	assert.Equal(t, []int{}, tree[0].Path)
+	assert.Equal(t, []int{r1, x1}, tree[2].Path)
+	assert.Equal(t, []int{r2}, tree[5].Path)
+
+	tree, err = f.s.GetCommentTree(f.ctx, post, 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{r1, r2, r3}, treeIDs(tree))
+
+	tree, err = f.s.GetCommentTree(f.ctx, post, 1, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{r1, x1, x2, r2, y1, r3}, treeIDs(tree))
+
+	tree, err = f.s.GetCommentTree(f.ctx, post, -1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{r1, x1, x11, x2}, treeIDs(tree))
+
+	tree, err = f.s.GetCommentTree(f.ctx, post, -1, 0)
+	require.NoError(t, err)
+	assert.Empty(t, tree)
+
+	tree, err = f.s.GetCommentTree(f.ctx, missingID, -1, -1)
+	require.NoError(t, err)
+	assert.Empty(t, tree)
+}
+
+func testCommentSubtree(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post := f.post(author, true)
+
+	r1 := f.comment(post, author, nil).ID
+	x1 := f.comment(post, author, &r1).ID
+	r2 := f.comment(post, author, nil).ID
+	x11 := f.comment(post, author, &x1).ID
+	x2 := f.comment(post, author, &r1).ID
+	f.comment(post, author, &r2)
+	x111 := f.comment(post, author, &x11).ID
+
+	subtree, err := f.s.GetCommentSubtree(f.ctx, r1, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{r1, x1, x11, x111, x2}, treeIDs(subtree))
+	assert.Equal(t, 0, subtree[0].Depth)
+	assert.Equal(t, []int{}, subtree[0].Path)
+	assert.Equal(t, 3, subtree[3].Depth)
+	assert.Equal(t, []int{r1, x1, x11}, subtree[3].Path)
+
+	// Глубина и путь считаются от корня дерева, ограничение — от запрошенного комментария
+	subtree, err = f.s.GetCommentSubtree(f.ctx, x1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{x1, x11}, treeIDs(subtree))
+	assert.Equal(t, 1, subtree[0].Depth)
+	assert.Equal(t, []int{r1}, subtree[0].Path)
+
+	subtree, err = f.s.GetCommentSubtree(f.ctx, x1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []int{x1}, treeIDs(subtree))
+
+	subtree, err = f.s.GetCommentSubtree(f.ctx, missingID, -1)
+	require.NoError(t, err)
+	assert.Empty(t, subtree)
+}
+
+// maxReplyDepth — наибольшая глубина ответа, которую допускает validation.max_comment_depth.
+const maxReplyDepth = 100
+
+// testLongReplyChain проверяет цепочку ответов наибольшей допустимой глубины:
+// в PostgreSQL путь такого комментария должен помещаться в индекс (post_id, path).
+func testLongReplyChain(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post := f.post(author, true)
+
+	chain := []int{f.comment(post, author, nil).ID}
+	for len(chain) <= maxReplyDepth {
+		chain = append(chain, f.comment(post, author, &chain[len(chain)-1]).ID)
+	}
+	deepest := chain[maxReplyDepth]
+
+	tree, err := f.s.GetCommentTree(f.ctx, post, -1, -1)
+	require.NoError(t, err)
+	require.Equal(t, chain, treeIDs(tree))
+	assert.Equal(t, maxReplyDepth, tree[maxReplyDepth].Depth)
+	assert.Equal(t, chain[:maxReplyDepth], tree[maxReplyDepth].Path)
+
+	tree, err = f.s.GetCommentTree(f.ctx, post, 10, -1)
+	require.NoError(t, err)
+	assert.Equal(t, chain[:11], treeIDs(tree))
+
+	subtree, err := f.s.GetCommentSubtree(f.ctx, chain[50], -1)
+	require.NoError(t, err)
+	assert.Equal(t, chain[50:], treeIDs(subtree))
+	assert.Equal(t, chain[:maxReplyDepth], subtree[len(subtree)-1].Path)
+
+	ancestors, err := f.s.GetAncestors(f.ctx, deepest, -1)
+	require.NoError(t, err)
+	assert.Equal(t, chain[:maxReplyDepth], commentIDs(ancestors))
+
+	ancestors, err = f.s.GetAncestors(f.ctx, deepest, 3)
+	require.NoError(t, err)
+	assert.Equal(t, chain[maxReplyDepth-3:maxReplyDepth], commentIDs(ancestors))
+}
+
+func testAncestors(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post := f.post(author, true)
+
+	root := f.comment(post, author, nil).ID
+	c1 := f.comment(post, author, &root).ID
+	c2 := f.comment(post, author, &c1).ID
+	c3 := f.comment(post, author, &c2).ID
+
+	tests := []struct {
+		name  string
+		id    int
+		limit int
+		want  []int
+	}{
+		{"all", c3, -1, []int{root, c1, c2}},
+		{"nearest", c3, 2, []int{c1, c2}},
+		{"zero limit", c3, 0, []int{}},
+		{"limit above depth", c3, 10, []int{root, c1, c2}},
+		{"root", root, -1, []int{}},
+		{"missing", missingID, -1, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ancestors, err := f.s.GetAncestors(f.ctx, tt.id, tt.limit)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, commentIDs(ancestors))
+		})
+	}
+}
+
+func testCommentContext(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post := f.post(author, true)
+
+	root := f.comment(post, author, nil).ID
+	siblings := f.comments(post, author, &root, 5)
+	target := siblings[2]
+	replies := f.comments(post, author, &target, 3)
+	f.comment(post, author, nil)
+
+	result, err := f.s.GetCommentContext(f.ctx, target, -1, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, target, result.Comment.ID)
+	assert.Equal(t, []int{root}, commentIDs(result.Ancestors))
+	assert.Equal(t, []int{siblings[1]}, commentIDs(result.SiblingsBefore))
+	assert.Equal(t, []int{siblings[3]}, commentIDs(result.SiblingsAfter))
+	assert.Equal(t, []int{replies[0], replies[1]}, commentIDs(result.Replies))
+
+	result, err = f.s.GetCommentContext(f.ctx, siblings[0], 0, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, result.Ancestors)
+	assert.Empty(t, result.SiblingsBefore)
+	assert.Equal(t, []int{siblings[1], siblings[2], siblings[3], siblings[4]}, commentIDs(result.SiblingsAfter))
+	assert.Empty(t, result.Replies)
+
+	// Соседи корневого комментария — другие корневые комментарии поста
+	result, err = f.s.GetCommentContext(f.ctx, root, -1, 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, result.Ancestors)
+	assert.Empty(t, result.SiblingsBefore)
+	assert.Empty(t, result.SiblingsAfter)
+	assert.Equal(t, siblings, commentIDs(result.Replies))
+
+	_, err = f.s.GetCommentContext(f.ctx, missingID, -1, 1, 1)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testBatchMethods(t *testing.T, f *fixture) {
+	author := f.user("author")
+	p1, p2 := f.post(author, true), f.post(author, true)
+
+	r1 := f.comments(p1, author, nil, 3)
+	r2 := f.comments(p2, author, nil, 1)
+	replies := f.comments(p1, author, &r1[0], 2)
+
+	posts, err := f.s.GetPostsByID(f.ctx, []int{p2, missingID, p1})
+	require.NoError(t, err)
+	require.Len(t, posts, 3)
+	require.NotNil(t, posts[0])
+	assert.Equal(t, p2, posts[0].ID)
+	assert.Nil(t, posts[1])
+	require.NotNil(t, posts[2])
+	assert.Equal(t, p1, posts[2].ID)
+
+	comments, err := f.s.GetCommentsByID(f.ctx, []int{replies[1], missingID, r2[0]})
+	require.NoError(t, err)
+	require.Len(t, comments, 3)
+	require.NotNil(t, comments[0])
+	assert.Equal(t, replies[1], comments[0].ID)
+	assert.Nil(t, comments[1])
+	require.NotNil(t, comments[2])
+	assert.Equal(t, r2[0], comments[2].ID)
+
+	rootPages, err := f.s.GetRootCommentPages(f.ctx, []int{p1, missingID, p2}, models.SortNewest, models.PageParams{First: 2})
+	require.NoError(t, err)
+	require.Len(t, rootPages, 3)
+	assert.Equal(t, []int{r1[2], r1[1]}, commentIDs(rootPages[0].Comments))
+	assert.True(t, rootPages[0].HasNextPage)
+	assert.Equal(t, 3, rootPages[0].TotalCount)
+	assert.Empty(t, rootPages[1].Comments)
+	assert.False(t, rootPages[1].HasNextPage)
+	assert.Equal(t, 0, rootPages[1].TotalCount)
+	assert.Equal(t, r2, commentIDs(rootPages[2].Comments))
+	assert.False(t, rootPages[2].HasNextPage)
+	assert.Equal(t, 1, rootPages[2].TotalCount)
+
+	// Пакетная страница совпадает с одиночным запросом, в том числе по курсорам
+	single, err := f.s.GetComments(f.ctx, p1, models.SortNewest, models.PageParams{First: 2})
+	require.NoError(t, err)
+	assert.Equal(t, single.Cursors, rootPages[0].Cursors)
+
+	childPages, err := f.s.GetChildCommentPages(f.ctx, []int{r1[1], missingID, r1[0]}, models.SortOldest, models.PageParams{First: 1})
+	require.NoError(t, err)
+	require.Len(t, childPages, 3)
+	assert.Empty(t, childPages[0].Comments)
+	assert.Equal(t, 0, childPages[0].TotalCount)
+	assert.Empty(t, childPages[1].Comments)
+	assert.False(t, childPages[1].HasNextPage)
+	assert.Equal(t, []int{replies[0]}, commentIDs(childPages[2].Comments))
+	assert.True(t, childPages[2].HasNextPage)
+	assert.Equal(t, 2, childPages[2].TotalCount)
+
+	// Пустой список ключей — пустой результат
+	posts, err = f.s.GetPostsByID(f.ctx, []int{})
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+	rootPages, err = f.s.GetRootCommentPages(f.ctx, []int{}, models.SortOldest, models.PageParams{First: 1})
+	require.NoError(t, err)
+	assert.Empty(t, rootPages)
+}
+
+func testStats(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post, empty := f.post(author, true), f.post(author, true)
+
+	root := f.comment(post, author, nil)
+	child := f.comment(post, author, &root.ID)
+	f.comment(post, author, &child.ID)
+	last := f.comment(post, author, &root.ID)
+
+	stats, err := f.s.GetPostStats(f.ctx, []int{post, empty, missingID})
+	require.NoError(t, err)
+	require.Len(t, stats, 3)
+	assert.Equal(t, 4, stats[0].CommentsCount)
+	require.NotNil(t, stats[0].LastCommentAt)
+	assert.True(t, stats[0].LastCommentAt.Equal(last.CreatedAt))
+	assert.Equal(t, models.PostStats{}, stats[1])
+	assert.Equal(t, models.PostStats{}, stats[2])
+
+	commentStats, err := f.s.GetCommentStats(f.ctx, []int{root.ID, child.ID, last.ID, missingID})
+	require.NoError(t, err)
+	assert.Equal(t, []models.CommentStats{
+		{RepliesCount: 2, DescendantsCount: 3},
+		{RepliesCount: 1, DescendantsCount: 1},
+		{},
+		{},
+	}, commentStats)
+}
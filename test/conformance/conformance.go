@@ -0,0 +1,156 @@
+// Package conformance — общий набор проверок для реализаций хранилища.
+// Все хранилища (PostgreSQL, SQLite, in-memory) должны одинаково сортировать,
+// разбивать на страницы и возвращать одни и те же ошибки storage.
+package conformance
+
+import (
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/service"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// missingID — ID, которого нет ни в одном тестовом хранилище.
+const missingID = 1_000_000
+
+// Storage — хранилище целиком: репозитории, на которых строятся PostService, CommentService и UserService.
+type Storage interface {
+	service.PostRepository
+	service.CommentRepository
+	service.UserRepository
+}
+
+// Factory возвращает новое пустое хранилище. Освобождать ресурсы фабрика должна сама через t.Cleanup.
+type Factory func(t *testing.T) Storage
+
+// Run проверяет все методы хранилища. Каждая проверка получает отдельное хранилище от newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, f *fixture)
+	}{
+		{"Users", testUsers},
+		{"UserPages", testUserPages},
+		{"Posts", testPosts},
+		{"PostPagination", testPostPagination},
+		{"BlockComments", testBlockComments},
+		{"DeletePost", testDeletePost},
+		{"DeletePostCounters", testDeletePostCounters},
+		{"DeletePostWithForeignReplies", testDeletePostWithForeignReplies},
+		{"CreateComment", testCreateComment},
+		{"EditAndDeleteComment", testEditAndDeleteComment},
+		{"CommentSorts", testCommentSorts},
+		{"CommentPagination", testCommentPagination},
+		{"Votes", testVotes},
+		{"VoteDeletedComment", testVoteDeletedComment},
+		{"CommentTree", testCommentTree},
+		{"CommentSubtree", testCommentSubtree},
+		{"Ancestors", testAncestors},
+		{"LongReplyChain", testLongReplyChain},
+		{"CommentContext", testCommentContext},
+		{"BatchMethods", testBatchMethods},
+		{"Stats", testStats},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, &fixture{t: t, ctx: context.Background(), s: newStorage(t)})
+		})
+	}
+}
+
+// fixture создает тестовые данные и останавливает тест при любой ошибке хранилища.
+type fixture struct {
+	t   *testing.T
+	ctx context.Context
+	s   Storage
+}
+
+func (f *fixture) user(username string) int {
+	f.t.Helper()
+
+	id, err := f.s.CreateUser(f.ctx, username, "hash", models.RoleUser)
+	require.NoError(f.t, err)
+	return id
+}
+
+func (f *fixture) post(authorID int, allowComments bool) int {
+	f.t.Helper()
+
+	id, err := f.s.CreatePost(f.ctx, authorID, "Title", "Content", allowComments)
+	require.NoError(f.t, err)
+	return id
+}
+
+// posts создает n постов автора по порядку, от старых к новым.
+func (f *fixture) posts(authorID, n int) []int {
+	f.t.Helper()
+
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = f.post(authorID, true)
+	}
+	return ids
+}
+
+func (f *fixture) comment(postID, authorID int, parentID *int) models.Comment {
+	f.t.Helper()
+
+	comment, err := f.s.CreateComment(f.ctx, postID, authorID, parentID, fmt.Sprintf("comment on post %d", postID))
+	require.NoError(f.t, err)
+	return comment
+}
+
+// comments создает n комментариев по порядку и возвращает их ID.
+func (f *fixture) comments(postID, authorID int, parentID *int, n int) []int {
+	f.t.Helper()
+
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = f.comment(postID, authorID, parentID).ID
+	}
+	return ids
+}
+
+func (f *fixture) vote(commentID, userID, value int) {
+	f.t.Helper()
+
+	require.NoError(f.t, f.s.VoteComment(f.ctx, commentID, userID, value))
+}
+
+// Функции ниже сводят результаты к ID, чтобы сравнение не зависело от nil и пустых срезов.
+
+func postIDs(posts []models.Post) []int {
+	ids := []int{}
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func commentIDs(comments []models.Comment) []int {
+	ids := []int{}
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func treeIDs(tree []models.CommentTreeNode) []int {
+	ids := []int{}
+	for _, n := range tree {
+		ids = append(ids, n.Comment.ID)
+	}
+	return ids
+}
+
+func ptr(id int) *int {
+	return &id
+}
+
+func ptrCursor(cursor models.Cursor) *models.Cursor {
+	return &cursor
+}
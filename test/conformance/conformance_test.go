@@ -0,0 +1,96 @@
+package conformance
+
+import (
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/storage/in-memory"
+	"Habr-comments-server/internal/storage/pg"
+	"Habr-comments-server/internal/storage/sqlite"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// pgDSNEnv — переменная окружения с DSN тестовой базы PostgreSQL. Без нее проверки PostgreSQL пропускаются.
+// База очищается перед каждой проверкой, поэтому указывать рабочую базу нельзя.
+const pgDSNEnv = "TEST_PG_DSN"
+
+func TestInMemory(t *testing.T) {
+	Run(t, func(t *testing.T) Storage {
+		return in_memory.NewInMemoryStorage()
+	})
+}
+
+func TestSQLite(t *testing.T) {
+	Run(t, func(t *testing.T) Storage {
+		s, err := sqlite.New(config.SQLite{Path: filepath.Join(t.TempDir(), "comments.db"), BusyTimeout: 5 * time.Second})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Stop(context.Background()) })
+		return s
+	})
+}
+
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv(pgDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", pgDSNEnv)
+	}
+
+	m, err := migrate.New("file://../../migrations", dsn)
+	require.NoError(t, err)
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		require.NoError(t, err)
+	}
+	m.Close()
+
+	cfg := pgConfig(t, dsn)
+
+	Run(t, func(t *testing.T) Storage {
+		truncate(t, dsn)
+
+		s, err := pg.New(cfg)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Stop(context.Background()) })
+		return s
+	})
+}
+
+// pgConfig переводит DSN в config.DB, которым настраивается pg.Storage.
+func pgConfig(t *testing.T, dsn string) config.DB {
+	conn, err := pgx.ParseConfig(dsn)
+	require.NoError(t, err)
+
+	return config.DB{
+		Host:               conn.Host,
+		Port:               strconv.Itoa(int(conn.Port)),
+		Username:           conn.User,
+		Password:           conn.Password,
+		Database:           conn.Database,
+		MaxConns:           4,
+		MaxConnLifetime:    time.Hour,
+		MaxConnIdleTime:    time.Minute,
+		HealthCheckPeriod:  time.Minute,
+		StatementCacheMode: "cache_statement",
+	}
+}
+
+// truncate удаляет все данные, включая тестовые из миграций, и сбрасывает последовательности ID.
+func truncate(t *testing.T, dsn string) {
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, dsn)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, `TRUNCATE comment_votes, comments, posts, users RESTART IDENTITY CASCADE;`)
+	require.NoError(t, err)
+}
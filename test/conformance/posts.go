@@ -0,0 +1,254 @@
+package conformance
+
+import (
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPosts(t *testing.T, f *fixture) {
+	author := f.user("author")
+
+	id, err := f.s.CreatePost(f.ctx, author, "Title", "Content", true)
+	require.NoError(t, err)
+
+	post, err := f.s.GetPost(f.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, post.ID)
+	assert.Equal(t, author, post.AuthorId)
+	assert.Equal(t, "Title", post.Title)
+	assert.Equal(t, "Content", post.Content)
+	assert.True(t, post.AllowComments)
+	assert.False(t, post.CreatedAt.IsZero())
+	assert.Nil(t, post.CommentsLockedReason)
+	assert.Nil(t, post.CommentsLockedAt)
+
+	_, err = f.s.CreatePost(f.ctx, missingID, "Title", "Content", true)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = f.s.GetPost(f.ctx, missingID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// nil-поля при редактировании не меняются
+	title, content := "New title", "New content"
+	require.NoError(t, f.s.UpdatePost(f.ctx, id, &title, nil))
+	post, err = f.s.GetPost(f.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "New title", post.Title)
+	assert.Equal(t, "Content", post.Content)
+
+	require.NoError(t, f.s.UpdatePost(f.ctx, id, nil, &content))
+	post, err = f.s.GetPost(f.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "New title", post.Title)
+	assert.Equal(t, "New content", post.Content)
+
+	assert.ErrorIs(t, f.s.UpdatePost(f.ctx, missingID, &title, nil), storage.ErrNotFound)
+
+	// Пост без комментариев создается сразу заблокированным
+	locked, err := f.s.CreatePost(f.ctx, author, "Locked", "Content", false)
+	require.NoError(t, err)
+	post, err = f.s.GetPost(f.ctx, locked)
+	require.NoError(t, err)
+	assert.False(t, post.AllowComments)
+	assert.NotNil(t, post.CommentsLockedAt)
+}
+
+func testPostPagination(t *testing.T, f *fixture) {
+	page, err := f.s.GetPosts(f.ctx, models.PageParams{First: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Posts)
+	assert.False(t, page.HasNextPage)
+	assert.Equal(t, 0, page.TotalCount)
+
+	ids := f.posts(f.user("author"), 5)
+
+	// Новые сверху; курсор — последний пост предыдущей страницы
+	page, err = f.s.GetPosts(f.ctx, models.PageParams{First: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{ids[4], ids[3]}, postIDs(page.Posts))
+	assert.True(t, page.HasNextPage)
+	assert.Equal(t, 5, page.TotalCount)
+
+	page, err = f.s.GetPosts(f.ctx, models.PageParams{First: 2, After: ptrCursor(page.Posts[1].Cursor())})
+	require.NoError(t, err)
+	assert.Equal(t, []int{ids[2], ids[1]}, postIDs(page.Posts))
+	assert.True(t, page.HasNextPage)
+	assert.Equal(t, 5, page.TotalCount)
+
+	third := page.Posts[0]
+	page, err = f.s.GetPosts(f.ctx, models.PageParams{First: 2, After: ptrCursor(page.Posts[1].Cursor())})
+	require.NoError(t, err)
+	assert.Equal(t, []int{ids[0]}, postIDs(page.Posts))
+	assert.False(t, page.HasNextPage)
+
+	// Курсор на последний пост дает пустую страницу
+	page, err = f.s.GetPosts(f.ctx, models.PageParams{First: 2, After: ptrCursor(page.Posts[0].Cursor())})
+	require.NoError(t, err)
+	assert.Empty(t, page.Posts)
+	assert.False(t, page.HasNextPage)
+	assert.Equal(t, 5, page.TotalCount)
+
+	// Ровно First записей — следующей страницы нет
+	page, err = f.s.GetPosts(f.ctx, models.PageParams{First: 5})
+	require.NoError(t, err)
+	assert.Len(t, page.Posts, 5)
+	assert.False(t, page.HasNextPage)
+
+	// First = 0 — только признак следующей страницы
+	page, err = f.s.GetPosts(f.ctx, models.PageParams{First: 0})
+	require.NoError(t, err)
+	assert.Empty(t, page.Posts)
+	assert.True(t, page.HasNextPage)
+
+	// Keyset-курсор остается действительным после удаления поста, на который он указывает
+	require.NoError(t, f.s.DeletePost(f.ctx, third.ID))
+	page, err = f.s.GetPosts(f.ctx, models.PageParams{First: 10, After: ptrCursor(third.Cursor())})
+	require.NoError(t, err)
+	assert.Equal(t, []int{ids[1], ids[0]}, postIDs(page.Posts))
+	assert.Equal(t, 4, page.TotalCount)
+}
+
+func testBlockComments(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post := f.post(author, true)
+
+	require.NoError(t, f.s.BlockComments(f.ctx, post, "offtopic"))
+	got, err := f.s.GetPost(f.ctx, post)
+	require.NoError(t, err)
+	assert.False(t, got.AllowComments)
+	require.NotNil(t, got.CommentsLockedReason)
+	assert.Equal(t, "offtopic", *got.CommentsLockedReason)
+	assert.NotNil(t, got.CommentsLockedAt)
+
+	_, err = f.s.CreateComment(f.ctx, post, author, nil, "text")
+	assert.ErrorIs(t, err, storage.ErrCommentsBlock)
+
+	// Пустая причина хранится как ее отсутствие
+	require.NoError(t, f.s.BlockComments(f.ctx, post, ""))
+	got, err = f.s.GetPost(f.ctx, post)
+	require.NoError(t, err)
+	assert.Nil(t, got.CommentsLockedReason)
+
+	require.NoError(t, f.s.UnblockComments(f.ctx, post))
+	got, err = f.s.GetPost(f.ctx, post)
+	require.NoError(t, err)
+	assert.True(t, got.AllowComments)
+	assert.Nil(t, got.CommentsLockedReason)
+	assert.Nil(t, got.CommentsLockedAt)
+
+	_, err = f.s.CreateComment(f.ctx, post, author, nil, "text")
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, f.s.BlockComments(f.ctx, missingID, ""), storage.ErrNotFound)
+	assert.ErrorIs(t, f.s.UnblockComments(f.ctx, missingID), storage.ErrNotFound)
+
+	locked := f.post(author, false)
+	_, err = f.s.CreateComment(f.ctx, locked, author, nil, "text")
+	assert.ErrorIs(t, err, storage.ErrCommentsBlock)
+}
+
+func testDeletePost(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post, other := f.post(author, true), f.post(author, true)
+
+	root := f.comment(post, author, nil)
+	reply := f.comment(post, author, &root.ID)
+	f.vote(reply.ID, author, 1)
+	kept := f.comment(other, author, nil)
+
+	require.NoError(t, f.s.DeletePost(f.ctx, post))
+	assert.ErrorIs(t, f.s.DeletePost(f.ctx, post), storage.ErrNotFound)
+
+	_, err := f.s.GetPost(f.ctx, post)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Комментарии и голоса удаляются вместе с постом
+	for _, id := range []int{root.ID, reply.ID} {
+		_, err = f.s.GetComment(f.ctx, id)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	}
+	votes, err := f.s.GetUserVotes(f.ctx, author, []int{reply.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []int{0}, votes)
+
+	page, err := f.s.GetComments(f.ctx, post, models.SortOldest, models.PageParams{First: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Comments)
+	assert.Equal(t, 0, page.TotalCount)
+
+	_, err = f.s.GetComment(f.ctx, kept.ID)
+	assert.NoError(t, err)
+
+	posts, err := f.s.GetPosts(f.ctx, models.PageParams{First: 10})
+	require.NoError(t, err)
+	assert.Equal(t, []int{other}, postIDs(posts.Posts))
+}
+
+// На комментарий поста отвечают из другого поста: внешний ключ parent_id — NO ACTION,
+// поэтому пост не удаляется, и ничего не меняется.
+func testDeletePostWithForeignReplies(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post, other := f.post(author, true), f.post(author, true)
+
+	root := f.comment(post, author, nil)
+	reply := f.comment(other, author, &root.ID)
+
+	err := f.s.DeletePost(f.ctx, post)
+	assert.ErrorIs(t, err, storage.ErrValidation)
+
+	_, err = f.s.GetPost(f.ctx, post)
+	assert.NoError(t, err)
+	_, err = f.s.GetComment(f.ctx, root.ID)
+	assert.NoError(t, err)
+
+	got, err := f.s.GetComment(f.ctx, reply.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.ParentId)
+	assert.Equal(t, root.ID, *got.ParentId)
+
+	stats, err := f.s.GetCommentStats(f.ctx, []int{root.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []models.CommentStats{{RepliesCount: 1, DescendantsCount: 1}}, stats)
+
+	tree, err := f.s.GetCommentTree(f.ctx, other, -1, -1)
+	require.NoError(t, err)
+	assert.Empty(t, tree, "reply is not a root of the other post")
+}
+
+// Хранилище не проверяет, что родитель ответа из того же поста (это делает validation),
+// поэтому поддерево удаляемого поста может висеть на комментарии другого поста.
+// Уцелевшие предки теряют все поддерево ровно один раз, в каком бы порядке ни удалялись строки.
+func testDeletePostCounters(t *testing.T, f *fixture) {
+	author := f.user("author")
+	post, other := f.post(author, true), f.post(author, true)
+
+	root := f.comment(other, author, nil)
+	parent := f.comment(other, author, &root.ID)
+	f.comment(other, author, &parent.ID)
+
+	top := f.comment(post, author, &parent.ID)
+	child := f.comment(post, author, &top.ID)
+	f.comment(post, author, &child.ID)
+	f.comment(post, author, &top.ID)
+
+	stats, err := f.s.GetCommentStats(f.ctx, []int{root.ID, parent.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []models.CommentStats{{RepliesCount: 1, DescendantsCount: 6}, {RepliesCount: 2, DescendantsCount: 5}}, stats)
+
+	require.NoError(t, f.s.DeletePost(f.ctx, post))
+
+	stats, err = f.s.GetCommentStats(f.ctx, []int{root.ID, parent.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []models.CommentStats{{RepliesCount: 1, DescendantsCount: 2}, {RepliesCount: 1, DescendantsCount: 1}}, stats)
+
+	postStats, err := f.s.GetPostStats(f.ctx, []int{other})
+	require.NoError(t, err)
+	assert.Equal(t, 3, postStats[0].CommentsCount)
+
+	tree, err := f.s.GetCommentTree(f.ctx, other, -1, -1)
+	require.NoError(t, err)
+	assert.Len(t, tree, 3)
+}
@@ -0,0 +1,109 @@
+package conformance
+
+import (
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUsers(t *testing.T, f *fixture) {
+	id, err := f.s.CreateUser(f.ctx, "alice", "hash", models.RoleUser)
+	require.NoError(t, err)
+
+	_, err = f.s.CreateUser(f.ctx, "alice", "other", models.RoleUser)
+	assert.ErrorIs(t, err, storage.ErrAlreadyExists)
+
+	_, err = f.s.CreateUser(f.ctx, "bob", "hash", models.Role("root"))
+	assert.ErrorIs(t, err, storage.ErrValidation)
+
+	user, err := f.s.GetUser(f.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, user.ID)
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, "hash", user.PasswordHash)
+	assert.Equal(t, models.RoleUser, user.Role)
+	assert.False(t, user.CreatedAt.IsZero())
+
+	byName, err := f.s.GetUserByUsername(f.ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, user, byName)
+
+	_, err = f.s.GetUser(f.ctx, missingID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = f.s.GetUserByUsername(f.ctx, "nobody")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.NoError(t, f.s.SetUserRole(f.ctx, id, models.RoleModerator))
+	assert.ErrorIs(t, f.s.SetUserRole(f.ctx, id, models.Role("root")), storage.ErrValidation)
+	assert.ErrorIs(t, f.s.SetUserRole(f.ctx, missingID, models.RoleAdmin), storage.ErrNotFound)
+
+	user, err = f.s.GetUser(f.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleModerator, user.Role)
+
+	users, err := f.s.GetUsersByID(f.ctx, []int{missingID, id})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	assert.Nil(t, users[0])
+	require.NotNil(t, users[1])
+	assert.Equal(t, "alice", users[1].Username)
+	assert.Equal(t, models.RoleModerator, users[1].Role)
+}
+
+func testUserPages(t *testing.T, f *fixture) {
+	alice, bob, carol := f.user("alice"), f.user("bob"), f.user("carol")
+
+	alicePosts := f.posts(alice, 3)
+	bobPost := f.post(bob, true)
+	aliceComments := f.comments(bobPost, alice, nil, 3)
+	f.comment(alicePosts[0], bob, nil)
+
+	// Посты и комментарии пользователя идут от новых к старым
+	page, err := f.s.GetUserPosts(f.ctx, alice, models.PageParams{First: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{alicePosts[2], alicePosts[1]}, postIDs(page.Posts))
+	assert.True(t, page.HasNextPage)
+	assert.Equal(t, 3, page.TotalCount)
+
+	page, err = f.s.GetUserPosts(f.ctx, alice, models.PageParams{First: 2, After: ptrCursor(page.Posts[1].Cursor())})
+	require.NoError(t, err)
+	assert.Equal(t, []int{alicePosts[0]}, postIDs(page.Posts))
+	assert.False(t, page.HasNextPage)
+
+	comments, err := f.s.GetUserComments(f.ctx, alice, models.PageParams{First: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int{aliceComments[2], aliceComments[1]}, commentIDs(comments.Comments))
+	assert.True(t, comments.HasNextPage)
+	assert.Equal(t, 3, comments.TotalCount)
+
+	comments, err = f.s.GetUserComments(f.ctx, alice, models.PageParams{First: 2, After: &comments.Cursors[1]})
+	require.NoError(t, err)
+	assert.Equal(t, []int{aliceComments[0]}, commentIDs(comments.Comments))
+	assert.False(t, comments.HasNextPage)
+
+	// Пакетные варианты: страницы в порядке ключей, для неизвестных ключей — пустые
+	postPages, err := f.s.GetUserPostPages(f.ctx, []int{bob, missingID, alice}, models.PageParams{First: 1})
+	require.NoError(t, err)
+	require.Len(t, postPages, 3)
+	assert.Equal(t, []int{bobPost}, postIDs(postPages[0].Posts))
+	assert.False(t, postPages[0].HasNextPage)
+	assert.Equal(t, 1, postPages[0].TotalCount)
+	assert.Empty(t, postPages[1].Posts)
+	assert.False(t, postPages[1].HasNextPage)
+	assert.Equal(t, 0, postPages[1].TotalCount)
+	assert.Equal(t, []int{alicePosts[2]}, postIDs(postPages[2].Posts))
+	assert.True(t, postPages[2].HasNextPage)
+	assert.Equal(t, 3, postPages[2].TotalCount)
+
+	commentPages, err := f.s.GetUserCommentPages(f.ctx, []int{carol, alice}, models.PageParams{First: 2})
+	require.NoError(t, err)
+	require.Len(t, commentPages, 2)
+	assert.Empty(t, commentPages[0].Comments)
+	assert.Equal(t, 0, commentPages[0].TotalCount)
+	assert.Equal(t, []int{aliceComments[2], aliceComments[1]}, commentIDs(commentPages[1].Comments))
+	assert.True(t, commentPages[1].HasNextPage)
+	assert.Equal(t, 3, commentPages[1].TotalCount)
+}
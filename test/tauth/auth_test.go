@@ -0,0 +1,216 @@
+package tauth
+
+import (
+	"Habr-comments-server/internal/auth"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var secret = strings.Repeat("s", auth.MinSecretLen)
+
+const hs256 = `{"alg":"HS256","typ":"JWT"}`
+
+func newTokens(t *testing.T, secret string, ttl time.Duration) *auth.Tokens {
+	t.Helper()
+
+	tokens, err := auth.NewTokens(secret, ttl)
+	require.NoError(t, err)
+	return tokens
+}
+
+func encode(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// forge собирает токен с произвольными заголовком и payload и подписывает его secret.
+func forge(secret, header, payload string) string {
+	signingInput := encode(header) + "." + encode(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func payload(sub string) string {
+	return fmt.Sprintf(`{"sub":%q,"iat":0,"exp":%d}`, sub, time.Now().Add(time.Hour).Unix())
+}
+
+func TestNewTokensWeakSecret(t *testing.T) {
+	for _, s := range []string{"", "secret", secret[1:]} {
+		_, err := auth.NewTokens(s, time.Hour)
+		assert.ErrorIs(t, err, auth.ErrWeakSecret)
+	}
+}
+
+func TestIssueAndParse(t *testing.T) {
+	tokens := newTokens(t, secret, time.Hour)
+
+	token, expiresAt, err := tokens.Issue(42)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+	userID, err := tokens.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, 42, userID)
+
+	// Токен, собранный вручную тем же способом, тоже принимается:
+	// значит, отказы в TestParseRejects вызваны именно подменой
+	userID, err = tokens.Parse(forge(secret, hs256, payload("7")))
+	require.NoError(t, err)
+	assert.Equal(t, 7, userID)
+}
+
+func TestParseRejects(t *testing.T) {
+	tokens := newTokens(t, secret, time.Hour)
+
+	token, _, err := tokens.Issue(42)
+	require.NoError(t, err)
+	parts := strings.Split(token, ".")
+
+	signature := []byte(parts[2])
+	signature[0] ^= 1
+
+	otherToken, _, err := newTokens(t, strings.Repeat("o", auth.MinSecretLen), time.Hour).Issue(42)
+	require.NoError(t, err)
+
+	expiredToken, _, err := newTokens(t, secret, -time.Minute).Issue(42)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"empty", "", auth.ErrInvalidToken},
+		{"two segments", parts[0] + "." + parts[1], auth.ErrInvalidToken},
+		{"four segments", token + ".x", auth.ErrInvalidToken},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + string(signature), auth.ErrInvalidToken},
+		{"tampered payload", parts[0] + "." + encode(payload("1")) + "." + parts[2], auth.ErrInvalidToken},
+		{"different secret", otherToken, auth.ErrInvalidToken},
+		{"expired", expiredToken, auth.ErrTokenExpired},
+		{"alg none", encode(`{"alg":"none","typ":"JWT"}`) + "." + encode(payload("1")) + ".", auth.ErrInvalidToken},
+		{"alg HS512", forge(secret, `{"alg":"HS512","typ":"JWT"}`, payload("1")), auth.ErrInvalidToken},
+		{"payload is not json", forge(secret, hs256, "not json"), auth.ErrInvalidToken},
+		{"non-numeric subject", forge(secret, hs256, payload("alice")), auth.ErrInvalidToken},
+		{"non-positive subject", forge(secret, hs256, payload("0")), auth.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokens.Parse(tt.token)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tokens := newTokens(t, secret, time.Hour)
+	token, _, err := tokens.Issue(42)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		header        string
+		status        int
+		authenticated bool
+	}{
+		{"no header", "", http.StatusOK, false},
+		{"valid token", "Bearer " + token, http.StatusOK, true},
+		{"not bearer", "Basic " + token, http.StatusUnauthorized, false},
+		{"empty token", "Bearer ", http.StatusUnauthorized, false},
+		{"invalid token", "Bearer garbage", http.StatusUnauthorized, false},
+		{"foreign token", "Bearer " + forge(strings.Repeat("o", auth.MinSecretLen), hs256, payload("42")), http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			var userID int
+			var ok bool
+			handler := auth.Middleware(tokens, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				userID, ok = auth.UserID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status != http.StatusOK {
+				// Неверный токен не понижается до анонимного доступа
+				assert.False(t, called)
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "invalid_token")
+				return
+			}
+
+			require.True(t, called)
+			assert.Equal(t, tt.authenticated, ok)
+			if tt.authenticated {
+				assert.Equal(t, 42, userID)
+			}
+		})
+	}
+}
+
+func TestWebsocketInit(t *testing.T) {
+	tokens := newTokens(t, secret, time.Hour)
+	token, _, err := tokens.Issue(42)
+	require.NoError(t, err)
+
+	expiredToken, _, err := newTokens(t, secret, -time.Minute).Issue(42)
+	require.NoError(t, err)
+
+	init := auth.WebsocketInit(tokens)
+
+	t.Run("no token", func(t *testing.T) {
+		ctx, _, err := init(context.Background(), transport.InitPayload{})
+		require.NoError(t, err)
+		_, ok := auth.UserID(ctx)
+		assert.False(t, ok)
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		for _, key := range []string{"Authorization", "authorization"} {
+			ctx, _, err := init(context.Background(), transport.InitPayload{key: "Bearer " + token})
+			require.NoError(t, err)
+			userID, ok := auth.UserID(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, 42, userID)
+		}
+	})
+
+	rejected := []struct {
+		name   string
+		header string
+		err    error
+	}{
+		{"invalid token", "Bearer garbage", auth.ErrInvalidToken},
+		{"expired token", "Bearer " + expiredToken, auth.ErrTokenExpired},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := init(context.Background(), transport.InitPayload{"Authorization": tt.header})
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	t.Run("not bearer", func(t *testing.T) {
+		_, _, err := init(context.Background(), transport.InitPayload{"Authorization": token})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,199 @@
+package tgraphql
+
+import (
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/graphql"
+	"Habr-comments-server/internal/graphql/loaders"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/pubsub"
+	"Habr-comments-server/internal/service"
+	in_memory "Habr-comments-server/internal/storage/in-memory"
+	"Habr-comments-server/internal/validation"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+// newServer поднимает GraphQL API поверх in-memory хранилища: пост с тремя комментариями.
+func newServer(t *testing.T) (*httptest.Server, int) {
+	t.Helper()
+	ctx := context.Background()
+
+	db := in_memory.NewInMemoryStorage()
+	userID, err := db.CreateUser(ctx, "alice", "hash", models.RoleUser)
+	require.NoError(t, err)
+	postID, err := db.CreatePost(ctx, userID, "title", "content", true)
+	require.NoError(t, err)
+	_, err = db.CreatePost(ctx, userID, "second", "content", true)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = db.CreateComment(ctx, postID, userID, nil, fmt.Sprintf("comment %d", i))
+		require.NoError(t, err)
+	}
+
+	svc := service.NewService(db, db, db, validation.New(config.Validation{MaxTitleLen: 200, MaxPostLen: 2000, MaxCommentLen: 2000, MaxCommentDepth: 100}, db))
+	gs := handler.New(graphql.NewExecutableSchema(graphql.Config{
+		Resolvers:  &graphql.Resolver{Service: svc, Broker: pubsub.NewBroker(1)},
+		Directives: graphql.NewDirectiveRoot(svc),
+	}))
+	gs.AddTransport(transport.POST{})
+	gs.SetErrorPresenter(graphql.NewErrorPresenter(slog.Default(), true))
+
+	srv := httptest.NewServer(loaders.Middleware(svc, gs))
+	t.Cleanup(srv.Close)
+	return srv, postID
+}
+
+func query(t *testing.T, srv *httptest.Server, q string) response {
+	t.Helper()
+	return queryAs(t, srv, "", q)
+}
+
+// queryAs выполняет запрос с токеном доступа; пустой token — анонимный запрос.
+func queryAs(t *testing.T, srv *httptest.Server, token, q string) response {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]string{"query": q})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(string(body)))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var r response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+	return r
+}
+
+// postsCursor возвращает курсор первой страницы постов.
+func postsCursor(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+
+	r := query(t, srv, `{ posts(first: 1) { pageInfo { endCursor } } }`)
+	require.Empty(t, r.Errors)
+
+	var data struct {
+		Posts struct {
+			PageInfo struct{ EndCursor string }
+		}
+	}
+	require.NoError(t, json.Unmarshal(r.Data, &data))
+	require.NotEmpty(t, data.Posts.PageInfo.EndCursor)
+	return data.Posts.PageInfo.EndCursor
+}
+
+func TestCursorPaging(t *testing.T) {
+	srv, postID := newServer(t)
+
+	r := query(t, srv, fmt.Sprintf(`{ post(id: "%d") { comments(first: 2) { edges { node { id } } pageInfo { endCursor hasNextPage } } } }`, postID))
+	require.Empty(t, r.Errors)
+	var data struct {
+		Post struct {
+			Comments struct {
+				Edges    []struct{ Node struct{ ID string } }
+				PageInfo struct {
+					EndCursor   string
+					HasNextPage bool
+				}
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(r.Data, &data))
+	require.Len(t, data.Post.Comments.Edges, 2)
+	assert.True(t, data.Post.Comments.PageInfo.HasNextPage)
+
+	r = query(t, srv, fmt.Sprintf(`{ post(id: "%d") { comments(first: 2, after: %q) { edges { node { id } } pageInfo { hasNextPage } } } }`, postID, data.Post.Comments.PageInfo.EndCursor))
+	require.Empty(t, r.Errors)
+	require.NoError(t, json.Unmarshal(r.Data, &data))
+	assert.Len(t, data.Post.Comments.Edges, 1)
+	assert.False(t, data.Post.Comments.PageInfo.HasNextPage)
+}
+
+// endCursor возвращает курсор первой страницы комментариев поста с сортировкой sort.
+func endCursor(t *testing.T, srv *httptest.Server, postID int, sort string) string {
+	t.Helper()
+
+	r := query(t, srv, fmt.Sprintf(`{ post(id: "%d") { comments(first: 1, sort: %s) { pageInfo { endCursor } } } }`, postID, sort))
+	require.Empty(t, r.Errors)
+
+	var data struct {
+		Post struct {
+			Comments struct {
+				PageInfo struct{ EndCursor string }
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(r.Data, &data))
+	require.NotEmpty(t, data.Post.Comments.PageInfo.EndCursor)
+	return data.Post.Comments.PageInfo.EndCursor
+}
+
+func TestCursorBoundToSort(t *testing.T) {
+	srv, postID := newServer(t)
+
+	top := endCursor(t, srv, postID, "TOP")
+
+	// С той же сортировкой курсор принимается
+	r := query(t, srv, fmt.Sprintf(`{ post(id: "%d") { comments(first: 5, after: %q, sort: TOP) { edges { node { id } } } } }`, postID, top))
+	assert.Empty(t, r.Errors)
+
+	// С другой сортировкой — ошибка проверки, а не страница в чужом порядке
+	for _, sort := range []string{"OLDEST", "NEWEST", "CONTROVERSIAL", "MOST_REPLIES"} {
+		r = query(t, srv, fmt.Sprintf(`{ post(id: "%d") { comments(first: 5, after: %q, sort: %s) { edges { node { id } } } } }`, postID, top, sort))
+		require.Len(t, r.Errors, 1, sort)
+		assert.Equal(t, "VALIDATION_FAILED", r.Errors[0].Extensions["code"], sort)
+	}
+
+	// Без аргумента sort используется OLDEST
+	oldest := endCursor(t, srv, postID, "OLDEST")
+	r = query(t, srv, fmt.Sprintf(`{ post(id: "%d") { comments(first: 5, after: %q) { edges { node { id } } } } }`, postID, oldest))
+	assert.Empty(t, r.Errors)
+}
+
+func TestPostCursorNotAcceptedForComments(t *testing.T) {
+	srv, postID := newServer(t)
+	cursor := postsCursor(t, srv)
+
+	r := query(t, srv, fmt.Sprintf(`{ posts(first: 1, after: %q) { edges { node { id } } } }`, cursor))
+	assert.Empty(t, r.Errors)
+
+	// Курсор постов не подходит для комментариев ни при какой сортировке, в том числе NEWEST
+	for _, sort := range []string{"OLDEST", "NEWEST", "TOP", "CONTROVERSIAL", "MOST_REPLIES"} {
+		r = query(t, srv, fmt.Sprintf(`{ post(id: "%d") { comments(after: %q, sort: %s) { totalCount } } }`, postID, cursor, sort))
+		require.Len(t, r.Errors, 1, sort)
+		assert.Equal(t, "VALIDATION_FAILED", r.Errors[0].Extensions["code"], sort)
+	}
+}
+
+func TestMalformedCursor(t *testing.T) {
+	srv, _ := newServer(t)
+
+	for _, cursor := range []string{"zzz", "MTox", "UE9TVFM6eDoxOjI"} { // мусор, "1:1", "POSTS:x:1:2"
+		r := query(t, srv, fmt.Sprintf(`{ posts(after: %q) { totalCount } }`, cursor))
+		require.Len(t, r.Errors, 1, cursor)
+		assert.Equal(t, "VALIDATION_FAILED", r.Errors[0].Extensions["code"], cursor)
+	}
+}
@@ -0,0 +1,161 @@
+package tgraphql
+
+import (
+	"Habr-comments-server/internal/auth"
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/graphql"
+	"Habr-comments-server/internal/graphql/loaders"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/pubsub"
+	"Habr-comments-server/internal/service"
+	in_memory "Habr-comments-server/internal/storage/in-memory"
+	"Habr-comments-server/internal/validation"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// authFixture — API с аутентификацией: автор поста и комментария, посторонний пользователь,
+// модератор и администратор. Токены выдаются тем же ключом, которым их проверяет сервер.
+type authFixture struct {
+	srv     *httptest.Server
+	users   map[string]int    // Имя пользователя -> ID
+	tokens  map[string]string // Имя пользователя -> токен
+	post    int
+	comment int
+}
+
+func newAuthServer(t *testing.T) *authFixture {
+	t.Helper()
+	ctx := context.Background()
+
+	db := in_memory.NewInMemoryStorage()
+	tokens, err := auth.NewTokens(strings.Repeat("k", auth.MinSecretLen), time.Hour)
+	require.NoError(t, err)
+
+	f := &authFixture{users: map[string]int{}, tokens: map[string]string{}}
+	for username, role := range map[string]models.Role{
+		"author":    models.RoleUser,
+		"stranger":  models.RoleUser,
+		"moderator": models.RoleModerator,
+		"admin":     models.RoleAdmin,
+	} {
+		f.users[username], err = db.CreateUser(ctx, username, "hash", role)
+		require.NoError(t, err)
+		f.tokens[username], _, err = tokens.Issue(f.users[username])
+		require.NoError(t, err)
+	}
+
+	// Токен пользователя, которого больше нет в хранилище
+	f.tokens["deleted"], _, err = tokens.Issue(1_000_000)
+	require.NoError(t, err)
+
+	f.post, err = db.CreatePost(ctx, f.users["author"], "title", "content", true)
+	require.NoError(t, err)
+	comment, err := db.CreateComment(ctx, f.post, f.users["author"], nil, "comment")
+	require.NoError(t, err)
+	f.comment = comment.ID
+
+	svc := service.NewService(db, db, db, validation.New(config.Validation{MaxTitleLen: 200, MaxPostLen: 2000, MaxCommentLen: 2000, MaxCommentDepth: 100}, db))
+	gs := handler.New(graphql.NewExecutableSchema(graphql.Config{
+		Resolvers:  &graphql.Resolver{Service: svc, Broker: pubsub.NewBroker(1), Tokens: tokens},
+		Directives: graphql.NewDirectiveRoot(svc),
+	}))
+	gs.AddTransport(transport.POST{})
+	gs.SetErrorPresenter(graphql.NewErrorPresenter(slog.Default(), true))
+
+	f.srv = httptest.NewServer(auth.Middleware(tokens, loaders.Middleware(svc, gs)))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// code выполняет мутацию от имени пользователя и возвращает код ошибки; пустая строка — мутация выполнена.
+func (f *authFixture) code(t *testing.T, username, mutation string) string {
+	t.Helper()
+
+	r := queryAs(t, f.srv, f.tokens[username], "mutation { "+mutation+" }")
+	if len(r.Errors) == 0 {
+		return ""
+	}
+	require.Len(t, r.Errors, 1)
+	return fmt.Sprint(r.Errors[0].Extensions["code"])
+}
+
+func TestDirectives(t *testing.T) {
+	mutations := map[string]func(f *authFixture) string{
+		"updatePost": func(f *authFixture) string {
+			return fmt.Sprintf(`updatePost(id: "%d", title: "edited") { id }`, f.post)
+		},
+		"deleteComment": func(f *authFixture) string {
+			return fmt.Sprintf(`deleteComment(id: "%d") { id }`, f.comment)
+		},
+		"blockComments": func(f *authFixture) string {
+			return fmt.Sprintf(`blockComments(postId: "%d", reason: "flame") { id }`, f.post)
+		},
+		"createPost": func(f *authFixture) string {
+			return `createPost(title: "title", content: "content", allowComments: true) { id }`
+		},
+		"setUserRole": func(f *authFixture) string {
+			return fmt.Sprintf(`setUserRole(userId: "%d", role: MODERATOR) { id }`, f.users["stranger"])
+		},
+	}
+
+	tests := []struct {
+		mutation string
+		user     string // Пустое имя — анонимный запрос
+		code     string
+	}{
+		// Без токена ни одна мутация не выполняется
+		{"updatePost", "", "UNAUTHENTICATED"},
+		{"deleteComment", "", "UNAUTHENTICATED"},
+		{"blockComments", "", "UNAUTHENTICATED"},
+		{"createPost", "", "UNAUTHENTICATED"},
+		{"setUserRole", "", "UNAUTHENTICATED"},
+
+		// Токен удаленного пользователя равносилен отсутствию входа
+		{"updatePost", "deleted", "UNAUTHENTICATED"},
+		{"createPost", "deleted", "UNAUTHENTICATED"},
+
+		// @isOwner: чужой ресурс недоступен обычному пользователю
+		{"updatePost", "stranger", "FORBIDDEN"},
+		{"deleteComment", "stranger", "FORBIDDEN"},
+		{"blockComments", "stranger", "FORBIDDEN"},
+
+		// ... но доступен автору, модератору и администратору
+		{"updatePost", "author", ""},
+		{"deleteComment", "author", ""},
+		{"blockComments", "author", ""},
+		{"updatePost", "moderator", ""},
+		{"deleteComment", "moderator", ""},
+		{"blockComments", "moderator", ""},
+		{"blockComments", "admin", ""},
+
+		// @hasRole(role: USER) пропускает любого вошедшего пользователя
+		{"createPost", "stranger", ""},
+
+		// @hasRole(role: ADMIN): назначать роли может только администратор
+		{"setUserRole", "author", "FORBIDDEN"},
+		{"setUserRole", "moderator", "FORBIDDEN"},
+		{"setUserRole", "admin", ""},
+	}
+
+	for _, tt := range tests {
+		name := tt.mutation + "/" + tt.user
+		if tt.user == "" {
+			name = tt.mutation + "/anonymous"
+		}
+		t.Run(name, func(t *testing.T) {
+			f := newAuthServer(t)
+			assert.Equal(t, tt.code, f.code(t, tt.user, mutations[tt.mutation](f)))
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package tgraphql
+
+import (
+	"Habr-comments-server/internal/auth"
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/graphql"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	"Habr-comments-server/internal/storage/sqlite"
+	"Habr-comments-server/internal/validation"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func present(t *testing.T, err error, hideInternal bool) (*gqlerror.Error, string) {
+	t.Helper()
+
+	var logs bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&logs, nil))
+	return graphql.NewErrorPresenter(log, hideInternal)(context.Background(), err), logs.String()
+}
+
+func TestErrorCodes(t *testing.T) {
+	_, numErr := strconv.Atoi("abc")
+
+	tests := []struct {
+		name    string
+		err     error
+		code    string
+		message string // сообщение в prod
+	}{
+		{"not found", storage.ErrNotFound, "NOT_FOUND", "not found"},
+		{"already exists", storage.ErrAlreadyExists, "ALREADY_EXISTS", "already exists"},
+		{"comments locked", storage.ErrCommentsBlock, "COMMENTS_LOCKED", "comments are locked for this post"},
+		{"validation", storage.ErrValidation, "VALIDATION_FAILED", "invalid input"},
+		{"unauthenticated", auth.ErrUnauthenticated, "UNAUTHENTICATED", "authentication required"},
+		{"forbidden", auth.ErrForbidden, "FORBIDDEN", "forbidden"},
+		{"wrapped", fmt.Errorf("service.GetPost: %w", fmt.Errorf("storage.db.GetPost: %w", storage.ErrNotFound)), "NOT_FOUND", "not found"},
+		{"invalid id", fmt.Errorf("failed to parse id: %w", numErr), "VALIDATION_FAILED", "invalid ID"},
+		{"internal", errors.New("storage.db.GetPost: connection refused"), "INTERNAL_SERVER_ERROR", "internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gqlErr, _ := present(t, tt.err, false)
+			assert.Equal(t, tt.code, gqlErr.Extensions["code"])
+			assert.Equal(t, tt.err.Error(), gqlErr.Message)
+
+			gqlErr, _ = present(t, tt.err, true)
+			assert.Equal(t, tt.code, gqlErr.Extensions["code"])
+			assert.Equal(t, tt.message, gqlErr.Message)
+		})
+	}
+}
+
+func TestInternalErrorHiddenInProd(t *testing.T) {
+	err := errors.New(`storage.db.GetPost: ERROR: relation "posts" does not exist`)
+
+	gqlErr, logs := present(t, err, true)
+	assert.Equal(t, "internal server error", gqlErr.Message)
+	assert.NotContains(t, fmt.Sprint(gqlErr.Extensions), "posts")
+	// Подробности остаются в логе
+	assert.Contains(t, logs, "relation")
+
+	gqlErr, logs = present(t, err, false)
+	assert.Equal(t, err.Error(), gqlErr.Message)
+	assert.Contains(t, logs, "relation")
+}
+
+func TestValidationErrorFields(t *testing.T) {
+	v := validation.New(config.Validation{MaxTitleLen: 5, MaxPostLen: 10, MaxCommentLen: 10}, nil)
+	err := v.Post("", strings.Repeat("x", 11))
+	require.ErrorIs(t, err, storage.ErrValidation)
+
+	// Сообщения проверки — клиентские, поэтому видны и в prod
+	gqlErr, _ := present(t, err, true)
+	assert.Equal(t, "VALIDATION_FAILED", gqlErr.Extensions["code"])
+	assert.Equal(t, err.Error(), gqlErr.Message)
+
+	var validationErr *validation.Error
+	require.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, validationErr.Fields, gqlErr.Extensions["fields"])
+	assert.Len(t, validationErr.Fields, 2)
+}
+
+func TestExistingCodeKept(t *testing.T) {
+	err := &gqlerror.Error{Message: "too many requests", Extensions: map[string]interface{}{"code": "RATE_LIMITED", "retryAfter": 5}}
+
+	gqlErr, _ := present(t, err, true)
+	assert.Equal(t, "RATE_LIMITED", gqlErr.Extensions["code"])
+	assert.Equal(t, 5, gqlErr.Extensions["retryAfter"])
+	assert.Equal(t, "too many requests", gqlErr.Message)
+}
+
+// TestStorageErrorCodes проверяет коды для настоящих ошибок хранилища:
+// ограничения БД (FK, CHECK, UNIQUE) и отсутствие строк переводятся в ошибки-сигналы в mapError.
+func TestStorageErrorCodes(t *testing.T) {
+	ctx := context.Background()
+	s, err := sqlite.New(config.SQLite{Path: filepath.Join(t.TempDir(), "comments.db"), BusyTimeout: 5 * time.Second})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop(ctx) })
+
+	userID, err := s.CreateUser(ctx, "alice", "hash", models.RoleUser)
+	require.NoError(t, err)
+	postID, err := s.CreatePost(ctx, userID, "title", "content", true)
+	require.NoError(t, err)
+	comment, err := s.CreateComment(ctx, postID, userID, nil, "comment")
+	require.NoError(t, err)
+	lockedPostID, err := s.CreatePost(ctx, userID, "locked", "content", true)
+	require.NoError(t, err)
+	require.NoError(t, s.BlockComments(ctx, lockedPostID, "flame"))
+
+	tests := []struct {
+		name string
+		call func() error
+		code string
+	}{
+		{"no rows", func() error {
+			_, err := s.GetPost(ctx, 1_000_000)
+			return err
+		}, "NOT_FOUND"},
+		{"foreign key", func() error {
+			_, err := s.CreatePost(ctx, 1_000_000, "title", "content", true)
+			return err
+		}, "NOT_FOUND"},
+		{"unique", func() error {
+			_, err := s.CreateUser(ctx, "alice", "hash", models.RoleUser)
+			return err
+		}, "ALREADY_EXISTS"},
+		{"check on content", func() error {
+			_, err := s.CreateComment(ctx, postID, userID, nil, strings.Repeat("x", 2001))
+			return err
+		}, "VALIDATION_FAILED"},
+		{"check on vote", func() error {
+			return s.VoteComment(ctx, comment.ID, userID, 5)
+		}, "VALIDATION_FAILED"},
+		{"comments locked", func() error {
+			_, err := s.CreateComment(ctx, lockedPostID, userID, nil, "comment")
+			return err
+		}, "COMMENTS_LOCKED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			require.Error(t, err)
+
+			gqlErr, logs := present(t, err, true)
+			assert.Equal(t, tt.code, gqlErr.Extensions["code"])
+			// Текст ошибки БД не попадает ни в ответ, ни в лог: это не внутренняя ошибка
+			assert.NotContains(t, gqlErr.Message, "storage.sqlite")
+			assert.Empty(t, logs)
+		})
+	}
+}
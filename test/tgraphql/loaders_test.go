@@ -0,0 +1,430 @@
+package tgraphql
+
+import (
+	"Habr-comments-server/internal/auth"
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/graphql"
+	"Habr-comments-server/internal/graphql/loaders"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/pubsub"
+	"Habr-comments-server/internal/service"
+	in_memory "Habr-comments-server/internal/storage/in-memory"
+	"Habr-comments-server/internal/validation"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStorage считает вызовы методов хранилища. Хранилище не встроено, а лежит в поле:
+// так компилятор не даст пропустить метод репозитория, и ни один вызов не пройдет мимо счетчика.
+type countingStorage struct {
+	db *in_memory.InMemoryStorage
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func newCountingStorage(db *in_memory.InMemoryStorage) *countingStorage {
+	return &countingStorage{db: db, calls: map[string]int{}}
+}
+
+func (c *countingStorage) count(method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[method]++
+}
+
+// snapshot возвращает счетчики и обнуляет их.
+func (c *countingStorage) snapshot() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	calls := c.calls
+	c.calls = map[string]int{}
+	return calls
+}
+
+var (
+	_ service.PostRepository    = (*countingStorage)(nil)
+	_ service.CommentRepository = (*countingStorage)(nil)
+	_ service.UserRepository    = (*countingStorage)(nil)
+)
+
+func (c *countingStorage) GetPost(ctx context.Context, id int) (models.Post, error) {
+	c.count("GetPost")
+	return c.db.GetPost(ctx, id)
+}
+
+func (c *countingStorage) GetPosts(ctx context.Context, page models.PageParams) (models.PostPage, error) {
+	c.count("GetPosts")
+	return c.db.GetPosts(ctx, page)
+}
+
+func (c *countingStorage) CreatePost(ctx context.Context, authorId int, title, content string, allowComments bool) (int, error) {
+	c.count("CreatePost")
+	return c.db.CreatePost(ctx, authorId, title, content, allowComments)
+}
+
+func (c *countingStorage) UpdatePost(ctx context.Context, id int, title, content *string) error {
+	c.count("UpdatePost")
+	return c.db.UpdatePost(ctx, id, title, content)
+}
+
+func (c *countingStorage) DeletePost(ctx context.Context, id int) error {
+	c.count("DeletePost")
+	return c.db.DeletePost(ctx, id)
+}
+
+func (c *countingStorage) BlockComments(ctx context.Context, id int, reason string) error {
+	c.count("BlockComments")
+	return c.db.BlockComments(ctx, id, reason)
+}
+
+func (c *countingStorage) UnblockComments(ctx context.Context, id int) error {
+	c.count("UnblockComments")
+	return c.db.UnblockComments(ctx, id)
+}
+
+func (c *countingStorage) GetPostsByID(ctx context.Context, ids []int) ([]*models.Post, error) {
+	c.count("GetPostsByID")
+	return c.db.GetPostsByID(ctx, ids)
+}
+
+func (c *countingStorage) GetPostStats(ctx context.Context, ids []int) ([]models.PostStats, error) {
+	c.count("GetPostStats")
+	return c.db.GetPostStats(ctx, ids)
+}
+
+func (c *countingStorage) GetComment(ctx context.Context, id int) (models.Comment, error) {
+	c.count("GetComment")
+	return c.db.GetComment(ctx, id)
+}
+
+func (c *countingStorage) GetComments(ctx context.Context, postID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	c.count("GetComments")
+	return c.db.GetComments(ctx, postID, sort, page)
+}
+
+func (c *countingStorage) GetChildComments(ctx context.Context, parentID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	c.count("GetChildComments")
+	return c.db.GetChildComments(ctx, parentID, sort, page)
+}
+
+func (c *countingStorage) CreateComment(ctx context.Context, postID, authorID int, parentID *int, content string) (models.Comment, error) {
+	c.count("CreateComment")
+	return c.db.CreateComment(ctx, postID, authorID, parentID, content)
+}
+
+func (c *countingStorage) UpdateComment(ctx context.Context, id int, content string) error {
+	c.count("UpdateComment")
+	return c.db.UpdateComment(ctx, id, content)
+}
+
+func (c *countingStorage) DeleteComment(ctx context.Context, id int) error {
+	c.count("DeleteComment")
+	return c.db.DeleteComment(ctx, id)
+}
+
+func (c *countingStorage) VoteComment(ctx context.Context, commentID, userID, value int) error {
+	c.count("VoteComment")
+	return c.db.VoteComment(ctx, commentID, userID, value)
+}
+
+func (c *countingStorage) GetUserVotes(ctx context.Context, userID int, commentIDs []int) ([]int, error) {
+	c.count("GetUserVotes")
+	return c.db.GetUserVotes(ctx, userID, commentIDs)
+}
+
+func (c *countingStorage) GetCommentTree(ctx context.Context, postID, maxDepth, rootLimit int) ([]models.CommentTreeNode, error) {
+	c.count("GetCommentTree")
+	return c.db.GetCommentTree(ctx, postID, maxDepth, rootLimit)
+}
+
+func (c *countingStorage) GetCommentSubtree(ctx context.Context, id, maxDepth int) ([]models.CommentTreeNode, error) {
+	c.count("GetCommentSubtree")
+	return c.db.GetCommentSubtree(ctx, id, maxDepth)
+}
+
+func (c *countingStorage) GetAncestors(ctx context.Context, id, limit int) ([]models.Comment, error) {
+	c.count("GetAncestors")
+	return c.db.GetAncestors(ctx, id, limit)
+}
+
+func (c *countingStorage) GetCommentContext(ctx context.Context, id, parentsAbove, siblings, repliesBelow int) (models.CommentContext, error) {
+	c.count("GetCommentContext")
+	return c.db.GetCommentContext(ctx, id, parentsAbove, siblings, repliesBelow)
+}
+
+func (c *countingStorage) GetCommentsByID(ctx context.Context, ids []int) ([]*models.Comment, error) {
+	c.count("GetCommentsByID")
+	return c.db.GetCommentsByID(ctx, ids)
+}
+
+func (c *countingStorage) GetCommentStats(ctx context.Context, ids []int) ([]models.CommentStats, error) {
+	c.count("GetCommentStats")
+	return c.db.GetCommentStats(ctx, ids)
+}
+
+func (c *countingStorage) GetRootCommentPages(ctx context.Context, postIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	c.count("GetRootCommentPages")
+	return c.db.GetRootCommentPages(ctx, postIDs, sort, page)
+}
+
+func (c *countingStorage) GetChildCommentPages(ctx context.Context, parentIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	c.count("GetChildCommentPages")
+	return c.db.GetChildCommentPages(ctx, parentIDs, sort, page)
+}
+
+func (c *countingStorage) CreateUser(ctx context.Context, username, passwordHash string, role models.Role) (int, error) {
+	c.count("CreateUser")
+	return c.db.CreateUser(ctx, username, passwordHash, role)
+}
+
+func (c *countingStorage) GetUser(ctx context.Context, id int) (models.User, error) {
+	c.count("GetUser")
+	return c.db.GetUser(ctx, id)
+}
+
+func (c *countingStorage) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	c.count("GetUserByUsername")
+	return c.db.GetUserByUsername(ctx, username)
+}
+
+func (c *countingStorage) SetUserRole(ctx context.Context, id int, role models.Role) error {
+	c.count("SetUserRole")
+	return c.db.SetUserRole(ctx, id, role)
+}
+
+func (c *countingStorage) GetUsersByID(ctx context.Context, ids []int) ([]*models.User, error) {
+	c.count("GetUsersByID")
+	return c.db.GetUsersByID(ctx, ids)
+}
+
+func (c *countingStorage) GetUserPosts(ctx context.Context, userID int, page models.PageParams) (models.PostPage, error) {
+	c.count("GetUserPosts")
+	return c.db.GetUserPosts(ctx, userID, page)
+}
+
+func (c *countingStorage) GetUserComments(ctx context.Context, userID int, page models.PageParams) (models.CommentPage, error) {
+	c.count("GetUserComments")
+	return c.db.GetUserComments(ctx, userID, page)
+}
+
+func (c *countingStorage) GetUserPostPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.PostPage, error) {
+	c.count("GetUserPostPages")
+	return c.db.GetUserPostPages(ctx, userIDs, page)
+}
+
+func (c *countingStorage) GetUserCommentPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.CommentPage, error) {
+	c.count("GetUserCommentPages")
+	return c.db.GetUserCommentPages(ctx, userIDs, page)
+}
+
+// loaderFixture — API поверх хранилища со счетчиком вызовов: posts постов от трех авторов,
+// у каждого поста два корневых комментария с двумя ответами.
+type loaderFixture struct {
+	srv     *httptest.Server
+	storage *countingStorage
+	token   string // Токен модератора: он может редактировать любые комментарии
+}
+
+func newLoaderServer(t *testing.T, posts int) *loaderFixture {
+	t.Helper()
+	ctx := context.Background()
+
+	db := in_memory.NewInMemoryStorage()
+	tokens, err := auth.NewTokens(strings.Repeat("k", auth.MinSecretLen), time.Hour)
+	require.NoError(t, err)
+
+	authors := make([]int, 3)
+	for i := range authors {
+		authors[i], err = db.CreateUser(ctx, fmt.Sprintf("user%d", i), "hash", models.RoleUser)
+		require.NoError(t, err)
+	}
+	for i := 0; i < posts; i++ {
+		postID, err := db.CreatePost(ctx, authors[i%len(authors)], fmt.Sprintf("post %d", i), "content", true)
+		require.NoError(t, err)
+		for j := 0; j < 2; j++ {
+			root, err := db.CreateComment(ctx, postID, authors[0], nil, "root")
+			require.NoError(t, err)
+			for k := 0; k < 2; k++ {
+				_, err = db.CreateComment(ctx, postID, authors[(j+k+1)%len(authors)], &root.ID, "reply")
+				require.NoError(t, err)
+			}
+		}
+	}
+
+	moderator, err := db.CreateUser(ctx, "moderator", "hash", models.RoleModerator)
+	require.NoError(t, err)
+
+	f := &loaderFixture{storage: newCountingStorage(db)}
+	f.token, _, err = tokens.Issue(moderator)
+	require.NoError(t, err)
+
+	svc := service.NewService(f.storage, f.storage, f.storage, validation.New(config.Validation{MaxTitleLen: 200, MaxPostLen: 2000, MaxCommentLen: 2000, MaxCommentDepth: 100}, f.storage))
+	gs := handler.New(graphql.NewExecutableSchema(graphql.Config{
+		Resolvers:  &graphql.Resolver{Service: svc, Broker: pubsub.NewBroker(1), Tokens: tokens},
+		Directives: graphql.NewDirectiveRoot(svc),
+	}))
+	gs.AddTransport(transport.POST{})
+	gs.SetErrorPresenter(graphql.NewErrorPresenter(slog.Default(), true))
+
+	f.srv = httptest.NewServer(auth.Middleware(tokens, loaders.Middleware(svc, gs)))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+const nestedPostsQuery = `{ posts(first: 50) { edges { node { id commentsCount author { username }
+	comments(first: 10) { edges { node { id repliesCount author { username } parent { id }
+		children(first: 10) { edges { node { id repliesCount author { username } parent { id content } } } } } } } } } } }`
+
+// Число обращений к хранилищу за вложенный запрос не зависит от числа постов и комментариев:
+// каждое поле на каждом уровне загружается пакетом, а не по одной записи.
+func TestNestedQueryBatched(t *testing.T) {
+	run := func(posts int) map[string]int {
+		f := newLoaderServer(t, posts)
+
+		r := query(t, f.srv, nestedPostsQuery)
+		require.Empty(t, r.Errors)
+
+		var data struct {
+			Posts struct {
+				Edges []struct {
+					Node struct {
+						Comments struct {
+							Edges []struct {
+								Node struct {
+									Children struct {
+										Edges []json.RawMessage `json:"edges"`
+									} `json:"children"`
+								} `json:"node"`
+							} `json:"edges"`
+						} `json:"comments"`
+					} `json:"node"`
+				} `json:"edges"`
+			} `json:"posts"`
+		}
+		require.NoError(t, json.Unmarshal(r.Data, &data))
+		require.Len(t, data.Posts.Edges, posts)
+		for _, post := range data.Posts.Edges {
+			require.Len(t, post.Node.Comments.Edges, 2)
+			for _, comment := range post.Node.Comments.Edges {
+				require.Len(t, comment.Node.Children.Edges, 2)
+			}
+		}
+
+		return f.storage.snapshot()
+	}
+
+	// Без пакетной загрузки второй прогон сделал бы больше сотни обращений
+	for _, posts := range []int{2, 8} {
+		calls := run(posts)
+
+		// Поштучные чтения означали бы N+1
+		for _, method := range []string{"GetPost", "GetComment", "GetUser", "GetComments", "GetChildComments", "GetUserPosts", "GetUserComments"} {
+			assert.Zero(t, calls[method], "%s: %v", method, calls)
+		}
+
+		// По пакету на поле на каждом уровне: посты, корневые комментарии и ответы со своими
+		// авторами, счетчиками и родителями — около восьми обращений. Запас на случай, когда
+		// ключи одного уровня не успели в окно ожидания лоадера и ушли вторым пакетом.
+		total := 0
+		for _, n := range calls {
+			total += n
+		}
+		assert.LessOrEqual(t, total, 12, calls)
+		assert.Equal(t, 1, calls["GetPosts"], calls)
+	}
+}
+
+// Мутация сбрасывает кеш лоадеров: поля, прочитанные в том же запросе после нее, свежие.
+func TestMutationThenReadSameRequest(t *testing.T) {
+	f := newLoaderServer(t, 1)
+
+	var page struct {
+		Posts struct {
+			Edges []struct {
+				Node struct {
+					ID       string `json:"id"`
+					Comments struct {
+						Edges []struct {
+							Node struct {
+								ID       string `json:"id"`
+								Children struct {
+									Edges []struct {
+										Node struct {
+											ID string `json:"id"`
+										} `json:"node"`
+									} `json:"edges"`
+								} `json:"children"`
+							} `json:"node"`
+						} `json:"edges"`
+					} `json:"comments"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"posts"`
+	}
+	r := query(t, f.srv, `{ posts { edges { node { id comments { edges { node { id children { edges { node { id } } } } } } } } } }`)
+	require.Empty(t, r.Errors)
+	require.NoError(t, json.Unmarshal(r.Data, &page))
+	post := page.Posts.Edges[0].Node
+	root := post.Comments.Edges[0].Node
+	reply := root.Children.Edges[0].Node.ID
+
+	// Первая мутация загружает в кеш родителя, его ответы и счетчики, вторая их меняет,
+	// третья читает заново. Мутации выполняются по очереди.
+	r = queryAs(t, f.srv, f.token, fmt.Sprintf(`mutation {
+		before: updateComment(id: %[1]q, content: "root") { id content repliesCount children { totalCount } }
+		create: createComment(postId: %[2]q, parentId: %[1]q, content: "new reply") { id }
+		edit: updateComment(id: %[3]q, content: "edited") { id }
+		after: updateComment(id: %[1]q, content: "root") { repliesCount children { totalCount edges { node { id content } } } }
+	}`, root.ID, post.ID, reply))
+	require.Empty(t, r.Errors)
+
+	var data struct {
+		Before struct {
+			RepliesCount int `json:"repliesCount"`
+			Children     struct {
+				TotalCount int `json:"totalCount"`
+			} `json:"children"`
+		} `json:"before"`
+		Create struct {
+			ID string `json:"id"`
+		} `json:"create"`
+		After struct {
+			RepliesCount int `json:"repliesCount"`
+			Children     struct {
+				TotalCount int `json:"totalCount"`
+				Edges      []struct {
+					Node struct {
+						ID      string `json:"id"`
+						Content string `json:"content"`
+					} `json:"node"`
+				} `json:"edges"`
+			} `json:"children"`
+		} `json:"after"`
+	}
+	require.NoError(t, json.Unmarshal(r.Data, &data))
+
+	assert.Equal(t, 2, data.Before.RepliesCount)
+	assert.Equal(t, 2, data.Before.Children.TotalCount)
+
+	assert.Equal(t, 3, data.After.RepliesCount)
+	assert.Equal(t, 3, data.After.Children.TotalCount)
+	contents := map[string]string{}
+	for _, edge := range data.After.Children.Edges {
+		contents[edge.Node.ID] = edge.Node.Content
+	}
+	assert.Equal(t, "new reply", contents[data.Create.ID])
+	assert.Equal(t, "edited", contents[reply])
+}
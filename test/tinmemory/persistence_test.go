@@ -0,0 +1,329 @@
+package tinmemory
+
+import (
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	in_memory "Habr-comments-server/internal/storage/in-memory"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Имена файлов повторяют формат хранилища: wal-<номер из 16 цифр>.log и snapshot.json.
+const (
+	snapshotFile = "snapshot.json"
+	firstSegment = "wal-0000000000000000.log"
+	nextSegment  = "wal-0000000000000001.log"
+)
+
+var ctx = context.Background()
+
+// open открывает долговременное хранилище в dir без снимков по таймеру и останавливает его в конце теста.
+func open(t *testing.T, dir, fsync string) *in_memory.InMemoryStorage {
+	t.Helper()
+
+	s, err := in_memory.Open(config.InMemory{DataDir: dir, Fsync: fsync, FsyncInterval: 10 * time.Millisecond}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Stop(ctx) })
+	return s
+}
+
+// crash копирует файлы хранилища в новый каталог — так выглядит диск после падения процесса:
+// без последнего снимка при остановке и без закрытия журнала.
+func crash(t *testing.T, dir string) string {
+	t.Helper()
+
+	copyDir := t.TempDir()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(copyDir, entry.Name()), data, 0o644))
+	}
+	return copyDir
+}
+
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// history — ID записей, созданных write
+type history struct {
+	alice, bob    int
+	post, deleted int
+	root, reply   int
+}
+
+// write выполняет по одной операции каждого вида, которые пишутся в журнал.
+func write(t *testing.T, s *in_memory.InMemoryStorage) history {
+	t.Helper()
+
+	var h history
+	var err error
+
+	h.alice, err = s.CreateUser(ctx, "alice", "hash", models.RoleUser)
+	require.NoError(t, err)
+	h.bob, err = s.CreateUser(ctx, "bob", "hash", models.RoleUser)
+	require.NoError(t, err)
+	require.NoError(t, s.SetUserRole(ctx, h.bob, models.RoleModerator))
+
+	h.post, err = s.CreatePost(ctx, h.alice, "Title", "Content", true)
+	require.NoError(t, err)
+	h.deleted, err = s.CreatePost(ctx, h.alice, "Deleted", "Content", true)
+	require.NoError(t, err)
+	title := "New title"
+	require.NoError(t, s.UpdatePost(ctx, h.post, &title, nil))
+	require.NoError(t, s.BlockComments(ctx, h.deleted, "spam"))
+	require.NoError(t, s.DeletePost(ctx, h.deleted))
+
+	root, err := s.CreateComment(ctx, h.post, h.alice, nil, "root")
+	require.NoError(t, err)
+	reply, err := s.CreateComment(ctx, h.post, h.bob, &root.ID, "reply")
+	require.NoError(t, err)
+	h.root, h.reply = root.ID, reply.ID
+
+	require.NoError(t, s.UpdateComment(ctx, h.reply, "edited"))
+	require.NoError(t, s.VoteComment(ctx, h.root, h.bob, 1))
+	require.NoError(t, s.VoteComment(ctx, h.root, h.alice, -1))
+	require.NoError(t, s.DeleteComment(ctx, h.root))
+
+	return h
+}
+
+// check проверяет, что восстановленное хранилище совпадает с состоянием после write.
+func check(t *testing.T, s *in_memory.InMemoryStorage, h history) {
+	t.Helper()
+
+	bob, err := s.GetUser(ctx, h.bob)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleModerator, bob.Role)
+	alice, err := s.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", alice.PasswordHash)
+
+	post, err := s.GetPost(ctx, h.post)
+	require.NoError(t, err)
+	assert.Equal(t, "New title", post.Title)
+	_, err = s.GetPost(ctx, h.deleted)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	root, err := s.GetComment(ctx, h.root)
+	require.NoError(t, err)
+	assert.True(t, root.IsDeleted)
+	assert.Equal(t, 1, root.Upvotes)
+	assert.Equal(t, 1, root.Downvotes)
+
+	reply, err := s.GetComment(ctx, h.reply)
+	require.NoError(t, err)
+	assert.Equal(t, "edited", reply.Content)
+	assert.NotNil(t, reply.EditedAt)
+
+	votes, err := s.GetUserVotes(ctx, h.bob, []int{h.root})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, votes)
+
+	// Индексы и счетчики строятся заново при восстановлении
+	stats, err := s.GetCommentStats(ctx, []int{h.root})
+	require.NoError(t, err)
+	assert.Equal(t, []models.CommentStats{{RepliesCount: 1, DescendantsCount: 1}}, stats)
+
+	tree, err := s.GetCommentTree(ctx, h.post, -1, 1)
+	require.NoError(t, err)
+	require.Len(t, tree, 2)
+	assert.Equal(t, h.root, tree[0].Comment.ID)
+	assert.Equal(t, h.reply, tree[1].Comment.ID)
+}
+
+// checkSequences проверяет, что ID не переиспользуются, в том числе ID удаленного поста.
+func checkSequences(t *testing.T, s *in_memory.InMemoryStorage, h history) {
+	t.Helper()
+
+	user, err := s.CreateUser(ctx, "carol", "hash", models.RoleUser)
+	require.NoError(t, err)
+	assert.Equal(t, h.bob+1, user)
+
+	post, err := s.CreatePost(ctx, h.alice, "Title", "Content", true)
+	require.NoError(t, err)
+	assert.Equal(t, h.deleted+1, post)
+
+	comment, err := s.CreateComment(ctx, h.post, h.alice, nil, "text")
+	require.NoError(t, err)
+	assert.Equal(t, h.reply+1, comment.ID)
+}
+
+func TestRecoverFromLog(t *testing.T) {
+	dir := t.TempDir()
+	h := write(t, open(t, dir, in_memory.FsyncAlways))
+
+	// До первого снимка все изменения только в журнале
+	crashed := crash(t, dir)
+	assert.Equal(t, []string{firstSegment}, files(t, crashed))
+
+	s := open(t, crashed, in_memory.FsyncAlways)
+	check(t, s, h)
+	checkSequences(t, s, h)
+}
+
+func TestRecoverFromSnapshotAndLog(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, in_memory.FsyncAlways)
+	h := write(t, s)
+
+	// Снимок переключает журнал на новый сегмент и удаляет старый
+	require.NoError(t, s.Snapshot())
+	assert.Equal(t, []string{snapshotFile, nextSegment}, files(t, dir))
+
+	// Без изменений повторный снимок ничего не делает
+	require.NoError(t, s.Snapshot())
+	assert.Equal(t, []string{snapshotFile, nextSegment}, files(t, dir))
+
+	// Изменения после снимка попадают только в журнал
+	extra, err := s.CreatePost(ctx, h.alice, "After snapshot", "Content", true)
+	require.NoError(t, err)
+
+	recovered := open(t, crash(t, dir), in_memory.FsyncAlways)
+	check(t, recovered, h)
+	post, err := recovered.GetPost(ctx, extra)
+	require.NoError(t, err)
+	assert.Equal(t, "After snapshot", post.Title)
+
+	comment, err := recovered.CreateComment(ctx, h.post, h.alice, nil, "text")
+	require.NoError(t, err)
+	assert.Equal(t, h.reply+1, comment.ID)
+}
+
+func TestRecoverAfterStop(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, in_memory.FsyncAlways)
+	h := write(t, s)
+
+	// Остановка оставляет снимок и пустой новый сегмент
+	require.NoError(t, s.Stop(ctx))
+	assert.Equal(t, []string{snapshotFile, nextSegment}, files(t, dir))
+	info, err := os.Stat(filepath.Join(dir, nextSegment))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	recovered := open(t, dir, in_memory.FsyncAlways)
+	check(t, recovered, h)
+	checkSequences(t, recovered, h)
+}
+
+func TestTornLastRecord(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, in_memory.FsyncAlways)
+	h := write(t, s)
+
+	lost, err := s.CreateComment(ctx, h.post, h.alice, nil, "lost")
+	require.NoError(t, err)
+
+	// Обрываем последнюю запись посередине, как при сбое во время записи
+	crashed := crash(t, dir)
+	path := filepath.Join(crashed, firstSegment)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-10))
+
+	recovered := open(t, crashed, in_memory.FsyncAlways)
+	check(t, recovered, h)
+	_, err = recovered.GetComment(ctx, lost.ID)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	// Недописанная запись не применялась, поэтому ее ID выдается снова
+	again, err := recovered.CreateComment(ctx, h.post, h.alice, nil, "again")
+	require.NoError(t, err)
+	assert.Equal(t, lost.ID, again.ID)
+
+	// Оборванный хвост отрезан при открытии, новая запись читается после повторного сбоя
+	second := open(t, crash(t, crashed), in_memory.FsyncAlways)
+	check(t, second, h)
+	comment, err := second.GetComment(ctx, again.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "again", comment.Content)
+}
+
+func TestCorruptedRecordInTheMiddle(t *testing.T) {
+	dir := t.TempDir()
+	write(t, open(t, dir, in_memory.FsyncAlways))
+
+	// Поврежденная запись не в конце сегмента — не последствие сбоя, а порча данных
+	crashed := crash(t, dir)
+	path := filepath.Join(crashed, firstSegment)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append([]byte("{not json\n"), data...), 0o644))
+
+	_, err = in_memory.Open(config.InMemory{DataDir: crashed, Fsync: in_memory.FsyncAlways}, slog.Default())
+	assert.Error(t, err)
+}
+
+func TestStaleSegmentRemoved(t *testing.T) {
+	dir := t.TempDir()
+	s := open(t, dir, in_memory.FsyncAlways)
+	h := write(t, s)
+	require.NoError(t, s.Snapshot())
+
+	// Процесс упал после записи снимка, но до удаления старого сегмента:
+	// его записи уже в снимке и не должны применяться повторно
+	crashed := crash(t, dir)
+	require.NoError(t, os.WriteFile(filepath.Join(crashed, firstSegment), []byte("{not json\n"), 0o644))
+
+	recovered := open(t, crashed, in_memory.FsyncAlways)
+	check(t, recovered, h)
+	assert.NotContains(t, files(t, crashed), firstSegment)
+}
+
+func TestFsyncModes(t *testing.T) {
+	for _, mode := range []string{in_memory.FsyncAlways, in_memory.FsyncInterval, in_memory.FsyncNever} {
+		t.Run(mode, func(t *testing.T) {
+			dir := t.TempDir()
+			s := open(t, dir, mode)
+			h := write(t, s)
+
+			// Режим влияет только на сброс на диск: записи сразу попадают в файл
+			check(t, open(t, crash(t, dir), mode), h)
+
+			require.NoError(t, s.Stop(ctx))
+			check(t, open(t, dir, mode), h)
+		})
+	}
+}
+
+func TestInvalidFsyncConfig(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := in_memory.Open(config.InMemory{DataDir: t.TempDir(), Fsync: "sometimes"}, log)
+	assert.Error(t, err)
+
+	_, err = in_memory.Open(config.InMemory{DataDir: t.TempDir(), Fsync: in_memory.FsyncInterval}, log)
+	assert.Error(t, err)
+}
+
+func TestWithoutDataDir(t *testing.T) {
+	s, err := in_memory.Open(config.InMemory{}, slog.Default())
+	require.NoError(t, err)
+
+	write(t, s)
+	assert.NoError(t, s.Snapshot())
+	assert.NoError(t, s.Stop(ctx))
+}
@@ -0,0 +1,78 @@
+package tpg
+
+import (
+	"Habr-comments-server/internal/storage"
+	"Habr-comments-server/internal/storage/pg"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMapError проверяет перевод кодов SQLSTATE без базы: ошибки PostgreSQL собираются вручную.
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want error // nil — ошибка возвращается без перевода
+	}{
+		{"unique", "23505", storage.ErrAlreadyExists},
+		{"foreign key", "23503", storage.ErrNotFound},
+		{"check", "23514", storage.ErrValidation},
+		{"not null", "23502", storage.ErrValidation},
+		{"string too long", "22001", storage.ErrValidation},
+		{"index row too large", "54000", storage.ErrValidation},
+		{"serialization failure", "40001", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pgErr := &pgconn.PgError{Code: tt.code, Message: "message", ConstraintName: "constraint"}
+			err := pg.MapError(fmt.Errorf("storage.db.Op: %w", pgErr))
+
+			if tt.want != nil {
+				assert.ErrorIs(t, err, tt.want)
+			}
+			for _, sentinel := range []error{storage.ErrAlreadyExists, storage.ErrNotFound, storage.ErrValidation} {
+				if sentinel != tt.want {
+					assert.NotErrorIs(t, err, sentinel)
+				}
+			}
+
+			// Исходная ошибка с кодом и ограничением остается в цепочке
+			var got *pgconn.PgError
+			require.ErrorAs(t, err, &got)
+			assert.Same(t, pgErr, got)
+		})
+	}
+}
+
+func TestMapErrorNoRows(t *testing.T) {
+	err := pg.MapError(fmt.Errorf("storage.db.GetPost: %w", pgx.ErrNoRows))
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestMapErrorPassesThrough(t *testing.T) {
+	original := errors.New("connection refused")
+	assert.Same(t, original, pg.MapError(original))
+}
+
+// При удалении нарушение внешнего ключа означает, что на запись еще ссылаются, а не что ее нет.
+func TestMapDeleteError(t *testing.T) {
+	pgErr := &pgconn.PgError{Code: "23503", ConstraintName: "comments_parent_id_fkey"}
+	err := pg.MapDeleteError(pgErr)
+	assert.ErrorIs(t, err, storage.ErrValidation)
+	assert.NotErrorIs(t, err, storage.ErrNotFound)
+
+	var got *pgconn.PgError
+	require.ErrorAs(t, err, &got)
+	assert.Equal(t, "comments_parent_id_fkey", got.ConstraintName)
+
+	// Остальные ошибки переводятся как обычно
+	assert.ErrorIs(t, pg.MapDeleteError(pgx.ErrNoRows), storage.ErrNotFound)
+}
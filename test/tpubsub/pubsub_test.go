@@ -0,0 +1,147 @@
+package tpubsub
+
+import (
+	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/pubsub"
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func comment(id, postID int, parentID *int) *models.Comment {
+	return &models.Comment{ID: id, PostId: postID, ParentId: parentID}
+}
+
+func ptr(v int) *int {
+	return &v
+}
+
+// receive вычитывает из канала все уже доставленные комментарии, не дожидаясь новых.
+func receive(ch <-chan *models.Comment) []int {
+	var ids []int
+	for {
+		select {
+		case c, ok := <-ch:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, c.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestPublishFiltersByPostAndParent(t *testing.T) {
+	b := pubsub.NewBroker(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	all := b.Subscribe(ctx, 1, nil)
+	replies := b.Subscribe(ctx, 1, ptr(100))
+	otherPost := b.Subscribe(ctx, 2, nil)
+
+	b.Publish(comment(1, 1, nil))
+	b.Publish(comment(2, 1, ptr(100)))
+	b.Publish(comment(3, 1, ptr(200)))
+	b.Publish(comment(4, 2, ptr(100)))
+	b.Publish(nil)
+
+	assert.Equal(t, []int{1, 2, 3}, receive(all))
+	assert.Equal(t, []int{2}, receive(replies))
+	assert.Equal(t, []int{4}, receive(otherPost))
+}
+
+func TestPublishDoesNotBlockOnSlowSubscriber(t *testing.T) {
+	b := pubsub.NewBroker(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	slow := b.Subscribe(ctx, 1, nil) // никогда не читает до конца теста
+	fast := b.Subscribe(ctx, 1, nil)
+
+	done := make(chan struct{})
+	var received []int
+	go func() {
+		defer close(done)
+		for c := range fast {
+			received = append(received, c.ID)
+			if c.ID == 5 {
+				return
+			}
+		}
+	}()
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		for i := 1; i <= 5; i++ {
+			b.Publish(comment(i, 1, nil))
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish blocked on a full subscriber")
+	}
+
+	// Медленному подписчику достается только то, что поместилось в буфер
+	assert.Equal(t, []int{1, 2}, receive(slow))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("fast subscriber did not receive all comments")
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, received)
+}
+
+func TestCancelClosesChannel(t *testing.T) {
+	b := pubsub.NewBroker(1)
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chans := make([]<-chan *models.Comment, 0, 10)
+	for i := 0; i < 10; i++ {
+		chans = append(chans, b.Subscribe(ctx, 1, nil))
+	}
+	other := b.Subscribe(context.Background(), 1, nil)
+
+	// Буфер заполнен: закрытие не должно зависеть от того, читает ли подписчик
+	b.Publish(comment(1, 1, nil))
+	cancel()
+
+	for _, ch := range chans {
+		require.Eventually(t, func() bool {
+			for {
+				select {
+				case _, ok := <-ch:
+					if !ok {
+						return true
+					}
+				default:
+					return false
+				}
+			}
+		}, time.Second, time.Millisecond)
+	}
+
+	// Горутины отписки завершились: остается только горутина живой подписки.
+	// Ждем циклом, а не assert.Eventually — тот сам запускает горутины.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before+1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before+1)
+
+	// После отписки публикация не паникует на закрытых каналах и доходит до оставшихся
+	assert.Equal(t, []int{1}, receive(other))
+	b.Publish(comment(2, 1, nil))
+	assert.Equal(t, []int{2}, receive(other))
+}
@@ -0,0 +1,301 @@
+package tratelimit
+
+import (
+	"Habr-comments-server/internal/auth"
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/ratelimit"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestLimiterRefill(t *testing.T) {
+	// 2 операции в секунду, подряд не больше 2
+	l := ratelimit.NewLimiter(2, time.Second, 2, 10)
+
+	for i := 0; i < 2; i++ {
+		allowed, _ := l.Allow("a", start)
+		assert.True(t, allowed)
+	}
+
+	allowed, retryAfter := l.Allow("a", start)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	// Через 200 мс накопилось 0.4 токена — ждать еще 300 мс
+	allowed, retryAfter = l.Allow("a", start.Add(200*time.Millisecond))
+	assert.False(t, allowed)
+	assert.Equal(t, 300*time.Millisecond, retryAfter)
+
+	allowed, _ = l.Allow("a", start.Add(500*time.Millisecond))
+	assert.True(t, allowed)
+
+	// Корзина не копит больше burst, сколько бы ни прошло времени
+	later := start.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		allowed, _ = l.Allow("a", later)
+		assert.True(t, allowed)
+	}
+	allowed, _ = l.Allow("a", later)
+	assert.False(t, allowed)
+
+	// У другого ключа своя корзина
+	allowed, _ = l.Allow("b", start)
+	assert.True(t, allowed)
+}
+
+func TestLimiterAllowNAndRefund(t *testing.T) {
+	l := ratelimit.NewLimiter(3, time.Minute, 3, 10)
+
+	allowed, _ := l.AllowN("a", 2, start)
+	assert.True(t, allowed)
+
+	// Не хватает токенов — не списывается ни один
+	allowed, retryAfter := l.AllowN("a", 2, start)
+	assert.False(t, allowed)
+	assert.Equal(t, 20*time.Second, retryAfter)
+	allowed, _ = l.Allow("a", start)
+	assert.True(t, allowed)
+
+	l.Refund("a", 2)
+	allowed, _ = l.AllowN("a", 2, start)
+	assert.True(t, allowed)
+
+	// Возврат не переполняет корзину сверх burst
+	l.Refund("a", 10)
+	allowed, _ = l.AllowN("a", 3, start)
+	assert.True(t, allowed)
+	allowed, _ = l.Allow("a", start)
+	assert.False(t, allowed)
+
+	// Возврат в неизвестную корзину ничего не создает
+	l.Refund("unknown", 1)
+
+	// Больше burst за раз не списать никогда: времени ожидания нет
+	allowed, retryAfter = l.AllowN("b", 4, start.Add(time.Hour))
+	assert.False(t, allowed)
+	assert.Zero(t, retryAfter)
+	assert.Equal(t, 3, l.Burst())
+}
+
+func TestLimiterEviction(t *testing.T) {
+	l := ratelimit.NewLimiter(1, time.Hour, 1, 2)
+
+	for _, key := range []string{"a", "b"} {
+		allowed, _ := l.Allow(key, start)
+		require.True(t, allowed)
+	}
+
+	// "a" использовался недавно, поэтому при переполнении вытесняется "b"
+	allowed, _ := l.Allow("a", start)
+	assert.False(t, allowed)
+	allowed, _ = l.Allow("c", start)
+	assert.True(t, allowed)
+
+	// Вытесненный ключ начинает с полной корзиной, оставшиеся сохраняют состояние
+	allowed, _ = l.Allow("b", start)
+	assert.True(t, allowed)
+	allowed, _ = l.Allow("c", start)
+	assert.False(t, allowed)
+}
+
+func TestNewValidatesRules(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.RateLimit
+	}{
+		{"no max keys", config.RateLimit{}},
+		{"unknown per", config.RateLimit{MaxKeys: 1, Operations: map[string]config.RateLimitRule{
+			"login": {Per: "session", Limit: 1, Interval: time.Minute},
+		}}},
+		{"zero limit", config.RateLimit{MaxKeys: 1, Operations: map[string]config.RateLimitRule{
+			"login": {Per: ratelimit.PerIP, Interval: time.Minute},
+		}}},
+		{"zero interval", config.RateLimit{MaxKeys: 1, Operations: map[string]config.RateLimitRule{
+			"login": {Per: ratelimit.PerIP, Limit: 1},
+		}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ratelimit.New(tt.cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+var schema = gqlparser.MustLoadSchema(&ast.Source{Input: `
+type Query { ping: Int }
+type Mutation { createPost: Int, createComment: Int, login: Int, logout: Int }
+`})
+
+// limits создает лимиты: createPost и createComment — по одной операции в час на пользователя,
+// login — одна в час на IP.
+func limits(t *testing.T) *ratelimit.Limits {
+	t.Helper()
+
+	l, err := ratelimit.New(config.RateLimit{MaxKeys: 100, Operations: map[string]config.RateLimitRule{
+		"createPost":    {Per: ratelimit.PerUser, Limit: 1, Interval: time.Hour},
+		"createComment": {Per: ratelimit.PerUser, Limit: 1, Interval: time.Hour},
+		"login":         {Per: ratelimit.PerIP, Limit: 1, Interval: time.Hour},
+	}})
+	require.NoError(t, err)
+	return l
+}
+
+// client — контекст запроса: IP кладется тем же ClientIPMiddleware, что и в сервере.
+func client(t *testing.T, remoteAddr string, userID int) context.Context {
+	t.Helper()
+
+	var ctx context.Context
+	handler := ratelimit.ClientIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	req.RemoteAddr = remoteAddr
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if userID > 0 {
+		ctx = auth.WithUserID(ctx, userID)
+	}
+	return ctx
+}
+
+// run пропускает операцию через Middleware и сообщает, дошла ли она до выполнения.
+func run(t *testing.T, l *ratelimit.Limits, ctx context.Context, query string) (*graphql.Response, bool) {
+	t.Helper()
+
+	doc := gqlparser.MustLoadQuery(schema, query)
+	ctx = graphql.WithOperationContext(ctx, &graphql.OperationContext{
+		RawQuery:  query,
+		Doc:       doc,
+		Operation: doc.Operations[0],
+	})
+
+	executed := false
+	resp := l.Middleware(ctx, func(ctx context.Context) graphql.ResponseHandler {
+		executed = true
+		return graphql.OneShot(&graphql.Response{})
+	})(ctx)
+	return resp, executed
+}
+
+func TestMiddlewareRateLimitedError(t *testing.T) {
+	l := limits(t)
+	ctx := client(t, "10.0.0.1:5000", 1)
+
+	_, executed := run(t, l, ctx, `mutation { createPost }`)
+	assert.True(t, executed)
+
+	resp, executed := run(t, l, ctx, `mutation { post: createPost }`)
+	assert.False(t, executed)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "RATE_LIMITED", resp.Errors[0].Extensions["code"])
+	assert.Equal(t, 3600, resp.Errors[0].Extensions["retryAfter"])
+	assert.Equal(t, ast.Path{ast.PathName("post")}, resp.Errors[0].Path)
+
+	// Поля без правил и запросы на чтение не ограничиваются
+	_, executed = run(t, l, ctx, `mutation { logout }`)
+	assert.True(t, executed)
+	_, executed = run(t, l, ctx, `query { ping }`)
+	assert.True(t, executed)
+}
+
+func TestMiddlewareKeys(t *testing.T) {
+	l := limits(t)
+
+	// Лимит per: user считается по пользователю, даже если IP меняется
+	_, executed := run(t, l, client(t, "10.0.0.1:5000", 1), `mutation { createPost }`)
+	assert.True(t, executed)
+	_, executed = run(t, l, client(t, "10.0.0.2:5000", 1), `mutation { createPost }`)
+	assert.False(t, executed)
+	_, executed = run(t, l, client(t, "10.0.0.1:5000", 2), `mutation { createPost }`)
+	assert.True(t, executed)
+
+	// Анонимные запросы при per: user считаются по IP
+	_, executed = run(t, l, client(t, "10.0.0.3:5000", 0), `mutation { createPost }`)
+	assert.True(t, executed)
+	_, executed = run(t, l, client(t, "10.0.0.3:6000", 0), `mutation { createPost }`)
+	assert.False(t, executed)
+	_, executed = run(t, l, client(t, "10.0.0.4:5000", 0), `mutation { createPost }`)
+	assert.True(t, executed)
+
+	// Лимит per: ip не зависит от пользователя
+	_, executed = run(t, l, client(t, "10.0.0.5:5000", 1), `mutation { login }`)
+	assert.True(t, executed)
+	_, executed = run(t, l, client(t, "10.0.0.5:5000", 2), `mutation { login }`)
+	assert.False(t, executed)
+}
+
+func TestMiddlewareAllOrNothing(t *testing.T) {
+	l := limits(t)
+	ctx := client(t, "10.0.0.1:5000", 1)
+
+	// Исчерпываем только createComment
+	_, executed := run(t, l, ctx, `mutation { createComment }`)
+	require.True(t, executed)
+
+	// createPost разрешен, но createComment — нет: операция не выполняется,
+	// и токен createPost возвращается
+	resp, executed := run(t, l, ctx, `mutation { createPost createComment }`)
+	assert.False(t, executed)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, ast.Path{ast.PathName("createComment")}, resp.Errors[0].Path)
+
+	_, executed = run(t, l, ctx, `mutation { createPost }`)
+	assert.True(t, executed)
+}
+
+func TestMiddlewareAliasesShareBucket(t *testing.T) {
+	l := limits(t)
+	ctx := client(t, "10.0.0.1:5000", 1)
+
+	// Два алиаса одного поля требуют два токена при лимите в один: не списывается ни один.
+	// Два токена в корзину не поместятся никогда, поэтому это ошибка запроса без retryAfter.
+	resp, executed := run(t, l, ctx, `mutation { a: createPost b: createPost }`)
+	assert.False(t, executed)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "VALIDATION_FAILED", resp.Errors[0].Extensions["code"])
+	assert.NotContains(t, resp.Errors[0].Extensions, "retryAfter")
+	assert.Equal(t, ast.Path{ast.PathName("a")}, resp.Errors[0].Path)
+
+	_, executed = run(t, l, ctx, `mutation { createPost }`)
+	assert.True(t, executed)
+}
+
+func TestMiddlewareAliasesWithinBurst(t *testing.T) {
+	l, err := ratelimit.New(config.RateLimit{MaxKeys: 100, Operations: map[string]config.RateLimitRule{
+		"createComment": {Per: ratelimit.PerUser, Limit: 3, Interval: time.Minute},
+	}})
+	require.NoError(t, err)
+	ctx := client(t, "10.0.0.1:5000", 1)
+
+	_, executed := run(t, l, ctx, `mutation { a: createComment b: createComment }`)
+	assert.True(t, executed)
+
+	// Два токена в корзину помещаются, но сейчас остался один: повтор возможен через 20 с
+	resp, executed := run(t, l, ctx, `mutation { a: createComment b: createComment }`)
+	assert.False(t, executed)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "RATE_LIMITED", resp.Errors[0].Extensions["code"])
+	assert.Equal(t, 20, resp.Errors[0].Extensions["retryAfter"])
+
+	// Четыре поля при burst 3 отклоняются без списания: оставшийся токен на месте
+	resp, executed = run(t, l, ctx, `mutation { a: createComment b: createComment c: createComment d: createComment }`)
+	assert.False(t, executed)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "VALIDATION_FAILED", resp.Errors[0].Extensions["code"])
+
+	_, executed = run(t, l, ctx, `mutation { createComment }`)
+	assert.True(t, executed)
+}
@@ -0,0 +1,92 @@
+package tservice
+
+import (
+	"Habr-comments-server/internal/models"
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockCommentRepository struct {
+	mock.Mock
+}
+
+func (m *MockCommentRepository) GetComment(ctx context.Context, id int) (models.Comment, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.Comment), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetComments(ctx context.Context, postID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	args := m.Called(ctx, postID, sort, page)
+	return args.Get(0).(models.CommentPage), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetChildComments(ctx context.Context, parentID int, sort models.CommentSort, page models.PageParams) (models.CommentPage, error) {
+	args := m.Called(ctx, parentID, sort, page)
+	return args.Get(0).(models.CommentPage), args.Error(1)
+}
+
+func (m *MockCommentRepository) CreateComment(ctx context.Context, postID, authorID int, parentID *int, content string) (models.Comment, error) {
+	args := m.Called(ctx, postID, authorID, parentID, content)
+	//log.Printf("Переданный ctx: %v", ctx)
+	return args.Get(0).(models.Comment), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetCommentTree(ctx context.Context, postID, maxDepth, rootLimit int) ([]models.CommentTreeNode, error) {
+	args := m.Called(ctx, postID, maxDepth, rootLimit)
+	return args.Get(0).([]models.CommentTreeNode), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetCommentSubtree(ctx context.Context, id, maxDepth int) ([]models.CommentTreeNode, error) {
+	args := m.Called(ctx, id, maxDepth)
+	return args.Get(0).([]models.CommentTreeNode), args.Error(1)
+}
+
+func (m *MockCommentRepository) UpdateComment(ctx context.Context, id int, content string) error {
+	args := m.Called(ctx, id, content)
+	return args.Error(0)
+}
+
+func (m *MockCommentRepository) DeleteComment(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockCommentRepository) VoteComment(ctx context.Context, commentID, userID, value int) error {
+	args := m.Called(ctx, commentID, userID, value)
+	return args.Error(0)
+}
+
+func (m *MockCommentRepository) GetUserVotes(ctx context.Context, userID int, commentIDs []int) ([]int, error) {
+	args := m.Called(ctx, userID, commentIDs)
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetAncestors(ctx context.Context, id, limit int) ([]models.Comment, error) {
+	args := m.Called(ctx, id, limit)
+	return args.Get(0).([]models.Comment), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetCommentContext(ctx context.Context, id, parentsAbove, siblings, repliesBelow int) (models.CommentContext, error) {
+	args := m.Called(ctx, id, parentsAbove, siblings, repliesBelow)
+	return args.Get(0).(models.CommentContext), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetCommentsByID(ctx context.Context, ids []int) ([]*models.Comment, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]*models.Comment), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetRootCommentPages(ctx context.Context, postIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	args := m.Called(ctx, postIDs, sort, page)
+	return args.Get(0).([]models.CommentPage), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetChildCommentPages(ctx context.Context, parentIDs []int, sort models.CommentSort, page models.PageParams) ([]models.CommentPage, error) {
+	args := m.Called(ctx, parentIDs, sort, page)
+	return args.Get(0).([]models.CommentPage), args.Error(1)
+}
+
+func (m *MockCommentRepository) GetCommentStats(ctx context.Context, ids []int) ([]models.CommentStats, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]models.CommentStats), args.Error(1)
+}
@@ -0,0 +1,62 @@
+package tservice
+
+import (
+	"Habr-comments-server/internal/models"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPostRepository struct {
+	mock.Mock
+}
+
+func (m *MockPostRepository) GetPost(ctx context.Context, id int) (models.Post, error) {
+	args := m.Called(ctx, id) // mock.Anything вместо ctx
+
+	post, ok := args.Get(0).(models.Post)
+	if !ok {
+		return models.Post{}, fmt.Errorf("unexpected type: %T", args.Get(0))
+	}
+	return post, args.Error(1)
+}
+
+func (m *MockPostRepository) GetPosts(ctx context.Context, page models.PageParams) (models.PostPage, error) {
+	args := m.Called(ctx, page)
+	return args.Get(0).(models.PostPage), args.Error(1)
+}
+
+func (m *MockPostRepository) CreatePost(ctx context.Context, authorId int, title, content string, allowComments bool) (int, error) {
+	args := m.Called(ctx, authorId, title, content, allowComments)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostRepository) UpdatePost(ctx context.Context, id int, title, content *string) error {
+	args := m.Called(ctx, id, title, content)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) DeletePost(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) BlockComments(ctx context.Context, id int, reason string) error {
+	args := m.Called(ctx, id, reason)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) UnblockComments(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPostRepository) GetPostsByID(ctx context.Context, ids []int) ([]*models.Post, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]*models.Post), args.Error(1)
+}
+
+func (m *MockPostRepository) GetPostStats(ctx context.Context, ids []int) ([]models.PostStats, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]models.PostStats), args.Error(1)
+}
@@ -0,0 +1,56 @@
+package tservice
+
+import (
+	"Habr-comments-server/internal/models"
+	"context"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockUserRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserRepository) CreateUser(ctx context.Context, username, passwordHash string, role models.Role) (int, error) {
+	args := m.Called(ctx, username, passwordHash, role)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUser(ctx context.Context, id int) (models.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	args := m.Called(ctx, username)
+	return args.Get(0).(models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) SetUserRole(ctx context.Context, id int, role models.Role) error {
+	args := m.Called(ctx, id, role)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) GetUsersByID(ctx context.Context, ids []int) ([]*models.User, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]*models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserPosts(ctx context.Context, userID int, page models.PageParams) (models.PostPage, error) {
+	args := m.Called(ctx, userID, page)
+	return args.Get(0).(models.PostPage), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserComments(ctx context.Context, userID int, page models.PageParams) (models.CommentPage, error) {
+	args := m.Called(ctx, userID, page)
+	return args.Get(0).(models.CommentPage), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserPostPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.PostPage, error) {
+	args := m.Called(ctx, userIDs, page)
+	return args.Get(0).([]models.PostPage), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserCommentPages(ctx context.Context, userIDs []int, page models.PageParams) ([]models.CommentPage, error) {
+	args := m.Called(ctx, userIDs, page)
+	return args.Get(0).([]models.CommentPage), args.Error(1)
+}
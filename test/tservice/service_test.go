@@ -1,79 +1,221 @@
 package tservice
 
 import (
+	"Habr-comments-server/internal/config"
 	"Habr-comments-server/internal/models"
+	"Habr-comments-server/internal/storage"
+	"Habr-comments-server/internal/validation"
 	"context"
+	"errors"
 	"github.com/stretchr/testify/mock"
 	"testing"
 
 	s "Habr-comments-server/internal/service"
 	"github.com/stretchr/testify/assert"
-	// "github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 )
 
+var testLimits = config.Validation{MaxTitleLen: 200, MaxPostLen: 20000, MaxCommentLen: 2000, MaxCommentDepth: 3}
+
+// newTestService собирает сервисы поверх моков репозиториев с настоящим валидатором.
+func newTestService(posts *MockPostRepository, comments *MockCommentRepository, users *MockUserRepository) *s.Service {
+	return s.NewService(posts, comments, users, validation.New(testLimits, comments))
+}
+
 func TestGetPost(t *testing.T) {
-	mockPostService := new(MockPostService)
-	mockCommentService := new(MockCommentService)
-	service := s.NewService(mockPostService, mockCommentService)
+	mockPostRepo := new(MockPostRepository)
+	mockCommentRepo := new(MockCommentRepository)
+	service := newTestService(mockPostRepo, mockCommentRepo, new(MockUserRepository))
 
 	ctx := context.Background()
 	expectedPost := models.Post{ID: 1, Title: "Test Post", Content: "Test Content", AllowComments: true}
 
-	mockPostService.On("GetPost", ctx, 1).Return(expectedPost, nil)
+	mockPostRepo.On("GetPost", ctx, 1).Return(expectedPost, nil)
 
 	post, err := service.PostService.GetPost(ctx, 1)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedPost, post)
 
-	mockPostService.AssertExpectations(t)
+	mockPostRepo.AssertExpectations(t)
 }
 
 func TestCreatePost(t *testing.T) {
-	mockPostService := new(MockPostService)
-	mockCommentService := new(MockCommentService)
-	service := s.NewService(mockPostService, mockCommentService)
+	mockPostRepo := new(MockPostRepository)
+	mockCommentRepo := new(MockCommentRepository)
+	service := newTestService(mockPostRepo, mockCommentRepo, new(MockUserRepository))
 
 	ctx := context.Background()
-	mockPostService.On("CreatePost", ctx, 1, "Title", "Content", true).Return(1, nil)
+	expectedPost := models.Post{ID: 1, Title: "Title", Content: "Content", AuthorId: 1, AllowComments: true}
 
-	postID, err := service.PostService.CreatePost(ctx, 1, "Title", "Content", true)
+	mockPostRepo.On("CreatePost", ctx, 1, "Title", "Content", true).Return(1, nil)
+	mockPostRepo.On("GetPost", ctx, 1).Return(expectedPost, nil)
+
+	post, err := service.PostService.CreatePost(ctx, 1, "Title", "Content", true)
 	assert.NoError(t, err)
-	assert.Equal(t, 1, postID)
+	assert.Equal(t, expectedPost, post)
+
+	mockPostRepo.AssertExpectations(t)
+}
+
+func TestCreatePostBlankTitle(t *testing.T) {
+	mockPostRepo := new(MockPostRepository)
+	mockCommentRepo := new(MockCommentRepository)
+	service := newTestService(mockPostRepo, mockCommentRepo, new(MockUserRepository))
+
+	_, err := service.PostService.CreatePost(context.Background(), 1, "   ", "Content", true)
 
-	mockPostService.AssertExpectations(t)
+	var validationErr *validation.Error
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ErrorIs(t, err, storage.ErrValidation)
+	assert.Equal(t, validation.FieldTitle, validationErr.Fields[0].Field)
+
+	// До хранилища невалидный пост не доходит
+	mockPostRepo.AssertNotCalled(t, "CreatePost", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestCreateComment(t *testing.T) {
-	mockPostService := new(MockPostService)
-	mockCommentService := new(MockCommentService)
-	service := s.NewService(mockPostService, mockCommentService)
+	mockPostRepo := new(MockPostRepository)
+	mockCommentRepo := new(MockCommentRepository)
+	service := newTestService(mockPostRepo, mockCommentRepo, new(MockUserRepository))
 
 	ctx := context.Background() // ОДИН раз создаем контекст
+	expectedComment := models.Comment{ID: 10, PostId: 1, AuthorId: 2, Content: "New Comment"}
 
-	mockCommentService.On("CreateComment", mock.Anything, 1, 2, (*int)(nil), "New Comment").Return(10, nil).Once()
+	// Созданная строка приходит из хранилища целиком, повторное чтение не нужно
+	mockCommentRepo.On("CreateComment", mock.Anything, 1, 2, (*int)(nil), "New Comment").Return(expectedComment, nil).Once()
 
 	// Вызываем тестируемую функцию
-	commentID, err := service.CommentService.CreateComment(ctx, 1, 2, nil, "New Comment")
+	comment, err := service.CommentService.CreateComment(ctx, 1, 2, nil, "New Comment")
 
 	// Проверяем результат
 	assert.NoError(t, err)
-	assert.Equal(t, 10, commentID)
+	assert.Equal(t, expectedComment, comment)
 
 	// Проверяем вызовы моков
-	mockPostService.AssertExpectations(t)
-	mockCommentService.AssertExpectations(t)
+	mockPostRepo.AssertExpectations(t)
+	mockCommentRepo.AssertExpectations(t)
+}
+
+func TestCreateCommentBlocked(t *testing.T) {
+	mockPostRepo := new(MockPostRepository)
+	mockCommentRepo := new(MockCommentRepository)
+	service := newTestService(mockPostRepo, mockCommentRepo, new(MockUserRepository))
+
+	mockCommentRepo.On("CreateComment", mock.Anything, 1, 2, (*int)(nil), "New Comment").
+		Return(models.Comment{}, storage.ErrCommentsBlock).Once()
+
+	_, err := service.CommentService.CreateComment(context.Background(), 1, 2, nil, "New Comment")
+	assert.ErrorIs(t, err, storage.ErrCommentsBlock)
+
+	mockCommentRepo.AssertExpectations(t)
+	mockCommentRepo.AssertNotCalled(t, "GetComment", mock.Anything, mock.Anything)
+}
+
+func TestCreateCommentParentFromAnotherPost(t *testing.T) {
+	mockPostRepo := new(MockPostRepository)
+	mockCommentRepo := new(MockCommentRepository)
+	service := newTestService(mockPostRepo, mockCommentRepo, new(MockUserRepository))
+
+	parentID := 5
+	mockCommentRepo.On("GetComment", mock.Anything, parentID).Return(models.Comment{ID: parentID, PostId: 2}, nil).Once()
+
+	_, err := service.CommentService.CreateComment(context.Background(), 1, 2, &parentID, "Reply")
+
+	var validationErr *validation.Error
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, validation.FieldParentID, validationErr.Fields[0].Field)
+
+	mockCommentRepo.AssertExpectations(t)
+	mockCommentRepo.AssertNotCalled(t, "CreateComment", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateCommentTooDeep(t *testing.T) {
+	mockPostRepo := new(MockPostRepository)
+	mockCommentRepo := new(MockCommentRepository)
+	service := newTestService(mockPostRepo, mockCommentRepo, new(MockUserRepository))
+
+	// Родитель на глубине 3: ответ на него был бы глубже MaxCommentDepth
+	parentID, grandparentID := 5, 4
+	mockCommentRepo.On("GetComment", mock.Anything, parentID).Return(models.Comment{ID: parentID, PostId: 1, ParentId: &grandparentID}, nil).Once()
+	mockCommentRepo.On("GetAncestors", mock.Anything, parentID, testLimits.MaxCommentDepth).
+		Return([]models.Comment{{ID: 2}, {ID: 3}, {ID: grandparentID}}, nil).Once()
+
+	_, err := service.CommentService.CreateComment(context.Background(), 1, 2, &parentID, "Reply")
+
+	var validationErr *validation.Error
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, validation.FieldParentID, validationErr.Fields[0].Field)
+
+	mockCommentRepo.AssertExpectations(t)
+	mockCommentRepo.AssertNotCalled(t, "CreateComment", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestVoteCommentInvalidValue(t *testing.T) {
+	mockPostRepo := new(MockPostRepository)
+	mockCommentRepo := new(MockCommentRepository)
+	service := newTestService(mockPostRepo, mockCommentRepo, new(MockUserRepository))
+
+	_, err := service.CommentService.VoteComment(context.Background(), 1, 2, 5)
+	assert.ErrorIs(t, err, storage.ErrValidation)
+
+	mockCommentRepo.AssertNotCalled(t, "VoteComment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestBlockComments(t *testing.T) {
-	mockPostService := new(MockPostService)
-	mockCommentService := new(MockCommentService)
-	service := s.NewService(mockPostService, mockCommentService)
+	mockPostRepo := new(MockPostRepository)
+	mockCommentRepo := new(MockCommentRepository)
+	service := newTestService(mockPostRepo, mockCommentRepo, new(MockUserRepository))
 
 	ctx := context.Background()
-	mockPostService.On("BlockComments", ctx, 1).Return(nil)
+	expectedPost := models.Post{ID: 1, AllowComments: false}
+
+	mockPostRepo.On("BlockComments", ctx, 1, "").Return(nil)
+	mockPostRepo.On("GetPost", ctx, 1).Return(expectedPost, nil)
+
+	post, err := service.PostService.BlockComments(ctx, 1, "")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedPost, post)
+
+	mockPostRepo.AssertExpectations(t)
+}
+
+func TestRegisterAlwaysUser(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	service := newTestService(new(MockPostRepository), new(MockCommentRepository), mockUserRepo)
+
+	expectedUser := models.User{ID: 7, Username: "admin", Role: models.RoleUser}
+
+	// Пароль сохраняется только в виде хеша; имя не дает роли администратора
+	mockUserRepo.On("CreateUser", mock.Anything, "admin", mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret123")) == nil
+	}), models.RoleUser).Return(7, nil).Once()
+	mockUserRepo.On("GetUser", mock.Anything, 7).Return(expectedUser, nil).Once()
+
+	user, err := service.UserService.Register(context.Background(), " admin ", "secret123")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedUser, user)
 
-	err := service.PostService.BlockComments(ctx, 1)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestAuthenticateWrongPassword(t *testing.T) {
+	mockUserRepo := new(MockUserRepository)
+	service := newTestService(new(MockPostRepository), new(MockCommentRepository), mockUserRepo)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
 	assert.NoError(t, err)
 
-	mockPostService.AssertExpectations(t)
+	mockUserRepo.On("GetUserByUsername", mock.Anything, "bob").
+		Return(models.User{ID: 3, Username: "bob", PasswordHash: string(hash)}, nil).Once()
+	mockUserRepo.On("GetUserByUsername", mock.Anything, "nobody").
+		Return(models.User{}, storage.ErrNotFound).Once()
+
+	_, err = service.UserService.Authenticate(context.Background(), "bob", "wrong")
+	assert.True(t, errors.Is(err, s.ErrInvalidCredentials))
+
+	// Неизвестное имя неотличимо от неверного пароля
+	_, err = service.UserService.Authenticate(context.Background(), "nobody", "secret123")
+	assert.True(t, errors.Is(err, s.ErrInvalidCredentials))
+
+	mockUserRepo.AssertExpectations(t)
 }
@@ -0,0 +1,110 @@
+package tvalidation
+
+import (
+	"Habr-comments-server/internal/config"
+	"Habr-comments-server/internal/storage"
+	"Habr-comments-server/internal/validation"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var limits = config.Validation{MaxTitleLen: 10, MaxPostLen: 20, MaxCommentLen: 15, MaxCommentDepth: 5}
+
+func TestValidator(t *testing.T) {
+	// Без parentId Validator к хранилищу не обращается
+	v := validation.New(limits, nil)
+
+	tests := []struct {
+		name   string
+		check  func() error
+		fields []string // Поля с ошибками; пусто — проверка пройдена
+	}{
+		{"post ok", func() error { return v.Post("Заголовок", "Текст поста") }, nil},
+		{"title blank", func() error { return v.Post("  ", "text") }, []string{validation.FieldTitle}},
+		{"title with newline", func() error { return v.Post("a\nb", "text") }, []string{validation.FieldTitle}},
+		{"title length in characters", func() error { return v.Post(strings.Repeat("ж", 10), "text") }, nil},
+		{"title too long", func() error { return v.Post(strings.Repeat("ж", 11), "text") }, []string{validation.FieldTitle}},
+		{"post content invalid utf-8", func() error { return v.Post("title", "bad \xff byte") }, []string{validation.FieldContent}},
+		{"post content too long", func() error { return v.Post("title", strings.Repeat("x", 21)) }, []string{validation.FieldContent}},
+		{"all post errors reported", func() error { return v.Post("", "") }, []string{validation.FieldTitle, validation.FieldContent}},
+		{"post update skips nil fields", func() error { return v.PostUpdate(nil, nil) }, nil},
+		{"post update checks title", func() error { title := " "; return v.PostUpdate(&title, nil) }, []string{validation.FieldTitle}},
+
+		{"comment ok", func() error { return v.Comment(context.Background(), 1, nil, "line 1\n\tline 2") }, nil},
+		{"comment control character", func() error { return v.Comment(context.Background(), 1, nil, "nul \x00") }, []string{validation.FieldContent}},
+		{"comment escape sequence", func() error { return v.Comment(context.Background(), 1, nil, "\x1b[31mred") }, []string{validation.FieldContent}},
+		{"comment blank", func() error { return v.Comment(context.Background(), 1, nil, " \n ") }, []string{validation.FieldContent}},
+		{"comment too long", func() error { return v.Comment(context.Background(), 1, nil, strings.Repeat("x", 16)) }, []string{validation.FieldContent}},
+		{"comment update invalid utf-8", func() error { return v.CommentUpdate("\xc3\x28") }, []string{validation.FieldContent}},
+
+		{"vote up", func() error { return v.Vote(1) }, nil},
+		{"vote withdraw", func() error { return v.Vote(0) }, nil},
+		{"vote out of range", func() error { return v.Vote(2) }, []string{validation.FieldValue}},
+
+		{"user ok", func() error { return v.User("alice_01", "password") }, nil},
+		{"username letters of any script", func() error { return v.User("пётр.в", "password") }, nil},
+		{"username too short", func() error { return v.User("ab", "password") }, []string{validation.FieldUsername}},
+		{"username too long", func() error { return v.User(strings.Repeat("a", 33), "password") }, []string{validation.FieldUsername}},
+		{"username with space", func() error { return v.User("bad name", "password") }, []string{validation.FieldUsername}},
+		{"username with slash", func() error { return v.User("a/b/c", "password") }, []string{validation.FieldUsername}},
+		{"password too short", func() error { return v.User("alice", "1234567") }, []string{validation.FieldPassword}},
+		{"password of 72 bytes", func() error { return v.User("alice", strings.Repeat("p", 72)) }, nil},
+		{"password over 72 bytes", func() error { return v.User("alice", strings.Repeat("p", 73)) }, []string{validation.FieldPassword}},
+		// 40 символов кириллицы — 80 байт: bcrypt отбросил бы хвост пароля
+		{"password length in bytes", func() error { return v.User("alice", strings.Repeat("п", 40)) }, []string{validation.FieldPassword}},
+		{"all user errors reported", func() error { return v.User("", "") }, []string{validation.FieldUsername, validation.FieldPassword}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check()
+			if len(tt.fields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, storage.ErrValidation)
+			var validationErr *validation.Error
+			require.ErrorAs(t, err, &validationErr)
+
+			fields := make([]string, len(validationErr.Fields))
+			for i, f := range validationErr.Fields {
+				fields[i] = f.Field
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestValidationLimits(t *testing.T) {
+	assert.NoError(t, limits.Validate())
+
+	tests := []struct {
+		name   string
+		modify func(v *config.Validation)
+	}{
+		{"zero title", func(v *config.Validation) { v.MaxTitleLen = 0 }},
+		{"negative post", func(v *config.Validation) { v.MaxPostLen = -1 }},
+		{"zero comment", func(v *config.Validation) { v.MaxCommentLen = 0 }},
+		{"comment above storage CHECK", func(v *config.Validation) { v.MaxCommentLen = config.MaxCommentLenLimit + 1 }},
+		{"zero depth", func(v *config.Validation) { v.MaxCommentDepth = 0 }},
+		{"depth above path index", func(v *config.Validation) { v.MaxCommentDepth = config.MaxCommentDepthLimit + 1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := limits
+			tt.modify(&v)
+			assert.Error(t, v.Validate())
+		})
+	}
+
+	// Границы хранилищ допустимы
+	v := limits
+	v.MaxCommentLen, v.MaxCommentDepth = config.MaxCommentLenLimit, config.MaxCommentDepthLimit
+	assert.NoError(t, v.Validate())
+}